@@ -112,6 +112,7 @@ func (c *Court) Import(configPath string) error {
 	_, err = c.net.UpdateChainTree(tree, "ids", map[string]interface{}{
 		"Locations": importIds.Locations,
 		"Objects":   importIds.Objects,
+		"Quests":    importIds.Quests,
 	})
 
 	log.Debug("court DAG:", tree.ChainTree.Dag.Dump(context.TODO()))
@@ -0,0 +1,506 @@
+package game
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/AsynkronIT/protoactor-go/actor"
+	"github.com/AsynkronIT/protoactor-go/plugin"
+	cid "github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+	"github.com/quorumcontrol/community/client"
+	"github.com/quorumcontrol/tupelo-go-sdk/gossip3/middleware"
+
+	"github.com/quorumcontrol/jasons-game/handlers"
+	"github.com/quorumcontrol/jasons-game/network"
+	"github.com/quorumcontrol/jasons-game/pb/jasonsgame"
+	"github.com/quorumcontrol/jasons-game/utils/stringslice"
+)
+
+const (
+	accessModePublic    = "public"
+	accessModeAllowlist = "allowlist"
+	accessModePassword  = "password"
+)
+
+const accessTopicSuffix = "/access"
+
+const passwordSaltLength = 16
+
+// passwordHash runs the password through scrypt with the policy's salt, so
+// the hash on the location is slow to guess passwords against
+func passwordHash(salt []byte, password string) (string, error) {
+	key, err := cipherKey([]byte(password), salt)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key[:]), nil
+}
+
+// newPasswordHash hashes the password with a new random salt
+func newPasswordHash(password string) (salt []byte, hash string, err error) {
+	salt = make([]byte, passwordSaltLength)
+	if _, err = io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, "", errors.Wrap(err, "error generating salt")
+	}
+	hash, err = passwordHash(salt, password)
+	return salt, hash, err
+}
+
+func removeDid(dids []string, did string) []string {
+	remaining := []string{}
+	for _, d := range dids {
+		if d != did {
+			remaining = append(remaining, d)
+		}
+	}
+	return remaining
+}
+
+// accessRefusal is why policy keeps playerDid out, or empty if it doesn't.
+// unlockedWith is the password hash the player last unlocked the location with.
+func accessRefusal(policy *jasonsgame.AccessPolicy, playerDid string, unlockedWith string) string {
+	if policy == nil {
+		return ""
+	}
+
+	if stringslice.Include(policy.Banned, playerDid) {
+		return "you've been banned from there"
+	}
+
+	switch policy.Mode {
+	case accessModeAllowlist:
+		if !stringslice.Include(policy.Allowed, playerDid) {
+			return "that's private land, only invited players can go there"
+		}
+	case accessModePassword:
+		if policy.PasswordHash != unlockedWith && !stringslice.Include(policy.Allowed, playerDid) {
+			return "that place is locked, type `unlock <password>` to go in"
+		}
+	}
+	return ""
+}
+
+// checkAccess is why the player can't go to the location with did, or empty
+// if they can. Owners can always go to their own land.
+func (g *Game) checkAccess(actorCtx actor.Context, did string) (string, error) {
+	location, err := fetchLocation(g.network, did)
+	if err != nil {
+		// without the location there's no telling who is allowed in
+		log.Warningf("error fetching location %s to check access: %v", did, err)
+		return "you can't find a way there right now", nil
+	}
+
+	auths, err := g.playerTree.Authentications()
+	if err != nil {
+		return "", errors.Wrap(err, "error fetching player authentications")
+	}
+	isOwnedBy, _ := location.IsOwnedBy(auths)
+	if isOwnedBy {
+		return "", nil
+	}
+
+	policy, err := location.AccessPolicy()
+	if err != nil {
+		return "", err
+	}
+	if refusal := accessRefusal(policy, g.playerTree.Did(), g.unlocked[did]); refusal != "" {
+		if policy.Mode == accessModePassword {
+			g.locked = did
+		}
+		return refusal, nil
+	}
+
+	return g.handlerAccessRefusal(actorCtx, location)
+}
+
+// accessHandler is the handler deciding who can enter a location as of tip,
+// nil if its handler doesn't
+type accessHandler struct {
+	tip     cid.Cid
+	handler *handlers.RemoteHandler
+}
+
+// locationAccessHandler is the handler deciding who can enter the location,
+// or nil if it has none. Handlers are cached until the location changes, so
+// locations without one aren't looked up on every visit.
+func (g *Game) locationAccessHandler(location *LocationTree) (*handlers.RemoteHandler, error) {
+	did := location.MustId()
+	if cached, ok := g.accessHandlers[did]; ok && cached.tip.Equals(location.Tip()) {
+		return cached.handler, nil
+	}
+
+	handler, err := handlers.FindHandlerForTree(g.network, did)
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching location handler")
+	}
+	if handler != nil && !handler.Supports(&jasonsgame.AccessRequest{}) {
+		handler = nil
+	}
+
+	g.accessHandlers[did] = &accessHandler{tip: location.Tip(), handler: handler}
+	return handler, nil
+}
+
+// handlerAccessRefusal asks the location's handler, if it wants a say in who
+// gets in
+func (g *Game) handlerAccessRefusal(actorCtx actor.Context, location *LocationTree) (string, error) {
+	handler, err := g.locationAccessHandler(location)
+	if err != nil || handler == nil {
+		return "", err
+	}
+
+	nonce := make([]byte, 16)
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", errors.Wrap(err, "error generating nonce")
+	}
+
+	request := &jasonsgame.AccessRequest{
+		Location: location.MustId(),
+		Player:   g.playerTree.Did(),
+		ReplyTo:  g.playerTree.Did() + accessTopicSuffix,
+		Nonce:    hex.EncodeToString(nonce),
+	}
+
+	pid := actorCtx.Spawn(NewAccessCheckActorProps(&AccessCheckActorConfig{
+		Network: g.network,
+		Handler: handler,
+		Request: request,
+	}))
+	defer actorCtx.Stop(pid)
+
+	response, err := actorCtx.RequestFuture(pid, &CheckAccess{}, 10*time.Second).Result()
+	if err != nil {
+		return "", errors.Wrap(err, "error asking the location if you can go there")
+	}
+
+	accessResponse, ok := response.(*AccessCheckResponse)
+	if !ok {
+		return "", fmt.Errorf("error casting AccessCheckResponse")
+	}
+	if accessResponse.Error != nil {
+		return "", accessResponse.Error
+	}
+	if accessResponse.Allowed {
+		return "", nil
+	}
+	if accessResponse.Reason == "" {
+		return "you aren't allowed in there", nil
+	}
+	return accessResponse.Reason, nil
+}
+
+// CheckAccess is answered with *AccessCheckResponse once the handler responds
+type CheckAccess struct{}
+
+type AccessCheckResponse struct {
+	Allowed bool
+	Reason  string
+	Error   error
+}
+
+// AccessCheckActor asks a location's handler if a player may enter, for a
+// single AccessRequest. It subscribes for the answer before it starts, so
+// the answer can't arrive before anyone is listening. Answers are only
+// trusted when they have the request's nonce and are signed by an owner of
+// the handler's tree.
+type AccessCheckActor struct {
+	middleware.LogAwareHolder
+	network      network.Network
+	handler      *handlers.RemoteHandler
+	request      *jasonsgame.AccessRequest
+	waiting      *actor.PID
+	subscription *client.Subscription
+}
+
+type AccessCheckActorConfig struct {
+	Network network.Network
+	Handler *handlers.RemoteHandler
+	Request *jasonsgame.AccessRequest
+}
+
+func NewAccessCheckActorProps(cfg *AccessCheckActorConfig) *actor.Props {
+	return actor.PropsFromProducer(func() actor.Actor {
+		return &AccessCheckActor{
+			network: cfg.Network,
+			handler: cfg.Handler,
+			request: cfg.Request,
+		}
+	}).WithReceiverMiddleware(
+		middleware.LoggingMiddleware,
+		plugin.Use(&middleware.LogPlugin{}),
+	)
+}
+
+func (a *AccessCheckActor) Receive(actorCtx actor.Context) {
+	switch msg := actorCtx.Message().(type) {
+	case *actor.Started:
+		subscription, err := a.network.Community().SubscribeActor(actorCtx.Self(), a.network.Community().TopicFor(a.request.ReplyTo))
+		if err != nil {
+			a.Log.Errorw("error subscribing to access responses", "err", err)
+			return
+		}
+		a.subscription = subscription
+	case *actor.Stopping:
+		if a.subscription == nil {
+			return
+		}
+		err := a.network.Community().Unsubscribe(a.subscription)
+		if err != nil {
+			a.Log.Errorw("error unsubscribing from access responses", "err", err)
+		}
+	case *CheckAccess:
+		if a.subscription == nil {
+			actorCtx.Respond(&AccessCheckResponse{Error: fmt.Errorf("error subscribing to access responses")})
+			return
+		}
+		err := a.handler.Handle(a.request)
+		if err != nil {
+			actorCtx.Respond(&AccessCheckResponse{Error: errors.Wrap(err, "error sending access request")})
+			return
+		}
+		// answered when the handler responds
+		a.waiting = actorCtx.Sender()
+	case *jasonsgame.AccessResponse:
+		if a.waiting == nil || msg.Location != a.request.Location || msg.Player != a.request.Player || msg.Nonce != a.request.Nonce {
+			return
+		}
+		err := handlers.VerifySigner(a.network, a.handler.Did(), handlers.AccessResponseSigningHash(msg), msg.Signature)
+		if err != nil {
+			a.Log.Warnw("ignoring access response not signed by the handler", "handler", a.handler.Did(), "err", err)
+			return
+		}
+		actorCtx.Send(a.waiting, &AccessCheckResponse{Allowed: msg.Allowed, Reason: msg.Reason})
+		a.waiting = nil
+	}
+}
+
+// ownedLocationForAccess is the current location, or nil after telling the
+// player they don't own it
+func (g *Game) ownedLocationForAccess(actorCtx actor.Context) (*LocationTree, error) {
+	location, err := g.currentLocationTree()
+	if err != nil {
+		return nil, err
+	}
+
+	auths, err := g.playerTree.Authentications()
+	if err != nil {
+		return nil, fmt.Errorf("error fetching player authentications")
+	}
+	isOwnedBy, _ := location.IsOwnedBy(auths)
+	if !isOwnedBy {
+		g.sendUserMessage(actorCtx, "you can only change who can come here on land you own")
+		return nil, nil
+	}
+	return location, nil
+}
+
+// updateAccessPolicy changes the current location's policy with update,
+// starting from a public policy if it has none
+func (g *Game) updateAccessPolicy(actorCtx actor.Context, update func(*jasonsgame.AccessPolicy), message string) error {
+	location, err := g.ownedLocationForAccess(actorCtx)
+	if err != nil || location == nil {
+		return err
+	}
+
+	policy, err := location.AccessPolicy()
+	if err != nil {
+		return err
+	}
+	if policy == nil {
+		policy = &jasonsgame.AccessPolicy{Mode: accessModePublic}
+	}
+	update(policy)
+
+	err = location.SetAccessPolicy(policy)
+	if err != nil {
+		return errors.Wrap(err, "error updating access policy")
+	}
+	g.sendUserMessage(actorCtx, message)
+	return nil
+}
+
+func (g *Game) handleMakePrivate(actorCtx actor.Context) error {
+	return g.updateAccessPolicy(actorCtx, func(policy *jasonsgame.AccessPolicy) {
+		policy.Mode = accessModeAllowlist
+		policy.PasswordHash = ""
+		policy.PasswordSalt = nil
+	}, "only players you allow can come here now, type `allow player <name or did>` to invite someone")
+}
+
+func (g *Game) handleMakePublic(actorCtx actor.Context) error {
+	return g.updateAccessPolicy(actorCtx, func(policy *jasonsgame.AccessPolicy) {
+		policy.Mode = accessModePublic
+		policy.PasswordHash = ""
+		policy.PasswordSalt = nil
+	}, "anyone can come here now, except banned players")
+}
+
+func (g *Game) handleLockWith(actorCtx actor.Context, args string) error {
+	password := strings.TrimSpace(args)
+	if password == "" {
+		g.sendUserMessage(actorCtx, "lock with what? e.g. `lock with open sesame`")
+		return nil
+	}
+
+	salt, hash, err := newPasswordHash(password)
+	if err != nil {
+		return err
+	}
+	return g.updateAccessPolicy(actorCtx, func(policy *jasonsgame.AccessPolicy) {
+		policy.Mode = accessModePassword
+		policy.PasswordHash = hash
+		policy.PasswordSalt = salt
+	}, "this place is locked, players need the password or an invitation to come here")
+}
+
+// namedPlayer finds the player args refers to, by name if they're here or
+// else by did, telling the player if there's nobody by that name
+func (g *Game) namedPlayer(actorCtx actor.Context, args string, usage string) (string, string, error) {
+	args = strings.TrimSpace(args)
+	if args == "" {
+		g.sendUserMessage(actorCtx, usage)
+		return "", "", nil
+	}
+
+	occupants, err := g.getOccupants(actorCtx)
+	if err != nil {
+		return "", "", err
+	}
+
+	name, did, rest := whisperRecipient(occupants.Names, args)
+	if did == "" || rest != "" {
+		g.sendUserMessage(actorCtx, "there's nobody here by that name, use their did for players elsewhere")
+		return "", "", nil
+	}
+	return name, did, nil
+}
+
+func (g *Game) handleAllowPlayer(actorCtx actor.Context, args string, allow bool) error {
+	usage := "allow who? e.g. `allow player jason`"
+	if !allow {
+		usage = "disallow who? e.g. `disallow player jason`"
+	}
+
+	name, did, err := g.namedPlayer(actorCtx, args, usage)
+	if err != nil || did == "" {
+		return err
+	}
+
+	if allow {
+		return g.updateAccessPolicy(actorCtx, func(policy *jasonsgame.AccessPolicy) {
+			if !stringslice.Include(policy.Allowed, did) {
+				policy.Allowed = append(policy.Allowed, did)
+			}
+		}, fmt.Sprintf("%s can come here now", name))
+	}
+	return g.updateAccessPolicy(actorCtx, func(policy *jasonsgame.AccessPolicy) {
+		policy.Allowed = removeDid(policy.Allowed, did)
+	}, fmt.Sprintf("%s is no longer invited here", name))
+}
+
+func (g *Game) handleBanPlayer(actorCtx actor.Context, args string, ban bool) error {
+	usage := "ban who? e.g. `ban player jason`"
+	if !ban {
+		usage = "unban who? e.g. `unban player jason`"
+	}
+
+	name, did, err := g.namedPlayer(actorCtx, args, usage)
+	if err != nil || did == "" {
+		return err
+	}
+
+	if ban {
+		return g.updateAccessPolicy(actorCtx, func(policy *jasonsgame.AccessPolicy) {
+			if !stringslice.Include(policy.Banned, did) {
+				policy.Banned = append(policy.Banned, did)
+			}
+			policy.Allowed = removeDid(policy.Allowed, did)
+		}, fmt.Sprintf("%s is banned from here", name))
+	}
+	return g.updateAccessPolicy(actorCtx, func(policy *jasonsgame.AccessPolicy) {
+		policy.Banned = removeDid(policy.Banned, did)
+	}, fmt.Sprintf("%s is no longer banned from here", name))
+}
+
+func (g *Game) handleShowAccess(actorCtx actor.Context) error {
+	location, err := g.ownedLocationForAccess(actorCtx)
+	if err != nil || location == nil {
+		return err
+	}
+
+	policy, err := location.AccessPolicy()
+	if err != nil {
+		return err
+	}
+	if policy == nil {
+		policy = &jasonsgame.AccessPolicy{Mode: accessModePublic}
+	}
+
+	toSend := indentedList{}
+	switch policy.Mode {
+	case accessModeAllowlist:
+		toSend = append(toSend, "only players you allow can come here")
+	case accessModePassword:
+		toSend = append(toSend, "players need the password or an invitation to come here")
+	default:
+		toSend = append(toSend, "anyone can come here")
+	}
+	for _, did := range policy.Allowed {
+		toSend = append(toSend, fmt.Sprintf("allowed: %s", playerNameFor(g.network, did)))
+	}
+	for _, did := range policy.Banned {
+		toSend = append(toSend, fmt.Sprintf("banned: %s", playerNameFor(g.network, did)))
+	}
+	g.sendUserMessage(actorCtx, toSend)
+	return nil
+}
+
+// handleUnlock tries the password on the last locked location the player
+// was kept out of, and takes them there if it's right. Moving there checks
+// everything else that might keep them out.
+func (g *Game) handleUnlock(actorCtx actor.Context, args string) error {
+	password := strings.TrimSpace(args)
+	if g.locked == "" {
+		g.sendUserMessage(actorCtx, "there's nothing to unlock")
+		return nil
+	}
+	if password == "" {
+		g.sendUserMessage(actorCtx, "unlock with what? e.g. `unlock open sesame`")
+		return nil
+	}
+
+	did := g.locked
+	location, err := fetchLocation(g.network, did)
+	if err != nil {
+		return err
+	}
+	policy, err := location.AccessPolicy()
+	if err != nil {
+		return err
+	}
+	if policy == nil || policy.Mode != accessModePassword {
+		// it's been opened up since
+		g.locked = ""
+		g.handleChangeLocation(actorCtx, did)
+		return nil
+	}
+
+	hash, err := passwordHash(policy.PasswordSalt, password)
+	if err != nil {
+		return err
+	}
+	if hash != policy.PasswordHash {
+		g.sendUserMessage(actorCtx, "that's not the right password")
+		return nil
+	}
+
+	g.locked = ""
+	g.unlocked[did] = hash
+	g.handleChangeLocation(actorCtx, did)
+	return nil
+}
@@ -0,0 +1,106 @@
+package game
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gogo/protobuf/proto"
+	messages "github.com/quorumcontrol/messages/build/go/community"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quorumcontrol/jasons-game/handlers"
+	"github.com/quorumcontrol/jasons-game/network"
+	"github.com/quorumcontrol/jasons-game/pb/jasonsgame"
+)
+
+func TestAccessRefusal(t *testing.T) {
+	salt, hash, err := newPasswordHash("open sesame")
+	require.Nil(t, err)
+	wrongHash, err := passwordHash(salt, "abracadabra")
+	require.Nil(t, err)
+
+	require.Empty(t, accessRefusal(nil, "did:tupelo:visitor", ""))
+
+	public := &jasonsgame.AccessPolicy{Mode: accessModePublic, Banned: []string{"did:tupelo:troll"}}
+	require.Empty(t, accessRefusal(public, "did:tupelo:visitor", ""))
+	require.Contains(t, accessRefusal(public, "did:tupelo:troll", ""), "banned")
+
+	private := &jasonsgame.AccessPolicy{Mode: accessModeAllowlist, Allowed: []string{"did:tupelo:friend"}}
+	require.Empty(t, accessRefusal(private, "did:tupelo:friend", ""))
+	require.Contains(t, accessRefusal(private, "did:tupelo:visitor", ""), "private")
+
+	locked := &jasonsgame.AccessPolicy{Mode: accessModePassword, PasswordHash: hash, Allowed: []string{"did:tupelo:friend"}}
+	require.Contains(t, accessRefusal(locked, "did:tupelo:visitor", ""), "locked")
+	require.Contains(t, accessRefusal(locked, "did:tupelo:visitor", wrongHash), "locked")
+	require.Empty(t, accessRefusal(locked, "did:tupelo:visitor", hash))
+	require.Empty(t, accessRefusal(locked, "did:tupelo:friend", ""))
+
+	// the same password is hashed differently each time it's used
+	_, otherHash, err := newPasswordHash("open sesame")
+	require.Nil(t, err)
+	require.NotEqual(t, hash, otherHash)
+
+	sameHash, err := passwordHash(salt, "open sesame")
+	require.Nil(t, err)
+	require.Equal(t, hash, sameHash)
+}
+
+func TestAccessCheckActor(t *testing.T) {
+	net := network.NewLocalNetwork()
+
+	handlerTree, err := net.CreateChainTree()
+	require.Nil(t, err)
+	handlerTree, err = net.UpdateChainTree(handlerTree, "jasons-game/handler/supports", []string{proto.MessageName((*jasonsgame.AccessRequest)(nil))})
+	require.Nil(t, err)
+	handler, err := handlers.GetRemoteHandler(net, handlerTree.MustId())
+	require.Nil(t, err)
+
+	otherKey, err := crypto.GenerateKey()
+	require.Nil(t, err)
+
+	respond := func(request *jasonsgame.AccessRequest, allowed bool, nonce string, key *ecdsa.PrivateKey) {
+		response := &jasonsgame.AccessResponse{
+			Location: request.Location,
+			Player:   request.Player,
+			Allowed:  allowed,
+			Reason:   "members only",
+			Nonce:    nonce,
+		}
+		signature, err := crypto.Sign(handlers.AccessResponseSigningHash(response), key)
+		require.Nil(t, err)
+		response.Signature = signature
+		require.Nil(t, net.Community().Send(net.Community().TopicFor(request.ReplyTo), response))
+	}
+
+	_, err = net.Community().Subscribe(net.Community().TopicFor(handler.Did()), func(ctx context.Context, _ *messages.Envelope, msg proto.Message) {
+		request := msg.(*jasonsgame.AccessRequest)
+		// only the handler's answer to this request counts
+		respond(request, true, request.Nonce, otherKey)
+		respond(request, true, "an old nonce", net.PrivateKey())
+		respond(request, false, request.Nonce, net.PrivateKey())
+	})
+	require.Nil(t, err)
+
+	pid := rootCtx.Spawn(NewAccessCheckActorProps(&AccessCheckActorConfig{
+		Network: net,
+		Handler: handler,
+		Request: &jasonsgame.AccessRequest{
+			Location: "did:tupelo:garden",
+			Player:   "did:tupelo:visitor",
+			ReplyTo:  "did:tupelo:visitor" + accessTopicSuffix,
+			Nonce:    "a nonce",
+		},
+	}))
+	defer rootCtx.Stop(pid)
+
+	response, err := rootCtx.RequestFuture(pid, &CheckAccess{}, 2*time.Second).Result()
+	require.Nil(t, err)
+	checked, ok := response.(*AccessCheckResponse)
+	require.True(t, ok)
+	require.Nil(t, checked.Error)
+	require.False(t, checked.Allowed)
+	require.Equal(t, "members only", checked.Reason)
+}
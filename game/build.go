@@ -0,0 +1,726 @@
+package game
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/AsynkronIT/protoactor-go/actor"
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/quorumcontrol/jasons-game/game/trees"
+)
+
+var addExitRegex = regexp.MustCompile(`^(.+?)\s+to\s+(.+)$`)
+
+// newLocationTargets are what `add exit` takes to make a new location
+var newLocationTargets = map[string]bool{
+	"new":            true,
+	"new location":   true,
+	"a new location": true,
+}
+
+// PropertiesPath is where `set property` keeps a location's properties, so
+// they can't touch what the game keeps up to date
+const PropertiesPath = "properties"
+
+var oppositeDirections = map[string]string{
+	"north":     "south",
+	"south":     "north",
+	"east":      "west",
+	"west":      "east",
+	"northeast": "southwest",
+	"southwest": "northeast",
+	"northwest": "southeast",
+	"southeast": "northwest",
+	"up":        "down",
+	"down":      "up",
+	"in":        "out",
+	"out":       "in",
+}
+
+const buildHelp = `you're building, changes are previewed before you confirm them:
+describe here <text>
+add exit <direction> to <location did or "new location">
+add response <command> = <text>
+create object here <name> <description>
+set property <path> <value>
+confirm, cancel, undo, stop building`
+
+// pendingBuild is a build command waiting for the player to confirm it
+type pendingBuild struct {
+	preview string
+	// operations are what the build is charged for, see BuildPrices
+	operations []string
+	// cost is what the preview said operations cost
+	cost uint64
+	// paths are what apply changes on the location, so it can be undone
+	paths [][]string
+	apply func(actorCtx actor.Context) error
+	// created is the did of the object apply made, if it made one
+	created string
+	// newLocation is the did of the location apply made, if it made one
+	newLocation string
+}
+
+// buildModeCommands are only taken while building
+var buildModeCommands = map[string]bool{
+	"confirm-build": true,
+	"cancel-build":  true,
+	"undo-build":    true,
+}
+
+// lastBuild is where undo replays the location's values from
+type lastBuild struct {
+	locationDid string
+	tip         cid.Cid
+	// after is the tip the build left, undo refuses if paths changed since
+	after   cid.Cid
+	paths   [][]string
+	created string
+	// newLocationTip is the tip the build left newLocation at, undo refuses
+	// if it changed since
+	newLocation    string
+	newLocationTip cid.Cid
+}
+
+// buildLocation is the current location, or nil after telling the player why
+// they can't build here
+func (g *Game) buildLocation(actorCtx actor.Context) (*LocationTree, error) {
+	if !g.building {
+		g.sendUserMessage(actorCtx, "type `build` to start building")
+		return nil, nil
+	}
+
+	location, err := g.currentLocationTree()
+	if err != nil {
+		return nil, err
+	}
+
+	auths, err := g.playerTree.Authentications()
+	if err != nil {
+		return nil, fmt.Errorf("error fetching player authentications")
+	}
+	isOwnedBy, _ := location.IsOwnedBy(auths)
+	if !isOwnedBy {
+		g.sendUserMessage(actorCtx, "you can only build on land you own")
+		return nil, nil
+	}
+	return location, nil
+}
+
+func (g *Game) handleBuild(actorCtx actor.Context) error {
+	g.building = true
+	location, err := g.buildLocation(actorCtx)
+	if err != nil || location == nil {
+		g.building = false
+		return err
+	}
+	g.updateCommands(actorCtx)
+
+	help := indentedList(strings.Split(buildHelp, "\n"))
+	if g.chargingForBuilds() {
+		help = append(help, describePrices(g.buildPrices())...)
+	}
+	g.sendUserMessage(actorCtx, help)
+	return nil
+}
+
+func (g *Game) handleStopBuilding(actorCtx actor.Context) error {
+	g.building = false
+	g.pendingBuild = nil
+	g.updateCommands(actorCtx)
+	g.sendUserMessage(actorCtx, "you put your tools away")
+	return nil
+}
+
+// previewBuild holds the build until the player confirms it
+func (g *Game) previewBuild(actorCtx actor.Context, build *pendingBuild) {
+	g.pendingBuild = build
+	build.cost = g.buildCost(build.operations)
+
+	toSend := indentedList{"preview: " + build.preview}
+	if build.cost > 0 {
+		toSend = append(toSend, fmt.Sprintf("this costs %s", describeCost(build.cost)))
+	}
+	toSend = append(toSend, "type `confirm` to build it, or `cancel`")
+	g.sendUserMessage(actorCtx, toSend)
+}
+
+func (g *Game) handleDescribeHere(actorCtx actor.Context, args string) error {
+	description := strings.TrimSpace(args)
+	if description == "" {
+		g.sendUserMessage(actorCtx, "describe it how? e.g. `describe here a quiet meadow`")
+		return nil
+	}
+
+	location, err := g.buildLocation(actorCtx)
+	if err != nil || location == nil {
+		return err
+	}
+
+	current, err := location.GetDescription()
+	if err != nil {
+		return errors.Wrap(err, "error fetching description")
+	}
+
+	preview := fmt.Sprintf("this place will be described as \"%s\"", description)
+	if current != "" {
+		preview += fmt.Sprintf(", instead of \"%s\"", current)
+	}
+
+	g.previewBuild(actorCtx, &pendingBuild{
+		preview:    preview,
+		operations: []string{buildSetDescription},
+		paths:      [][]string{{"description"}},
+		apply: func(actorCtx actor.Context) error {
+			response, err := actorCtx.RequestFuture(g.locationActor, &SetLocationDescriptionRequest{Description: description}, 30*time.Second).Result()
+			if err != nil {
+				return err
+			}
+			resp, ok := response.(*SetLocationDescriptionResponse)
+			if !ok {
+				return fmt.Errorf("error casting set description response")
+			}
+			return resp.Error
+		},
+	})
+	return nil
+}
+
+func (g *Game) handleAddExit(actorCtx actor.Context, args string) error {
+	matches := addExitRegex.FindStringSubmatch(strings.TrimSpace(args))
+	if len(matches) != 3 {
+		g.sendUserMessage(actorCtx, "add which exit, to where? e.g. `add exit north to new location`")
+		return nil
+	}
+	direction, target := strings.ToLower(matches[1]), matches[2]
+
+	location, err := g.buildLocation(actorCtx)
+	if err != nil || location == nil {
+		return err
+	}
+
+	existing, err := location.getInteractionFromTree(location, direction)
+	if err != nil {
+		return errors.Wrap(err, "error fetching interaction")
+	}
+
+	if newLocationTargets[strings.ToLower(target)] {
+		back := oppositeDirections[direction]
+		if back == "" {
+			back = "back"
+		}
+
+		preview := fmt.Sprintf("a new location will be made, with `%s` leading there and `%s` leading back here", direction, back)
+		if existing != nil {
+			preview += fmt.Sprintf(", replacing the current %s", direction)
+		}
+
+		hereDid := g.locationDid
+		build := &pendingBuild{
+			preview:    preview,
+			operations: []string{buildCreateLocation, buildAddInteraction},
+			paths:      [][]string{{"interactions", direction}},
+		}
+		build.apply = func(actorCtx actor.Context) error {
+			newTree, err := g.network.CreateChainTree()
+			if err != nil {
+				return errors.Wrap(err, "error creating location")
+			}
+			err = NewLocationTree(g.network, newTree).AddInteraction(&ChangeLocationInteraction{Command: back, Did: hereDid})
+			if err == nil {
+				err = g.buildInteraction(actorCtx, existing != nil, &ChangeLocationInteraction{Command: direction, Did: newTree.MustId()})
+			}
+			if err != nil {
+				// nothing leads to the new location, so don't keep it around
+				g.deleteCreatedTree(newTree.MustId())
+				return errors.Wrap(err, "error adding exit")
+			}
+			build.newLocation = newTree.MustId()
+			return nil
+		}
+		g.previewBuild(actorCtx, build)
+		return nil
+	}
+
+	if !strings.HasPrefix(target, "did:") {
+		g.sendUserMessage(actorCtx, "exits lead to a location did, or `new location`")
+		return nil
+	}
+
+	targetLocation, err := fetchLocation(g.network, target)
+	if err != nil {
+		g.sendUserMessage(actorCtx, fmt.Sprintf("could not find %s", target))
+		return nil
+	}
+	auths, err := g.playerTree.Authentications()
+	if err != nil {
+		return fmt.Errorf("error fetching player authentications")
+	}
+	isOwnedBy, _ := targetLocation.IsOwnedBy(auths)
+	if !isOwnedBy {
+		g.sendUserMessage(actorCtx, "exits can only lead to land you own, ask for a portal instead")
+		return nil
+	}
+
+	targetDescription, err := targetLocation.GetDescription()
+	if err != nil {
+		return errors.Wrap(err, "error fetching description")
+	}
+	preview := fmt.Sprintf("`%s` will lead to %s", direction, describeForPlayer(targetDescription, target))
+	if existing != nil {
+		preview += fmt.Sprintf(", replacing the current %s", direction)
+	}
+
+	g.previewBuild(actorCtx, &pendingBuild{
+		preview:    preview,
+		operations: []string{buildAddInteraction},
+		paths:      [][]string{{"interactions", direction}},
+		apply: func(actorCtx actor.Context) error {
+			return g.buildInteraction(actorCtx, existing != nil, &ChangeLocationInteraction{Command: direction, Did: target})
+		},
+	})
+	return nil
+}
+
+func (g *Game) handleAddResponse(actorCtx actor.Context, args string) error {
+	matches := editInteractionRegex.FindStringSubmatch(strings.TrimSpace(args))
+	if len(matches) != 3 {
+		g.sendUserMessage(actorCtx, "add what response? e.g. `add response wave = nobody waves back`")
+		return nil
+	}
+	command, response := matches[1], matches[2]
+
+	location, err := g.buildLocation(actorCtx)
+	if err != nil || location == nil {
+		return err
+	}
+
+	existing, err := location.getInteractionFromTree(location, command)
+	if err != nil {
+		return errors.Wrap(err, "error fetching interaction")
+	}
+
+	preview := fmt.Sprintf("`%s` will respond with \"%s\"", command, response)
+	if existing != nil {
+		preview += fmt.Sprintf(", replacing %s", describeInteraction(existing))
+	}
+
+	g.previewBuild(actorCtx, &pendingBuild{
+		preview:    preview,
+		operations: []string{buildAddInteraction},
+		paths:      [][]string{{"interactions", command}},
+		apply: func(actorCtx actor.Context) error {
+			return g.buildInteraction(actorCtx, existing != nil, &RespondInteraction{Command: command, Response: response})
+		},
+	})
+	return nil
+}
+
+// buildInteraction adds the interaction to the current location, replacing
+// the one with the same command if it exists
+func (g *Game) buildInteraction(actorCtx actor.Context, exists bool, interaction Interaction) error {
+	var request interface{} = &AddInteractionRequest{Interaction: interaction}
+	if exists {
+		request = &ReplaceInteractionRequest{Command: interaction.GetCommand(), Interaction: interaction}
+	}
+
+	result, err := actorCtx.RequestFuture(g.locationActor, request, 30*time.Second).Result()
+	if err != nil {
+		return errors.Wrap(err, "error building interaction")
+	}
+
+	switch resp := result.(type) {
+	case *AddInteractionResponse:
+		err = resp.Error
+	case *ReplaceInteractionResponse:
+		err = resp.Error
+	default:
+		return fmt.Errorf("error casting interaction response")
+	}
+	if err != nil {
+		return errors.Wrap(err, "error building interaction")
+	}
+	return nil
+}
+
+func (g *Game) handleCreateObjectHere(actorCtx actor.Context, args string) error {
+	fields := strings.SplitN(strings.TrimSpace(args), " ", 2)
+	if fields[0] == "" {
+		g.sendUserMessage(actorCtx, "create what? e.g. `create object here lantern a rusty old lantern`")
+		return nil
+	}
+	name := fields[0]
+	description := ""
+	if len(fields) > 1 {
+		description = strings.TrimSpace(fields[1])
+	}
+
+	location, err := g.buildLocation(actorCtx)
+	if err != nil || location == nil {
+		return err
+	}
+
+	preview := fmt.Sprintf("a %s will be made here", name)
+	if description != "" {
+		preview += fmt.Sprintf(", described as \"%s\"", description)
+	}
+
+	build := &pendingBuild{
+		preview:    preview,
+		operations: []string{buildCreateObject},
+	}
+	build.apply = func(actorCtx actor.Context) error {
+		response, err := actorCtx.RequestFuture(g.inventoryActor, &CreateObjectRequest{
+			Name:        name,
+			Description: description,
+		}, 30*time.Second).Result()
+		if err != nil {
+			return err
+		}
+		createObjectResp, ok := response.(*CreateObjectResponse)
+		if !ok {
+			return fmt.Errorf("error casting create object response")
+		}
+		if createObjectResp.Error == ErrExists {
+			return fmt.Errorf("you already have an object named %s in your bag of hodling", name)
+		}
+		if createObjectResp.Error != nil {
+			return createObjectResp.Error
+		}
+		did := createObjectResp.Object.Did
+		err = g.dropObject(actorCtx, did)
+		if err != nil {
+			// the build is given back, so the object shouldn't be kept either
+			g.discardCreatedObject(actorCtx, did)
+			return err
+		}
+		build.created = did
+		return nil
+	}
+	g.previewBuild(actorCtx, build)
+	return nil
+}
+
+func (g *Game) handleSetProperty(actorCtx actor.Context, args string) error {
+	fields := strings.SplitN(strings.TrimSpace(args), " ", 2)
+	if len(fields) < 2 || strings.TrimSpace(fields[1]) == "" {
+		g.sendUserMessage(actorCtx, "set what to what? e.g. `set property weather/today sunny`")
+		return nil
+	}
+	property := strings.Split(fields[0], "/")
+	value := strings.TrimSpace(fields[1])
+
+	for _, segment := range property {
+		if segment == "" {
+			g.sendUserMessage(actorCtx, fmt.Sprintf("%s isn't a property, e.g. `set property weather/today sunny`", fields[0]))
+			return nil
+		}
+	}
+	path := append([]string{PropertiesPath}, property...)
+
+	location, err := g.buildLocation(actorCtx)
+	if err != nil || location == nil {
+		return err
+	}
+
+	current, err := location.getPath(path)
+	if err != nil {
+		return err
+	}
+
+	preview := fmt.Sprintf("%s will be set to \"%s\"", fields[0], value)
+	if current != nil {
+		preview += fmt.Sprintf(", instead of \"%v\"", current)
+	}
+
+	g.previewBuild(actorCtx, &pendingBuild{
+		preview:    preview,
+		operations: []string{buildSetProperty},
+		paths:      [][]string{path},
+		apply: func(actorCtx actor.Context) error {
+			return g.setLocationPath(actorCtx, path, value)
+		},
+	})
+	return nil
+}
+
+func (g *Game) setLocationPath(actorCtx actor.Context, path []string, value interface{}) error {
+	response, err := actorCtx.RequestFuture(g.locationActor, &SetLocationPathRequest{Path: path, Value: value}, 30*time.Second).Result()
+	if err != nil {
+		return err
+	}
+	resp, ok := response.(*SetLocationPathResponse)
+	if !ok {
+		return fmt.Errorf("error casting set location path response")
+	}
+	return resp.Error
+}
+
+func (g *Game) handleConfirmBuild(actorCtx actor.Context) error {
+	build := g.pendingBuild
+	if build == nil {
+		g.sendUserMessage(actorCtx, "there's nothing to confirm")
+		return nil
+	}
+	g.pendingBuild = nil
+
+	location, err := g.buildLocation(actorCtx)
+	if err != nil || location == nil {
+		return err
+	}
+
+	cost := g.buildCost(build.operations)
+	if cost != build.cost {
+		g.sendUserMessage(actorCtx, fmt.Sprintf("the price has changed since the preview said %s", describeCost(build.cost)))
+		g.previewBuild(actorCtx, build)
+		return nil
+	}
+	if cost > 0 {
+		balance, err := g.inkBalance()
+		if err != nil {
+			return err
+		}
+		if balance < cost {
+			g.sendUserMessage(actorCtx, fmt.Sprintf("that costs %s, and you only have %s", describeCost(cost), describeCost(balance)))
+			return nil
+		}
+	}
+
+	// the tip before building is what undo goes back to
+	before := location.Tip()
+
+	// the build is paid for first, and the ink given back if it fails
+	payment, err := g.chargeInk(cost)
+	if err != nil {
+		log.Warningf("error charging for build: %v", err)
+		g.sendUserMessage(actorCtx, fmt.Sprintf("your ink couldn't be taken, so nothing was built: %v", err))
+		return nil
+	}
+
+	err = build.apply(actorCtx)
+	if err != nil {
+		if payment == nil {
+			return err
+		}
+		refundErr := g.refundInk(payment)
+		if refundErr != nil {
+			log.Errorf("error refunding %s for failed build: %v", describeCost(cost), refundErr)
+			return err
+		}
+		g.sendUserMessage(actorCtx, fmt.Sprintf("that couldn't be built, so your %s was given back", describeCost(cost)))
+		return err
+	}
+
+	built, err := g.currentLocationTree()
+	if err != nil {
+		return err
+	}
+	g.lastBuild = &lastBuild{
+		locationDid: g.locationDid,
+		tip:         before,
+		after:       built.Tip(),
+		paths:       build.paths,
+		created:     build.created,
+		newLocation: build.newLocation,
+	}
+	if build.newLocation != "" {
+		newLocation, err := g.network.GetTree(build.newLocation)
+		if err != nil {
+			return errors.Wrap(err, "error fetching new location")
+		}
+		g.lastBuild.newLocationTip = newLocation.Tip()
+	}
+
+	g.sendUserMessage(actorCtx, "built it, type `undo` to take it back")
+	return g.refreshAfterBuild(actorCtx)
+}
+
+func (g *Game) handleCancelBuild(actorCtx actor.Context) error {
+	if g.pendingBuild == nil {
+		g.sendUserMessage(actorCtx, "there's nothing to cancel")
+		return nil
+	}
+	g.pendingBuild = nil
+	g.sendUserMessage(actorCtx, "left it as it was")
+	return nil
+}
+
+// handleUndoBuild puts back the values the last build changed, as they were
+// at the tip before it, as long as nothing changed them since
+func (g *Game) handleUndoBuild(actorCtx actor.Context) error {
+	build := g.lastBuild
+	if build == nil {
+		g.sendUserMessage(actorCtx, "there's nothing to undo")
+		return nil
+	}
+	if build.locationDid != g.locationDid {
+		g.sendUserMessage(actorCtx, "go back to where you last built to undo it")
+		return nil
+	}
+
+	location, err := g.buildLocation(actorCtx)
+	if err != nil || location == nil {
+		return err
+	}
+
+	changed, err := changedSince(location, build.after, build.paths)
+	if err != nil {
+		return err
+	}
+	if changed {
+		g.lastBuild = nil
+		g.sendUserMessage(actorCtx, "this place has changed since you built that, so it can't be undone")
+		return nil
+	}
+
+	if build.newLocation != "" {
+		newLocation, err := g.network.GetTree(build.newLocation)
+		if err != nil {
+			return errors.Wrap(err, "error fetching new location")
+		}
+		if newLocation == nil || !newLocation.Tip().Equals(build.newLocationTip) {
+			g.lastBuild = nil
+			g.sendUserMessage(actorCtx, "the place you made has changed since, so it can't be unmade")
+			return nil
+		}
+	}
+
+	if build.created != "" {
+		inventoryDid, err := g.locationInventoryDid(actorCtx)
+		if err != nil {
+			return err
+		}
+		removed, err := g.removeFromInventory(inventoryDid, build.created)
+		if err != nil {
+			return errors.Wrap(err, "error undoing build")
+		}
+		if !removed {
+			g.lastBuild = nil
+			g.sendUserMessage(actorCtx, "what you made has been moved, so it can't be unmade")
+			return nil
+		}
+		g.deleteCreatedTree(build.created)
+	}
+
+	err = g.revertBuild(actorCtx, location, build.tip, build.paths)
+	if err != nil {
+		return errors.Wrap(err, "error undoing build")
+	}
+	if build.newLocation != "" {
+		// nothing leads there anymore
+		g.deleteCreatedTree(build.newLocation)
+	}
+
+	g.lastBuild = nil
+	g.sendUserMessage(actorCtx, "undid your last build")
+	return g.refreshAfterBuild(actorCtx)
+}
+
+// changedSince is whether any of paths on location differ from tip
+func changedSince(location *LocationTree, tip cid.Cid, paths [][]string) (bool, error) {
+	then, err := location.AtTip(tip)
+	if err != nil {
+		return false, err
+	}
+
+	for _, path := range paths {
+		was, err := then.getPath(path)
+		if err != nil {
+			return false, err
+		}
+		is, err := location.getPath(path)
+		if err != nil {
+			return false, err
+		}
+		if !reflect.DeepEqual(was, is) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (g *Game) locationInventoryDid(actorCtx actor.Context) (string, error) {
+	inventoryDid, err := actorCtx.RequestFuture(g.locationActor, &GetInventoryDid{}, 5*time.Second).Result()
+	if err != nil {
+		return "", errors.Wrap(err, "error fetching location inventory")
+	}
+	return inventoryDid.(string), nil
+}
+
+// removeFromInventory takes the object out of the inventory, or says false
+// if it isn't there
+func (g *Game) removeFromInventory(inventoryDid string, did string) (bool, error) {
+	inventory, err := trees.FindInventoryTree(g.network, inventoryDid)
+	if err != nil {
+		return false, err
+	}
+
+	exists, err := inventory.Exists(did)
+	if err != nil || !exists {
+		return false, err
+	}
+	return true, inventory.Remove(did)
+}
+
+// discardCreatedObject takes an object a failed build made out of whichever
+// inventory it ended up in, then deletes it
+func (g *Game) discardCreatedObject(actorCtx actor.Context, did string) {
+	inventoryDids := []string{g.playerTree.Did()}
+	if locationInventoryDid, err := g.locationInventoryDid(actorCtx); err == nil {
+		inventoryDids = append(inventoryDids, locationInventoryDid)
+	}
+
+	for _, inventoryDid := range inventoryDids {
+		removed, err := g.removeFromInventory(inventoryDid, did)
+		if err != nil {
+			log.Warningf("error removing %s from %s: %v", did, inventoryDid, err)
+			return
+		}
+		if removed {
+			break
+		}
+	}
+	g.deleteCreatedTree(did)
+}
+
+// deleteCreatedTree deletes a tree a build made, once nothing refers to it
+func (g *Game) deleteCreatedTree(did string) {
+	if err := g.network.DeleteTree(did); err != nil {
+		log.Warningf("error deleting %s: %v", did, err)
+	}
+}
+
+// revertBuild replays the values at paths from the location as it was at tip
+func (g *Game) revertBuild(actorCtx actor.Context, location *LocationTree, tip cid.Cid, paths [][]string) error {
+	then, err := location.AtTip(tip)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		val, err := then.getPath(path)
+		if err != nil {
+			return err
+		}
+		err = g.setLocationPath(actorCtx, path, val)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *Game) refreshAfterBuild(actorCtx actor.Context) error {
+	g.refreshLocationSnapshot()
+	err := g.refreshInteractionsFor(actorCtx, g.locationActor)
+	if err != nil {
+		return err
+	}
+	g.sendUILocation(actorCtx)
+	return nil
+}
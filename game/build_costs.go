@@ -0,0 +1,310 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gogo/protobuf/proto"
+	"github.com/pkg/errors"
+	messages "github.com/quorumcontrol/messages/build/go/community"
+	"github.com/quorumcontrol/messages/build/go/transactions"
+	"github.com/quorumcontrol/tupelo-go-sdk/consensus"
+
+	"github.com/quorumcontrol/jasons-game/game/static"
+	"github.com/quorumcontrol/jasons-game/handlers"
+	"github.com/quorumcontrol/jasons-game/handlers/inksink"
+	"github.com/quorumcontrol/jasons-game/pb/jasonsgame"
+)
+
+// inkSinkTimeout is how long to wait for the sink's handler to answer
+const inkSinkTimeout = 10 * time.Second
+
+// build operations that cost ink, the keys of BuildPrices
+const (
+	buildCreateObject   = "create-object"
+	buildCreateLocation = "create-location"
+	buildSetDescription = "set-description"
+	buildAddInteraction = "add-interaction"
+	buildSetProperty    = "set-property"
+)
+
+// BuildPrices is how much ink each build operation costs
+type BuildPrices map[string]uint64
+
+var DefaultBuildPrices = BuildPrices{
+	buildCreateObject:   5,
+	buildCreateLocation: 10,
+	buildSetDescription: 1,
+	buildAddInteraction: 1,
+	buildSetProperty:    1,
+}
+
+// parseBuildPrices reads prices written like "create-object=5,set-description=1"
+func parseBuildPrices(prices string) (BuildPrices, error) {
+	parsed := BuildPrices{}
+	for _, price := range strings.Split(prices, ",") {
+		price = strings.TrimSpace(price)
+		if price == "" {
+			continue
+		}
+		parts := strings.SplitN(price, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("error parsing build price %s, expected operation=amount", price)
+		}
+		amount, err := strconv.ParseUint(strings.TrimSpace(parts[1]), 10, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("error parsing build price %s", price))
+		}
+		parsed[strings.TrimSpace(parts[0])] = amount
+	}
+	return parsed, nil
+}
+
+// cost totals the price of each operation
+func (p BuildPrices) cost(operations ...string) uint64 {
+	total := uint64(0)
+	for _, operation := range operations {
+		total += p[operation]
+	}
+	return total
+}
+
+// buildPrices are the configured prices, with any set in the static values
+// taking their place
+func (g *Game) buildPrices() BuildPrices {
+	prices := BuildPrices{}
+	for operation, amount := range g.prices {
+		prices[operation] = amount
+	}
+
+	configured, _ := static.Get(g.network, "BuildPrices")
+	if configured == "" {
+		return prices
+	}
+	overrides, err := parseBuildPrices(configured)
+	if err != nil {
+		log.Warningf("ignoring build prices: %v", err)
+		return prices
+	}
+	for operation, amount := range overrides {
+		prices[operation] = amount
+	}
+	return prices
+}
+
+// inkSink is where ink paid for building goes, building is free without one
+func (g *Game) inkSink() string {
+	if g.inkSinkDID != "" {
+		return g.inkSinkDID
+	}
+	sink, _ := static.Get(g.network, "InkSink")
+	return sink
+}
+
+func (g *Game) inkTokenName() *consensus.TokenName {
+	inkDID := g.inkDID
+	if inkDID == "" {
+		inkDID, _ = static.Get(g.network, "InkDID")
+	}
+	if inkDID == "" {
+		return nil
+	}
+	return &consensus.TokenName{ChainTreeDID: inkDID, LocalName: "ink"}
+}
+
+// chargingForBuilds is false when there's nowhere to send ink, which makes
+// building free
+func (g *Game) chargingForBuilds() bool {
+	return g.inkSink() != "" && g.inkTokenName() != nil
+}
+
+// buildCost is what the operations cost the player
+func (g *Game) buildCost(operations []string) uint64 {
+	if !g.chargingForBuilds() {
+		return 0
+	}
+	return g.buildPrices().cost(operations...)
+}
+
+func (g *Game) inkBalance() (uint64, error) {
+	balance, err := g.network.InkBalance(g.playerTree.ChainTree(), g.inkTokenName())
+	if err != nil {
+		return 0, errors.Wrap(err, "error fetching ink balance")
+	}
+	return balance, nil
+}
+
+// inkPayment is ink the sink's handler has taken for a build
+type inkPayment struct {
+	id      string
+	amount  uint64
+	handler handlers.Handler
+}
+
+// inkSinkHandler is the handler taking ink for the sink, which the player
+// can't receive ink on themselves
+func (g *Game) inkSinkHandler() (handlers.Handler, error) {
+	sink, err := g.network.GetTree(g.inkSink())
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching ink sink")
+	}
+	if sink == nil {
+		return nil, fmt.Errorf("could not find ink sink %s", g.inkSink())
+	}
+
+	handler, err := handlers.FindHandlerForTree(g.network, g.inkSink())
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching ink sink handler")
+	}
+	if handler == nil || !handler.Supports(&jasonsgame.InkPayment{}) {
+		return nil, fmt.Errorf("the ink sink has no handler to take ink")
+	}
+	return handler, nil
+}
+
+// chargeInk sends amount of the player's ink to the sink, and waits for the
+// sink's handler to receive it. Nothing is sent unless the sink has a handler.
+func (g *Game) chargeInk(amount uint64) (*inkPayment, error) {
+	if amount == 0 {
+		return nil, nil
+	}
+
+	handler, err := g.inkSinkHandler()
+	if err != nil {
+		return nil, err
+	}
+
+	tokenPayload, err := g.network.SendInk(g.playerTree.ChainTree(), g.inkTokenName(), amount, g.inkSink())
+	if err != nil {
+		return nil, errors.Wrap(err, "error sending ink")
+	}
+	token, err := proto.Marshal(tokenPayload)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling token payload")
+	}
+
+	request := &jasonsgame.InkPayment{
+		From:     g.playerTree.Did(),
+		Id:       tokenPayload.TransactionId,
+		Token:    token,
+		Location: g.locationDid,
+	}
+	request.Signature, err = crypto.Sign(inksink.PaymentSigningHash(request.From, request.Id, request.Location), g.network.PrivateKey())
+	if err != nil {
+		return nil, errors.Wrap(err, "error signing payment")
+	}
+
+	response, err := g.askInkSink(handler, request, func(msg proto.Message) bool {
+		resp, ok := msg.(*jasonsgame.InkPaymentResponse)
+		return ok && resp.Id == request.Id
+	})
+	if err != nil {
+		return nil, err
+	}
+	if errMsg := response.(*jasonsgame.InkPaymentResponse).Error; errMsg != "" {
+		return nil, fmt.Errorf("the ink sink didn't take your ink: %s", errMsg)
+	}
+
+	return &inkPayment{id: request.Id, amount: amount, handler: handler}, nil
+}
+
+// refundInk asks the sink's handler to give back a payment for a build that
+// didn't happen, and receives it
+func (g *Game) refundInk(payment *inkPayment) error {
+	if payment == nil {
+		return nil
+	}
+
+	request := &jasonsgame.InkRefundRequest{
+		From: g.playerTree.Did(),
+		Id:   payment.id,
+	}
+	var err error
+	request.Signature, err = crypto.Sign(inksink.RefundSigningHash(request.From, request.Id), g.network.PrivateKey())
+	if err != nil {
+		return errors.Wrap(err, "error signing refund request")
+	}
+
+	response, err := g.askInkSink(payment.handler, request, func(msg proto.Message) bool {
+		resp, ok := msg.(*jasonsgame.InkRefundResponse)
+		return ok && resp.Id == request.Id
+	})
+	if err != nil {
+		return err
+	}
+	refund := response.(*jasonsgame.InkRefundResponse)
+	if refund.Error != "" {
+		return fmt.Errorf("the ink sink didn't give your ink back: %s", refund.Error)
+	}
+
+	tokenPayload := &transactions.TokenPayload{}
+	err = proto.Unmarshal(refund.Token, tokenPayload)
+	if err != nil {
+		return errors.Wrap(err, "error unmarshaling token payload")
+	}
+	err = g.network.ReceiveInk(g.playerTree.ChainTree(), tokenPayload)
+	if err != nil {
+		return errors.Wrap(err, "error receiving ink")
+	}
+	return nil
+}
+
+// askInkSink sends request to the sink's handler, and waits for the response
+// it matches. It listens before sending, so the response can't be missed.
+func (g *Game) askInkSink(handler handlers.Handler, request proto.Message, matches func(proto.Message) bool) (proto.Message, error) {
+	responses := make(chan proto.Message, 1)
+	topic := g.network.Community().TopicFor(inksink.ReplyTopicFor(g.playerTree.Did()))
+	subscription, err := g.network.Community().Subscribe(topic, func(_ context.Context, _ *messages.Envelope, msg proto.Message) {
+		if !matches(msg) {
+			return
+		}
+		select {
+		case responses <- msg:
+		default:
+		}
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error subscribing to the ink sink")
+	}
+	defer func() {
+		if err := g.network.Community().Unsubscribe(subscription); err != nil {
+			log.Warningf("error unsubscribing from the ink sink: %v", err)
+		}
+	}()
+
+	err = handler.Handle(request)
+	if err != nil {
+		return nil, errors.Wrap(err, "error sending to the ink sink")
+	}
+
+	select {
+	case response := <-responses:
+		return response, nil
+	case <-time.After(inkSinkTimeout):
+		return nil, fmt.Errorf("timeout waiting for the ink sink")
+	}
+}
+
+func describeCost(cost uint64) string {
+	return fmt.Sprintf("%d ink", cost)
+}
+
+// describePrices lists what each operation costs, for the build help
+func describePrices(prices BuildPrices) []string {
+	operations := make([]string, 0, len(prices))
+	for operation := range prices {
+		operations = append(operations, operation)
+	}
+	sort.Strings(operations)
+
+	described := make([]string, 0, len(operations))
+	for _, operation := range operations {
+		described = append(described, fmt.Sprintf("%s costs %s", operation, describeCost(prices[operation])))
+	}
+	return described
+}
@@ -0,0 +1,25 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBuildPrices(t *testing.T) {
+	prices, err := parseBuildPrices("create-object=5, set-description = 2,")
+	require.Nil(t, err)
+	require.Equal(t, BuildPrices{buildCreateObject: 5, buildSetDescription: 2}, prices)
+
+	_, err = parseBuildPrices("create-object")
+	require.NotNil(t, err)
+
+	_, err = parseBuildPrices("create-object=lots")
+	require.NotNil(t, err)
+}
+
+func TestBuildPricesCost(t *testing.T) {
+	prices := BuildPrices{buildCreateLocation: 10, buildAddInteraction: 1}
+	require.Equal(t, uint64(11), prices.cost(buildCreateLocation, buildAddInteraction))
+	require.Equal(t, uint64(0), prices.cost(buildSetProperty))
+}
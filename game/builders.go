@@ -0,0 +1,150 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/AsynkronIT/protoactor-go/actor"
+	"github.com/pkg/errors"
+
+	"github.com/quorumcontrol/jasons-game/utils/stringslice"
+)
+
+// locationForOwner is the current location, or nil after telling the player
+// only its owner can do that. Builders' keys own the location as fully as
+// the owner's, since chaintrees don't have lesser owners, so keeping them
+// from changing who builds there is only a courtesy of the game.
+func (g *Game) locationForOwner(actorCtx actor.Context) (*LocationTree, error) {
+	location, err := g.currentLocationTree()
+	if err != nil {
+		return nil, err
+	}
+
+	auths, err := g.playerTree.Authentications()
+	if err != nil {
+		return nil, fmt.Errorf("error fetching player authentications")
+	}
+	isOwnedBy, _ := location.IsOwnedBy(auths)
+
+	builders, err := location.Builders()
+	if err != nil {
+		return nil, err
+	}
+	if _, isBuilder := builders[g.playerTree.Did()]; !isOwnedBy || isBuilder {
+		g.sendUserMessage(actorCtx, "only the owner can change who builds here")
+		return nil, nil
+	}
+	return location, nil
+}
+
+func (g *Game) handleGrantBuilder(actorCtx actor.Context, args string) error {
+	name, did, err := g.namedPlayer(actorCtx, args, "grant who? e.g. `grant builder jason`")
+	if err != nil || did == "" {
+		return err
+	}
+	if did == g.playerTree.Did() {
+		g.sendUserMessage(actorCtx, "you can already build here")
+		return nil
+	}
+
+	location, err := g.locationForOwner(actorCtx)
+	if err != nil || location == nil {
+		return err
+	}
+
+	playerTree, err := g.network.GetTree(did)
+	if err != nil {
+		return errors.Wrap(err, "error fetching player")
+	}
+	if playerTree == nil {
+		g.sendUserMessage(actorCtx, fmt.Sprintf("could not find %s", name))
+		return nil
+	}
+	auths, err := playerTree.Authentications()
+	if err != nil {
+		return errors.Wrap(err, "error fetching player auths")
+	}
+
+	err = location.AddBuilder(did, auths)
+	if err != nil {
+		return errors.Wrap(err, "error adding builder")
+	}
+	g.sendUserMessage(actorCtx, fmt.Sprintf("%s can build here now", name))
+	return nil
+}
+
+func (g *Game) handleRevokeBuilder(actorCtx actor.Context, args string) error {
+	name, did, err := g.namedPlayer(actorCtx, args, "revoke who? e.g. `revoke builder jason`")
+	if err != nil || did == "" {
+		return err
+	}
+
+	location, err := g.locationForOwner(actorCtx)
+	if err != nil || location == nil {
+		return err
+	}
+
+	builders, err := location.Builders()
+	if err != nil {
+		return err
+	}
+	if _, ok := builders[did]; !ok {
+		g.sendUserMessage(actorCtx, fmt.Sprintf("%s doesn't build here", name))
+		return nil
+	}
+
+	auths, err := g.playerTree.Authentications()
+	if err != nil {
+		return fmt.Errorf("error fetching player authentications")
+	}
+	err = location.RemoveBuilder(did, auths)
+	if err != nil {
+		return errors.Wrap(err, "error removing builder")
+	}
+	g.sendUserMessage(actorCtx, fmt.Sprintf("%s can no longer build here", name))
+	return nil
+}
+
+func (g *Game) handleListBuilders(actorCtx actor.Context) error {
+	location, err := g.locationForOwner(actorCtx)
+	if err != nil || location == nil {
+		return err
+	}
+
+	builders, err := location.Builders()
+	if err != nil {
+		return err
+	}
+	if len(builders) == 0 {
+		g.sendUserMessage(actorCtx, "nobody else builds here, type `grant builder <name or did>` to let someone")
+		return nil
+	}
+
+	names := make([]string, 0, len(builders))
+	for did := range builders {
+		name := playerNameFor(g.network, did)
+		if name != did {
+			name = fmt.Sprintf("%s (%s)", name, did)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	g.sendUserMessage(actorCtx, append(indentedList{"builders here:"}, names...))
+	return nil
+}
+
+// signerName is who the key address belongs to, as far as the location
+// knows
+func (g *Game) signerName(addr string, builders map[string][]string) string {
+	auths, _ := g.playerTree.Authentications()
+	if stringslice.Include(auths, addr) {
+		return "you"
+	}
+	for did, addrs := range builders {
+		if stringslice.Include(addrs, addr) {
+			return playerNameFor(g.network, did)
+		}
+	}
+	return addr
+}
@@ -0,0 +1,173 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AsynkronIT/protoactor-go/actor"
+)
+
+func (g *Game) requestChat(actorCtx actor.Context, request interface{}) error {
+	response, err := actorCtx.RequestFuture(g.chatActor, request, 5*time.Second).Result()
+	if err != nil {
+		return err
+	}
+
+	chatResponse, ok := response.(*ChatResponse)
+	if !ok {
+		return fmt.Errorf("error casting ChatResponse")
+	}
+	return chatResponse.Error
+}
+
+// handleSay picks a response while talking to someone, and otherwise says
+// something to everyone in the location
+func (g *Game) handleSay(actorCtx actor.Context, args string) error {
+	if g.dialogue != nil {
+		if _, err := strconv.Atoi(strings.TrimSpace(args)); err == nil {
+			return g.handleDialogueChoice(actorCtx, args)
+		}
+	}
+
+	message := strings.TrimSpace(args)
+	if message == "" {
+		g.sendUserMessage(actorCtx, "say what?")
+		return nil
+	}
+
+	err := g.requestChat(actorCtx, &SayRequest{Message: message})
+	if err != nil {
+		return err
+	}
+	g.sendUserMessage(actorCtx, fmt.Sprintf("you say: %s", message))
+	return nil
+}
+
+func (g *Game) handleEmote(actorCtx actor.Context, args string) error {
+	message := strings.TrimSpace(args)
+	if message == "" {
+		g.sendUserMessage(actorCtx, "do what? e.g. `/me waves`")
+		return nil
+	}
+
+	err := g.requestChat(actorCtx, &SayRequest{Message: message, Emote: true})
+	if err != nil {
+		return err
+	}
+	g.sendUserMessage(actorCtx, fmt.Sprintf("%s %s", g.playerName(), message))
+	return nil
+}
+
+// handleShout says something to everyone in the location, and in the
+// locations its exits lead to
+func (g *Game) handleShout(actorCtx actor.Context, args string) error {
+	message := strings.TrimSpace(args)
+	if message == "" {
+		g.sendUserMessage(actorCtx, "shout what?")
+		return nil
+	}
+
+	neighbours, err := g.neighbouringLocations()
+	if err != nil {
+		return err
+	}
+
+	err = g.requestChat(actorCtx, &ShoutRequest{Message: message, Neighbours: neighbours})
+	if err != nil {
+		return err
+	}
+	g.sendUserMessage(actorCtx, fmt.Sprintf("you shout: %s", message))
+	return nil
+}
+
+func (g *Game) neighbouringLocations() ([]string, error) {
+	location, err := g.currentLocationTree()
+	if err != nil {
+		return nil, err
+	}
+
+	exits, err := locationExits(g.network, g.playerTree.HomeLocation.MustId(), location)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{g.locationDid: true}
+	var neighbours []string
+	for _, exit := range exits {
+		if !seen[exit.to] {
+			seen[exit.to] = true
+			neighbours = append(neighbours, exit.to)
+		}
+	}
+	sort.Strings(neighbours)
+	return neighbours, nil
+}
+
+// handleWhisper sends a message only the named player will see. The player
+// can be anyone in the location, or anyone at all by did.
+func (g *Game) handleWhisper(actorCtx actor.Context, args string) error {
+	args = strings.TrimSpace(args)
+	if args == "" {
+		g.sendUserMessage(actorCtx, "whisper to who? e.g. `whisper jason hello`")
+		return nil
+	}
+
+	occupants, err := g.getOccupants(actorCtx)
+	if err != nil {
+		return err
+	}
+
+	name, to, message := whisperRecipient(occupants.Names, args)
+	if to == "" {
+		g.sendUserMessage(actorCtx, "there's nobody here by that name, type `who` to see who is here")
+		return nil
+	}
+	if message == "" {
+		g.sendUserMessage(actorCtx, fmt.Sprintf("whisper what to %s?", name))
+		return nil
+	}
+
+	err = g.requestChat(actorCtx, &WhisperRequest{To: to, Message: message})
+	if err != nil {
+		return err
+	}
+	g.sendUserMessage(actorCtx, fmt.Sprintf("you whisper to %s: %s", name, message))
+	return nil
+}
+
+// whisperRecipient splits whisper args into who it's for and the message,
+// given the names of the players here by did. Names can have spaces, so the
+// longest matching name wins. A name shared by more than one player matches
+// nobody, they have to be told apart by did.
+func whisperRecipient(names map[string]string, args string) (name string, did string, message string) {
+	if strings.HasPrefix(args, "did:") {
+		fields := strings.SplitN(args, " ", 2)
+		if len(fields) == 1 {
+			return fields[0], fields[0], ""
+		}
+		return fields[0], fields[0], strings.TrimSpace(fields[1])
+	}
+
+	lowered := strings.ToLower(args)
+	ambiguous := false
+	for occupantDid, occupantName := range names {
+		prefix := strings.ToLower(occupantName)
+		if !strings.HasPrefix(lowered, prefix) || len(occupantName) < len(name) {
+			continue
+		}
+		rest := args[len(prefix):]
+		if rest != "" && rest[0] != ' ' {
+			continue
+		}
+		// two matches the same length are the same name
+		ambiguous = len(occupantName) == len(name)
+		name, did, message = occupantName, occupantDid, strings.TrimSpace(rest)
+	}
+	if ambiguous {
+		return name, "", message
+	}
+	return name, did, message
+}
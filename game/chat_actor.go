@@ -1,9 +1,15 @@
 package game
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/AsynkronIT/protoactor-go/actor"
 	"github.com/AsynkronIT/protoactor-go/plugin"
+	"github.com/gogo/protobuf/proto"
 	"github.com/pkg/errors"
+	"github.com/quorumcontrol/jasons-game/handlers"
+	"github.com/quorumcontrol/jasons-game/handlers/chatlog"
 	"github.com/quorumcontrol/jasons-game/network"
 	"github.com/quorumcontrol/jasons-game/pb/jasonsgame"
 	"github.com/quorumcontrol/tupelo-go-sdk/gossip3/middleware"
@@ -11,23 +17,88 @@ import (
 
 const chatTopicSuffix = "/chat"
 
+const whisperTopicSuffix = "/whisper"
+
+// ChatActor sends and receives the player's chat. Every message is signed by
+// the sender, and only shown once the signature is verified against the
+// owners of the sender's player tree. Senders are shown by the name on their
+// player tree, rather than the name in the message. Verified messages are
+// sent to the parent as userEvents.
 type ChatActor struct {
 	middleware.LogAwareHolder
-	did        string
-	community  *network.Community
-	subscriber *actor.PID
+	network            network.Network
+	playerDid          string
+	name               string
+	locationDid        string
+	locationSubscriber *actor.PID
+	senders            map[string]*chatSender
+	// received are the signing hashes of recent messages, by when they were
+	// sent, so each is only shown once
+	received map[string]int64
+	// chatLog is nil when the location doesn't log its chat
+	chatLog         *chatlog.Log
+	locationHandler *handlers.RemoteHandler
 }
 
 type ChatActorConfig struct {
-	Did       string
-	Community *network.Community
+	Network   network.Network
+	PlayerDid string
+	Name      string
+}
+
+// JoinChatLocation moves the player's chat to the location with Did.
+// Locations with Logged set keep recent chat, and the player catches up on
+// it from the others there or the location's handler.
+type JoinChatLocation struct {
+	Did    string
+	Logged bool
+}
+
+// SetChatLogged turns logging on or off for the current location
+type SetChatLogged struct {
+	Logged bool
+}
+
+// GetChatLog is answered with *ChatLog
+type GetChatLog struct {
+	Count int
+}
+
+type ChatLog struct {
+	Logged   bool
+	Messages []*jasonsgame.ChatMessage
+	// Names are the names of the senders, by did
+	Names map[string]string
+}
+
+type SayRequest struct {
+	Message string
+	Emote   bool
+}
+
+type ShoutRequest struct {
+	Message string
+	// dids of the locations next to this one, which will also hear the shout
+	Neighbours []string
+}
+
+type WhisperRequest struct {
+	To      string
+	Message string
+}
+
+type ChatResponse struct {
+	Error error
 }
 
 func NewChatActorProps(cfg *ChatActorConfig) *actor.Props {
 	return actor.PropsFromProducer(func() actor.Actor {
 		return &ChatActor{
-			did:       cfg.Did,
-			community: cfg.Community,
+			network:   cfg.Network,
+			playerDid: cfg.PlayerDid,
+			name:      cfg.Name,
+			senders:   make(map[string]*chatSender),
+			received:  make(map[string]int64),
 		}
 	}).WithReceiverMiddleware(
 		middleware.LoggingMiddleware,
@@ -35,20 +106,323 @@ func NewChatActorProps(cfg *ChatActorConfig) *actor.Props {
 	)
 }
 
-func (c *ChatActor) chatTopic() []byte {
-	return c.community.TopicFor(c.did + chatTopicSuffix)
+func chatTopicFor(net network.Network, locationDid string) []byte {
+	return net.Community().TopicFor(locationDid + chatTopicSuffix)
+}
+
+func whisperTopicFor(net network.Network, playerDid string) []byte {
+	return net.Community().TopicFor(playerDid + whisperTopicSuffix)
 }
 
 func (c *ChatActor) Receive(actorCtx actor.Context) {
 	switch msg := actorCtx.Message().(type) {
 	case *actor.Started:
-		c.subscriber = actorCtx.Spawn(c.community.NewSubscriberProps(c.chatTopic()))
-	case string:
-		err := c.community.Send(c.chatTopic(), &jasonsgame.ChatMessage{Message: msg})
-		if err != nil {
-			panic(errors.Wrap(err, "failed to broadcast ChatMessage"))
+		actorCtx.Spawn(c.network.Community().NewSubscriberProps(whisperTopicFor(c.network, c.playerDid)))
+	case *JoinChatLocation:
+		c.handleJoinChatLocation(actorCtx, msg)
+	case *SetChatLogged:
+		c.setLogged(msg.Logged)
+	case *GetChatLog:
+		if c.chatLog == nil {
+			actorCtx.Respond(&ChatLog{})
+			return
 		}
+		messages := c.chatLog.Last(msg.Count)
+		actorCtx.Respond(&ChatLog{Logged: true, Messages: messages, Names: c.senderNames(messages)})
+	case *SayRequest:
+		actorCtx.Respond(&ChatResponse{Error: c.handleSayRequest(msg)})
+	case *ShoutRequest:
+		actorCtx.Respond(&ChatResponse{Error: c.handleShoutRequest(msg)})
+	case *WhisperRequest:
+		actorCtx.Respond(&ChatResponse{Error: c.send(whisperTopicFor(c.network, msg.To), &jasonsgame.WhisperMessage{
+			From:    c.playerDid,
+			To:      msg.To,
+			Name:    c.name,
+			Message: msg.Message,
+			SentAt:  time.Now().Unix(),
+		})})
 	case *jasonsgame.ChatMessage:
-		actorCtx.Send(actorCtx.Parent(), msg)
+		if msg.Location != c.locationDid {
+			return
+		}
+		shown := c.receive(actorCtx, msg, func(name string) string {
+			return describeChatMessage(msg, name)
+		})
+		if shown && c.chatLog != nil {
+			c.chatLog.Add(msg)
+		}
+	case *jasonsgame.ShoutMessage:
+		c.receive(actorCtx, msg, func(name string) string {
+			if msg.Location == c.locationDid {
+				return fmt.Sprintf("%s shouts: %s", name, msg.Message)
+			}
+			return fmt.Sprintf("you hear %s shout from nearby: %s", name, msg.Message)
+		})
+	case *jasonsgame.WhisperMessage:
+		if msg.To != c.playerDid {
+			return
+		}
+		c.receive(actorCtx, msg, func(name string) string {
+			return fmt.Sprintf("%s whispers: %s", name, msg.Message)
+		})
+	case *jasonsgame.ChatLogRequest:
+		c.handleChatLogRequest(msg)
+	case *jasonsgame.ChatLogResponse:
+		c.handleChatLogResponse(msg)
+	}
+}
+
+// describeChatMessage shows the message as said by name, which should come
+// from the sender's player tree rather than the message
+func describeChatMessage(msg *jasonsgame.ChatMessage, name string) string {
+	if msg.Emote {
+		return fmt.Sprintf("%s %s", name, msg.Message)
+	}
+	return fmt.Sprintf("%s says: %s", name, msg.Message)
+}
+
+func (c *ChatActor) handleJoinChatLocation(actorCtx actor.Context, msg *JoinChatLocation) {
+	if c.locationSubscriber != nil {
+		actorCtx.Stop(c.locationSubscriber)
+	}
+	c.locationDid = msg.Did
+	c.locationSubscriber = actorCtx.Spawn(c.network.Community().NewSubscriberProps(chatTopicFor(c.network, msg.Did)))
+
+	var err error
+	c.locationHandler, err = handlers.FindHandlerForTree(c.network, msg.Did)
+	if err != nil {
+		c.Log.Warnw("error fetching location handler", "location", msg.Did, "err", err)
+		c.locationHandler = nil
+	}
+
+	c.chatLog = nil
+	c.setLogged(msg.Logged)
+}
+
+// setLogged starts a log for the current location, and asks around for
+// what was said before the player got here
+func (c *ChatActor) setLogged(logged bool) {
+	if !logged {
+		c.chatLog = nil
+		return
+	}
+	if c.chatLog != nil {
+		return
+	}
+	c.chatLog = chatlog.NewLog(chatlog.DefaultSize)
+
+	request := &jasonsgame.ChatLogRequest{
+		Location: c.locationDid,
+		From:     c.playerDid,
+		ReplyTo:  c.playerDid + whisperTopicSuffix,
+	}
+	err := c.network.Community().Send(chatTopicFor(c.network, c.locationDid), request)
+	if err != nil {
+		c.Log.Warnw("error requesting chat log", "err", err)
+	}
+	if c.locationHandler != nil && c.locationHandler.Supports(request) {
+		err = c.locationHandler.Handle(request)
+		if err != nil {
+			c.Log.Warnw("error requesting chat log from handler", "err", err)
+		}
+	}
+}
+
+func (c *ChatActor) handleSayRequest(msg *SayRequest) error {
+	said := &jasonsgame.ChatMessage{
+		From:     c.playerDid,
+		Name:     c.name,
+		Message:  msg.Message,
+		Emote:    msg.Emote,
+		Location: c.locationDid,
+		SentAt:   time.Now().Unix(),
+	}
+	err := c.send(chatTopicFor(c.network, c.locationDid), said)
+	if err != nil {
+		return err
+	}
+
+	if c.chatLog == nil {
+		return nil
+	}
+	c.chatLog.Add(said)
+
+	// the handler keeps the log for when nobody else is around
+	if c.locationHandler != nil && c.locationHandler.Supports(said) {
+		err = c.locationHandler.Handle(said)
+		if err != nil {
+			c.Log.Warnw("error sending chat to location handler", "err", err)
+		}
+	}
+	return nil
+}
+
+func (c *ChatActor) handleChatLogRequest(msg *jasonsgame.ChatLogRequest) {
+	if msg.Location != c.locationDid || msg.From == c.playerDid || c.chatLog == nil || c.chatLog.Len() == 0 {
+		return
+	}
+
+	err := c.network.Community().Send(c.network.Community().TopicFor(msg.ReplyTo), &jasonsgame.ChatLogResponse{
+		Location: c.locationDid,
+		To:       msg.From,
+		Messages: c.chatLog.Last(0),
+	})
+	if err != nil {
+		c.Log.Warnw("error sending chat log", "err", err)
+	}
+}
+
+// handleChatLogResponse adds what others logged to the player's own log.
+// Each message is verified, since whoever sent the log could have forged it.
+func (c *ChatActor) handleChatLogResponse(msg *jasonsgame.ChatLogResponse) {
+	if msg.To != c.playerDid || msg.Location != c.locationDid || c.chatLog == nil {
+		return
+	}
+
+	for _, logged := range msg.Messages {
+		if logged.Location != c.locationDid {
+			continue
+		}
+		_, verified, err := c.verify(logged)
+		if err != nil || !verified {
+			c.Log.Warnw("ignoring logged chat message that couldn't be verified", "from", logged.From, "err", err)
+			continue
+		}
+		c.chatLog.Add(logged)
+	}
+}
+
+func (c *ChatActor) handleShoutRequest(msg *ShoutRequest) error {
+	shout := &jasonsgame.ShoutMessage{
+		From:     c.playerDid,
+		Name:     c.name,
+		Message:  msg.Message,
+		Location: c.locationDid,
+		SentAt:   time.Now().Unix(),
+	}
+	err := chatlog.Sign(shout, c.network.PrivateKey())
+	if err != nil {
+		return err
+	}
+
+	for _, did := range append([]string{c.locationDid}, msg.Neighbours...) {
+		err = c.network.Community().Send(chatTopicFor(c.network, did), shout)
+		if err != nil {
+			return errors.Wrap(err, "error sending shout")
+		}
+	}
+	return nil
+}
+
+func (c *ChatActor) send(topic []byte, msg proto.Message) error {
+	err := chatlog.Sign(msg, c.network.PrivateKey())
+	if err != nil {
+		return err
+	}
+	return c.network.Community().Send(topic, msg)
+}
+
+// chatSender is who owns a player tree that has sent chat, and their name
+type chatSender struct {
+	auths []string
+	name  string
+}
+
+// receive shows messages from other players once they've been verified,
+// returning true if it was shown. toShow describes the message with the
+// sender's name.
+func (c *ChatActor) receive(actorCtx actor.Context, msg chatlog.Signed, toShow func(name string) string) bool {
+	if msg.GetFrom() == c.playerDid {
+		// the game has already shown the player what they said
+		return false
+	}
+
+	if !c.fresh(msg, time.Now()) {
+		c.Log.Warnw("ignoring old or repeated chat message", "from", msg.GetFrom())
+		return false
+	}
+
+	name, verified, err := c.verify(msg)
+	if err != nil {
+		c.Log.Warnw("error verifying chat message", "from", msg.GetFrom(), "err", err)
+		return false
+	}
+	if !verified {
+		c.Log.Warnw("ignoring chat message with a bad signature", "from", msg.GetFrom())
+		return false
+	}
+
+	if parent := actorCtx.Parent(); parent != nil {
+		actorCtx.Send(parent, &userEvent{message: toShow(name)})
+	}
+	return true
+}
+
+// fresh is false for messages sent more than chatlog.MaxAge from now, or that
+// have already been received. The sent at is signed, so it can't be changed
+// to replay a message later.
+func (c *ChatActor) fresh(msg chatlog.Signed, now time.Time) bool {
+	if !chatlog.Recent(msg, now) {
+		return false
+	}
+
+	for hash, receivedSentAt := range c.received {
+		if now.Sub(time.Unix(receivedSentAt, 0)) > chatlog.MaxAge {
+			delete(c.received, hash)
+		}
+	}
+
+	hash, err := chatlog.SigningHash(msg)
+	if err != nil {
+		return false
+	}
+	if _, ok := c.received[string(hash)]; ok {
+		return false
+	}
+	c.received[string(hash)] = msg.GetSentAt()
+	return true
+}
+
+// verify checks the message was signed by an owner of the sender's tree,
+// returning the name on that tree. Senders are cached, and only looked up
+// again when a signature doesn't match.
+func (c *ChatActor) verify(msg chatlog.Signed) (string, bool, error) {
+	sender, ok := c.senders[msg.GetFrom()]
+	if ok && chatlog.SignedBy(msg, sender.auths) {
+		return sender.name, true, nil
+	}
+
+	tree, err := c.network.GetTree(msg.GetFrom())
+	if err != nil {
+		return "", false, errors.Wrap(err, "error fetching sender")
+	}
+	if tree == nil {
+		return "", false, nil
+	}
+
+	auths, err := tree.Authentications()
+	if err != nil {
+		return "", false, errors.Wrap(err, "error fetching sender auths")
+	}
+
+	sender = &chatSender{auths: auths, name: playerNameOn(c.network, tree)}
+	c.senders[msg.GetFrom()] = sender
+
+	return sender.name, chatlog.SignedBy(msg, auths), nil
+}
+
+// senderNames are the names of whoever sent messages, by did
+func (c *ChatActor) senderNames(messages []*jasonsgame.ChatMessage) map[string]string {
+	names := make(map[string]string, len(messages))
+	for _, msg := range messages {
+		switch sender, ok := c.senders[msg.From]; {
+		case msg.From == c.playerDid:
+			names[msg.From] = c.name
+		case ok:
+			names[msg.From] = sender.name
+		default:
+			names[msg.From] = msg.From
+		}
 	}
+	return names
 }
@@ -0,0 +1,40 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWhisperRecipient(t *testing.T) {
+	names := map[string]string{
+		"did:tupelo:jason":  "jason",
+		"did:tupelo:jason2": "jason the 2",
+	}
+
+	name, did, message := whisperRecipient(names, "jason hello")
+	require.Equal(t, "jason", name)
+	require.Equal(t, "did:tupelo:jason", did)
+	require.Equal(t, "hello", message)
+
+	name, did, message = whisperRecipient(names, "Jason the 2 hi there")
+	require.Equal(t, "jason the 2", name)
+	require.Equal(t, "did:tupelo:jason2", did)
+	require.Equal(t, "hi there", message)
+
+	_, did, _ = whisperRecipient(names, "jasonx hello")
+	require.Equal(t, "", did)
+
+	name, did, message = whisperRecipient(names, "did:tupelo:bob hey")
+	require.Equal(t, "did:tupelo:bob", name)
+	require.Equal(t, "did:tupelo:bob", did)
+	require.Equal(t, "hey", message)
+
+	// players with the same name can only be told apart by did
+	names["did:tupelo:otherjason"] = "Jason"
+	_, did, _ = whisperRecipient(names, "jason hello")
+	require.Equal(t, "", did)
+
+	_, did, _ = whisperRecipient(names, "jason the 2 hello")
+	require.Equal(t, "did:tupelo:jason2", did)
+}
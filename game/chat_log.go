@@ -0,0 +1,112 @@
+package game
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AsynkronIT/protoactor-go/actor"
+	"github.com/pkg/errors"
+)
+
+// defaultChatHistoryLines is how much of the chat log `history` shows
+const defaultChatHistoryLines = 20
+
+func (g *Game) locationChatLogged(locationDid string) bool {
+	tree, err := g.network.GetTree(locationDid)
+	if err != nil || tree == nil {
+		log.Warningf("error fetching location %s for chat log: %v", locationDid, err)
+		return false
+	}
+
+	enabled, err := NewLocationTree(g.network, tree).ChatLogEnabled()
+	if err != nil {
+		log.Warningf("error fetching chat log setting: %v", err)
+		return false
+	}
+	return enabled
+}
+
+// syncChatLogged lets the chat actor know if the owner changed whether the
+// location logs its chat
+func (g *Game) syncChatLogged(actorCtx actor.Context, location *LocationTree) {
+	enabled, err := location.ChatLogEnabled()
+	if err != nil {
+		log.Warningf("error fetching chat log setting: %v", err)
+		return
+	}
+	if enabled == g.chatLogged {
+		return
+	}
+	g.chatLogged = enabled
+	actorCtx.Send(g.chatActor, &SetChatLogged{Logged: enabled})
+}
+
+func (g *Game) handleChatHistory(actorCtx actor.Context, args string) error {
+	lines := defaultChatHistoryLines
+	if linesArg := strings.TrimSpace(args); linesArg != "" {
+		var err error
+		lines, err = strconv.Atoi(linesArg)
+		if err != nil || lines < 1 {
+			g.sendUserMessage(actorCtx, fmt.Sprintf("%s isn't a number of lines, e.g. `history 10`", linesArg))
+			return nil
+		}
+	}
+
+	response, err := actorCtx.RequestFuture(g.chatActor, &GetChatLog{Count: lines}, 5*time.Second).Result()
+	if err != nil {
+		return err
+	}
+	chatLog, ok := response.(*ChatLog)
+	if !ok {
+		return fmt.Errorf("error casting ChatLog")
+	}
+
+	if !chatLog.Logged {
+		g.sendUserMessage(actorCtx, "chat isn't kept here")
+		return nil
+	}
+	if len(chatLog.Messages) == 0 {
+		g.sendUserMessage(actorCtx, "nobody has said anything here yet")
+		return nil
+	}
+
+	toSend := indentedList{"recent chat here:"}
+	for _, msg := range chatLog.Messages {
+		toSend = append(toSend, describeChatMessage(msg, chatLog.Names[msg.From]))
+	}
+	g.sendUserMessage(actorCtx, toSend)
+	return nil
+}
+
+func (g *Game) handleSetChatLog(actorCtx actor.Context, enabled bool) error {
+	location, err := g.currentLocationTree()
+	if err != nil {
+		return err
+	}
+
+	auths, err := g.playerTree.Authentications()
+	if err != nil {
+		return fmt.Errorf("error fetching player authentications")
+	}
+	isOwnedBy, _ := location.IsOwnedBy(auths)
+	if !isOwnedBy {
+		return fmt.Errorf("you can only change the chat log on land you own")
+	}
+
+	err = location.SetChatLogEnabled(enabled)
+	if err != nil {
+		return errors.Wrap(err, "error updating chat log setting")
+	}
+
+	g.chatLogged = enabled
+	actorCtx.Send(g.chatActor, &SetChatLogged{Logged: enabled})
+
+	if enabled {
+		g.sendUserMessage(actorCtx, "recent chat will be kept here, type `history` to see it")
+	} else {
+		g.sendUserMessage(actorCtx, "chat will no longer be kept here")
+	}
+	return nil
+}
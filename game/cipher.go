@@ -0,0 +1,265 @@
+package game
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+const (
+	defaultCipherMaxAttempts = 5
+	defaultCipherLockout     = time.Minute
+	cipherShareLength        = 32
+)
+
+func (l *CipherLimit) maxAttempts() uint32 {
+	if l.GetMaxAttempts() == 0 {
+		return defaultCipherMaxAttempts
+	}
+	return l.GetMaxAttempts()
+}
+
+func (l *CipherLimit) lockout() time.Duration {
+	if l.GetLockoutSeconds() == 0 {
+		return defaultCipherLockout
+	}
+	return time.Duration(l.GetLockoutSeconds()) * time.Second
+}
+
+// Check returns the attempts including this one, or an *InteractionLimitError
+// if the player is locked out. A nil limit uses the defaults, like any unset
+// field.
+func (l *CipherLimit) Check(attempts *InteractionUsage, now time.Time) (*InteractionUsage, error) {
+	lockout := l.lockout()
+
+	if attempts == nil || now.Sub(time.Unix(attempts.WindowStart, 0)) >= lockout {
+		return &InteractionUsage{Count: 1, WindowStart: now.Unix()}, nil
+	}
+
+	if attempts.Count >= l.maxAttempts() {
+		message := l.GetLockoutMessage()
+		if message == "" {
+			remaining := time.Unix(attempts.WindowStart, 0).Add(lockout).Sub(now).Round(time.Second)
+			message = fmt.Sprintf("you've tried too many times, wait %v before trying again", remaining)
+		}
+		return nil, &InteractionLimitError{Message: message}
+	}
+
+	return &InteractionUsage{Count: attempts.Count + 1, WindowStart: attempts.WindowStart}, nil
+}
+
+func NewMultiPartCipherInteraction(command string, fragments []string, interactionToSeal Interaction, failureInteraction Interaction, progressInteraction Interaction) (*MultiPartCipherInteraction, error) {
+	if len(fragments) == 0 {
+		return nil, fmt.Errorf("at least one fragment is required")
+	}
+
+	seen := make(map[string]bool, len(fragments))
+	for _, fragment := range fragments {
+		if seen[fragment] {
+			return nil, fmt.Errorf("fragments must be unique, %s is repeated", fragment)
+		}
+		seen[fragment] = true
+	}
+
+	interaction := &MultiPartCipherInteraction{
+		Command:      command,
+		Salt:         make([]byte, cipherNonceLength),
+		SealedShares: make([][]byte, len(fragments)),
+	}
+	if _, err := io.ReadFull(rand.Reader, interaction.Salt); err != nil {
+		return nil, err
+	}
+
+	shares := make([][]byte, len(fragments))
+	for idx, fragment := range fragments {
+		shares[idx] = make([]byte, cipherShareLength)
+		if _, err := io.ReadFull(rand.Reader, shares[idx]); err != nil {
+			return nil, err
+		}
+
+		fragmentKey, err := cipherKey([]byte(fragment), interaction.Salt)
+		if err != nil {
+			return nil, err
+		}
+
+		interaction.SealedShares[idx], err = sealCipherBytes(shares[idx], &fragmentKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	interactionToSealNode, err := interactionToCborNode(interactionToSeal)
+	if err != nil {
+		return nil, errors.Wrap(err, "interactionToSeal could not be encoded")
+	}
+
+	sharedKey := combineCipherShares(shares)
+	interaction.SealedInteractionBytes, err = sealCipherBytes(interactionToSealNode.RawData(), &sharedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if failureInteraction != nil {
+		failureInteractionNode, err := interactionToCborNode(failureInteraction)
+		if err != nil {
+			return nil, errors.Wrap(err, "failureInteraction could not be encoded")
+		}
+		interaction.FailureInteractionBytes = failureInteractionNode.RawData()
+	}
+
+	if progressInteraction != nil {
+		progressInteractionNode, err := interactionToCborNode(progressInteraction)
+		if err != nil {
+			return nil, errors.Wrap(err, "progressInteraction could not be encoded")
+		}
+		interaction.ProgressInteractionBytes = progressInteractionNode.RawData()
+	}
+
+	return interaction, nil
+}
+
+// MatchFragment returns the index and share of the fragment, or -1 if
+// fragment is not one of them. This runs scrypt, so keep it off of actors.
+func (i *MultiPartCipherInteraction) MatchFragment(fragment string) (int, []byte, error) {
+	fragmentKey, err := cipherKey([]byte(fragment), i.Salt)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	for idx, sealedShare := range i.SealedShares {
+		if share, ok := openCipherBytes(sealedShare, &fragmentKey); ok {
+			return idx, share, nil
+		}
+	}
+	return -1, nil, nil
+}
+
+// Unseal requires the shares for every fragment, in order
+func (i *MultiPartCipherInteraction) Unseal(shares [][]byte) (Interaction, error) {
+	if len(shares) != len(i.SealedShares) {
+		return nil, fmt.Errorf("expected %d shares, got %d", len(i.SealedShares), len(shares))
+	}
+
+	sharedKey := combineCipherShares(shares)
+	unsealedBytes, ok := openCipherBytes(i.SealedInteractionBytes, &sharedKey)
+	if !ok {
+		return nil, fmt.Errorf("shares do not unseal the interaction")
+	}
+
+	interaction, err := interactionFromCborBytes(unsealedBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding interaction")
+	}
+	return interaction, nil
+}
+
+// FailureInteraction returns nil if there is no failure interaction
+func (i *MultiPartCipherInteraction) FailureInteraction() (Interaction, error) {
+	return optionalInteractionFromBytes(i.FailureInteractionBytes)
+}
+
+// ProgressInteraction returns nil if there is no progress interaction
+func (i *MultiPartCipherInteraction) ProgressInteraction() (Interaction, error) {
+	return optionalInteractionFromBytes(i.ProgressInteractionBytes)
+}
+
+func optionalInteractionFromBytes(interactionBytes []byte) (Interaction, error) {
+	if len(interactionBytes) == 0 {
+		return nil, nil
+	}
+	interaction, err := interactionFromCborBytes(interactionBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding interaction")
+	}
+	return interaction, nil
+}
+
+// cipherProgressKey derives the key a player's progress on a multi-part
+// cipher is sealed with, since player trees can be read by anyone
+func cipherProgressKey(privateKey *ecdsa.PrivateKey, did string, command string) [32]byte {
+	return sha256.Sum256(append(crypto.FromECDSA(privateKey), []byte(did+"/"+command)...))
+}
+
+// openCipherProgress returns one entry per share, nil for shares not yet found
+func openCipherProgress(key *[32]byte, progress *CipherProgress, shareCount int) ([][]byte, error) {
+	shares := make([][]byte, shareCount)
+	if progress == nil {
+		return shares, nil
+	}
+	if len(progress.Shares) != shareCount {
+		// the cipher has been replaced since this progress was made
+		return shares, nil
+	}
+
+	for idx, sealedShare := range progress.Shares {
+		if len(sealedShare) == 0 {
+			continue
+		}
+		share, ok := openCipherBytes(sealedShare, key)
+		if !ok {
+			return nil, fmt.Errorf("could not open cipher progress")
+		}
+		shares[idx] = share
+	}
+	return shares, nil
+}
+
+func sealCipherProgress(key *[32]byte, shares [][]byte) (*CipherProgress, error) {
+	progress := &CipherProgress{Shares: make([][]byte, len(shares))}
+	for idx, share := range shares {
+		if len(share) == 0 {
+			continue
+		}
+		sealedShare, err := sealCipherBytes(share, key)
+		if err != nil {
+			return nil, err
+		}
+		progress.Shares[idx] = sealedShare
+	}
+	return progress, nil
+}
+
+func countCipherShares(shares [][]byte) int {
+	found := 0
+	for _, share := range shares {
+		if len(share) > 0 {
+			found++
+		}
+	}
+	return found
+}
+
+func combineCipherShares(shares [][]byte) (key [32]byte) {
+	for _, share := range shares {
+		for idx := range key {
+			if idx < len(share) {
+				key[idx] ^= share[idx]
+			}
+		}
+	}
+	return key
+}
+
+func sealCipherBytes(data []byte, key *[32]byte) ([]byte, error) {
+	var nonce [cipherNonceLength]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return nil, err
+	}
+	return secretbox.Seal(nonce[:], data, &nonce, key), nil
+}
+
+func openCipherBytes(sealed []byte, key *[32]byte) ([]byte, bool) {
+	if len(sealed) < cipherNonceLength {
+		return nil, false
+	}
+	var nonce [cipherNonceLength]byte
+	copy(nonce[:], sealed[:cipherNonceLength])
+	return secretbox.Open(nil, sealed[cipherNonceLength:], &nonce, key)
+}
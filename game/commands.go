@@ -1,29 +1,103 @@
 package game
 
 import (
+	"fmt"
 	"strings"
+	"unicode"
 )
 
 type commandList []command
 
 var defaultCommandList = commandList{
 	newCommand("create-object", "create object"),
-	newCommand("player-inventory-list", "look in bag"),
+	newCommand("player-inventory-list", "look in bag", "i", "inventory"),
 	newCommand("transfer-object", "transfer object"),
 	newCommand("receive-object", "receive object"),
+	newCommand("say", "say"),
+	newCommand("emote", "/me", "emote"),
+	newCommand("shout", "shout"),
+	newCommand("whisper", "whisper"),
+	newCommand("chat-history", "history"),
+	newCommand("inbox", "inbox"),
+	newCommand("read-mail", "read mail"),
+	newCommand("reply-mail", "reply"),
+	newCommand("delete-mail", "delete mail"),
+	newCommand("send-mail", "mail"),
+	newCommand("request-portal", "request portal to", "request portal"),
+	newCommand("portal-requests", "portal requests"),
+	newCommand("accept-portal-request", "accept portal request"),
+	newCommand("decline-portal-request", "decline portal request"),
+	newCommand("unlock", "unlock"),
+	newCommand("build", "build"),
+	newCommand("map", "map"),
+	newCommand("go-to", "go to"),
+	newCommand("who", "who"),
+	newCommand("journal", "journal"),
+	newCommand("bookmark", "bookmark"),
+	newCommand("bookmark-list", "bookmarks"),
+	newCommand("recall", "recall"),
+	newCommand("quest-list", "quests"),
+	newCommand("quest-details", "quest"),
+	newCommand("abandon-quest", "abandon quest"),
 	newCommand("help", "help"),
 	newCommand("help", "help location"),
 	newCommand("help", "help [name of object]"),
-	//  newHiddenCommand("tip-zoom", "go to tip"),
+	newCommand("history-here", "history here"),
+	newCommand("view-here", "view here at"),
+	newCommand("stop-viewing", "stop viewing"),
+	newHiddenCommand("tip-zoom", "zoom to tip"),
 	newHiddenCommand("create-location", "create location"),
 	newHiddenCommand("connect-location", "connect location"),
+	newHiddenCommand("list-interactions", "list interactions"),
+	newHiddenCommand("remove-interaction", "remove interaction"),
+	newHiddenCommand("edit-interaction", "edit interaction"),
+	newHiddenCommand("rename-interaction", "rename interaction"),
+	newHiddenCommand("enable-chat-log", "enable chat log"),
+	newHiddenCommand("disable-chat-log", "disable chat log"),
+	newHiddenCommand("make-private", "make private"),
+	newHiddenCommand("make-public", "make public"),
+	newHiddenCommand("lock-with", "lock with"),
+	newHiddenCommand("allow-player", "allow player"),
+	newHiddenCommand("disallow-player", "disallow player"),
+	newHiddenCommand("ban-player", "ban player"),
+	newHiddenCommand("unban-player", "unban player"),
+	newHiddenCommand("show-access", "who can come here"),
+	newHiddenCommand("stop-building", "stop building"),
+	newHiddenCommand("describe-here", "describe here"),
+	newHiddenCommand("add-exit", "add exit"),
+	newHiddenCommand("add-response", "add response"),
+	newHiddenCommand("create-object-here", "create object here"),
+	newHiddenCommand("set-property", "set property"),
+	newHiddenCommand("confirm-build", "confirm"),
+	newHiddenCommand("cancel-build", "cancel"),
+	newHiddenCommand("undo-build", "undo"),
+	newHiddenCommand("grant-builder", "grant builder"),
+	newHiddenCommand("revoke-builder", "revoke builder"),
+	newHiddenCommand("list-builders", "builders"),
 	newHiddenCommand("exit", "exit"),
 	newHiddenCommand("refresh", "refresh"),
 }
 
+// interactionAliases are shorthands for common interaction commands,
+// since interactions are loaded from trees and can't declare their own
+var interactionAliases = map[string][]string{
+	"north":       {"n"},
+	"south":       {"s"},
+	"east":        {"e"},
+	"west":        {"w"},
+	"northeast":   {"ne"},
+	"northwest":   {"nw"},
+	"southeast":   {"se"},
+	"southwest":   {"sw"},
+	"up":          {"u"},
+	"down":        {"d"},
+	"look around": {"l"},
+}
+
 type command interface {
 	Name() string
 	Parse() string
+	Aliases() []string
 	Hidden() bool
 	HelpGroup() string
 }
@@ -32,6 +106,7 @@ type basicCommand struct {
 	command
 	name      string
 	parse     string
+	aliases   []string
 	hidden    bool
 	helpGroup string
 }
@@ -44,6 +119,10 @@ func (c *basicCommand) Parse() string {
 	return c.parse
 }
 
+func (c *basicCommand) Aliases() []string {
+	return c.aliases
+}
+
 func (c *basicCommand) Hidden() bool {
 	return c.hidden
 }
@@ -52,15 +131,16 @@ func (c *basicCommand) HelpGroup() string {
 	return c.helpGroup
 }
 
-func newCommand(name, parse string) *basicCommand {
+func newCommand(name, parse string, aliases ...string) *basicCommand {
 	return &basicCommand{
-		name:  name,
-		parse: parse,
+		name:    name,
+		parse:   parse,
+		aliases: aliases,
 	}
 }
 
-func newHiddenCommand(name, parse string) *basicCommand {
-	c := newCommand(name, parse)
+func newHiddenCommand(name, parse string, aliases ...string) *basicCommand {
+	c := newCommand(name, parse, aliases...)
 	c.hidden = true
 	return c
 }
@@ -80,6 +160,10 @@ func (c *interactionCommand) Parse() string {
 	return c.parse
 }
 
+func (c *interactionCommand) Aliases() []string {
+	return interactionAliases[strings.ToLower(strings.Join(strings.Fields(c.parse), " "))]
+}
+
 func (c *interactionCommand) Hidden() bool {
 	return c.interaction.GetHidden()
 }
@@ -88,11 +172,171 @@ func (c *interactionCommand) HelpGroup() string {
 	return c.helpGroup
 }
 
-func (cl commandList) findCommand(req string) (command, string) {
+// ambiguousCommandError is returned by findCommand when the input could
+// refer to more than one command, or only partially names a command
+type ambiguousCommandError struct {
+	candidates []string
+}
+
+func (e *ambiguousCommandError) Error() string {
+	return fmt.Sprintf("did you mean: %s?", strings.Join(e.candidates, ", "))
+}
+
+func (e *ambiguousCommandError) Candidates() []string {
+	return e.candidates
+}
+
+type token struct {
+	text string
+	// end is the offset in the original input directly after this token
+	end int
+}
+
+func tokenize(input string) []token {
+	tokens := []token{}
+	start := -1
+	for i, r := range input {
+		if unicode.IsSpace(r) {
+			if start >= 0 {
+				tokens = append(tokens, token{text: input[start:i], end: i})
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{text: input[start:], end: len(input)})
+	}
+	return tokens
+}
+
+func tokensHavePrefix(tokens []token, prefix []token) bool {
+	if len(prefix) == 0 || len(prefix) > len(tokens) {
+		return false
+	}
+	for i, t := range prefix {
+		if !strings.EqualFold(tokens[i].text, t.text) {
+			return false
+		}
+	}
+	return true
+}
+
+// tokensPartiallyMatch returns true when the input tokens could be the start
+// of the phrase, allowing the last input token to be an unfinished word
+func tokensPartiallyMatch(tokens []token, phrase []token) bool {
+	if len(tokens) == 0 || len(tokens) > len(phrase) {
+		return false
+	}
+	last := len(tokens) - 1
+	for i := 0; i < last; i++ {
+		if !strings.EqualFold(tokens[i].text, phrase[i].text) {
+			return false
+		}
+	}
+	return strings.HasPrefix(strings.ToLower(phrase[last].text), strings.ToLower(tokens[last].text))
+}
+
+type commandMatch struct {
+	command command
+	length  int
+	alias   bool
+}
+
+func (m *commandMatch) betterThan(other *commandMatch) bool {
+	if m.length != other.length {
+		return m.length > other.length
+	}
+	return !m.alias && other.alias
+}
+
+// findCommand matches the input against each command's parse phrase and
+// aliases word by word, preferring the longest match. When the input matches
+// more than one distinct command equally well, or only partially matches
+// commands, an ambiguousCommandError listing the candidates is returned.
+// Commands sharing the same parse phrase resolve to the first in the list,
+// so later commands can't shadow earlier ones.
+func (cl commandList) findCommand(req string) (command, string, error) {
+	tokens := tokenize(req)
+	if len(tokens) == 0 {
+		return nil, "", nil
+	}
+
+	var best []*commandMatch
+	for _, comm := range cl {
+		match := matchCommand(comm, tokens)
+		if match == nil {
+			continue
+		}
+		if len(best) == 0 || match.betterThan(best[0]) {
+			best = []*commandMatch{match}
+			continue
+		}
+		if !best[0].betterThan(match) {
+			best = append(best, match)
+		}
+	}
+
+	if len(best) > 0 {
+		candidates := distinctParses(best)
+		if len(candidates) > 1 {
+			return nil, "", &ambiguousCommandError{candidates: candidates}
+		}
+		return best[0].command, strings.TrimSpace(req[tokens[best[0].length-1].end:]), nil
+	}
+
+	partials := []*commandMatch{}
 	for _, comm := range cl {
-		if strings.HasPrefix(req, comm.Parse()) {
-			return comm, strings.TrimSpace(strings.TrimPrefix(req, comm.Parse()))
+		if comm.Hidden() {
+			continue
+		}
+		if tokensPartiallyMatch(tokens, tokenize(comm.Parse())) {
+			partials = append(partials, &commandMatch{command: comm})
+		}
+	}
+
+	if len(partials) > 0 {
+		return nil, "", &ambiguousCommandError{candidates: distinctParses(partials)}
+	}
+
+	return nil, "", nil
+}
+
+func matchCommand(comm command, tokens []token) *commandMatch {
+	var best *commandMatch
+
+	phrases := append([]string{comm.Parse()}, comm.Aliases()...)
+	for i, phrase := range phrases {
+		phraseTokens := tokenize(phrase)
+		if !tokensHavePrefix(tokens, phraseTokens) {
+			continue
+		}
+		match := &commandMatch{
+			command: comm,
+			length:  len(phraseTokens),
+			alias:   i > 0,
+		}
+		if best == nil || match.betterThan(best) {
+			best = match
+		}
+	}
+
+	return best
+}
+
+func distinctParses(matches []*commandMatch) []string {
+	parses := []string{}
+	seen := make(map[string]bool)
+	for _, m := range matches {
+		parse := m.command.Parse()
+		if seen[parse] {
+			continue
 		}
+		seen[parse] = true
+		parses = append(parses, parse)
 	}
-	return nil, ""
+	return parses
 }
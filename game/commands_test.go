@@ -7,6 +7,88 @@ import (
 )
 
 func TestCommandList(t *testing.T) {
-	comm, _ := defaultCommandList.findCommand("help")
+	comm, _, err := defaultCommandList.findCommand("help")
+	require.Nil(t, err)
 	require.NotNil(t, comm)
 }
+
+func TestFindCommandWholeWords(t *testing.T) {
+	cl := commandList{
+		&interactionCommand{parse: "look at bowl", interaction: &RespondInteraction{Command: "look at bowl"}},
+		&interactionCommand{parse: "look at bowling ball", interaction: &RespondInteraction{Command: "look at bowling ball"}},
+	}
+
+	comm, args, err := cl.findCommand("look at bowling ball")
+	require.Nil(t, err)
+	require.Equal(t, "look at bowling ball", comm.Parse())
+	require.Empty(t, args)
+
+	comm, args, err = cl.findCommand("look  at bowl closely")
+	require.Nil(t, err)
+	require.Equal(t, "look at bowl", comm.Parse())
+	require.Equal(t, "closely", args)
+
+	comm, _, err = cl.findCommand("look at bowlingball")
+	require.Nil(t, comm)
+	require.Nil(t, err)
+}
+
+func TestFindCommandPrefersLongestMatch(t *testing.T) {
+	comm, args, err := defaultCommandList.findCommand("help location")
+	require.Nil(t, err)
+	require.Equal(t, "help location", comm.Parse())
+	require.Empty(t, args)
+
+	comm, args, err = defaultCommandList.findCommand("help sword of Truth")
+	require.Nil(t, err)
+	require.Equal(t, "help", comm.Parse())
+	require.Equal(t, "sword of Truth", args)
+}
+
+func TestFindCommandAliases(t *testing.T) {
+	cl := append(commandList{
+		&interactionCommand{parse: "north", interaction: &ChangeLocationInteraction{Command: "north"}},
+		&interactionCommand{parse: "look around", interaction: &LookAroundInteraction{}},
+	}, defaultCommandList...)
+
+	comm, _, err := cl.findCommand("n")
+	require.Nil(t, err)
+	require.Equal(t, "north", comm.Parse())
+
+	comm, _, err = cl.findCommand("L")
+	require.Nil(t, err)
+	require.Equal(t, "look around", comm.Parse())
+
+	comm, _, err = cl.findCommand("i")
+	require.Nil(t, err)
+	require.Equal(t, "player-inventory-list", comm.Name())
+}
+
+func TestFindCommandDisambiguation(t *testing.T) {
+	cl := commandList{
+		&interactionCommand{parse: "look at bowl", interaction: &RespondInteraction{Command: "look at bowl"}},
+		&interactionCommand{parse: "look at bowling ball", interaction: &RespondInteraction{Command: "look at bowling ball"}},
+		&interactionCommand{parse: "look at secret", interaction: &RespondInteraction{Command: "look at secret", Hidden: true}},
+		newCommand("first", "sing", "croon"),
+		newCommand("second", "hum", "croon"),
+	}
+
+	_, _, err := cl.findCommand("look at bow")
+	require.NotNil(t, err)
+	require.Equal(t, []string{"look at bowl", "look at bowling ball"}, err.(*ambiguousCommandError).Candidates())
+
+	_, _, err = cl.findCommand("croon")
+	require.NotNil(t, err)
+	require.Equal(t, []string{"sing", "hum"}, err.(*ambiguousCommandError).Candidates())
+}
+
+func TestFindCommandSameParseResolvesToFirst(t *testing.T) {
+	cl := commandList{
+		&interactionCommand{parse: "look around", interaction: &RespondInteraction{Command: "look around", Response: "custom"}},
+		&interactionCommand{parse: "look around", interaction: &LookAroundInteraction{}},
+	}
+
+	comm, _, err := cl.findCommand("look around")
+	require.Nil(t, err)
+	require.IsType(t, &RespondInteraction{}, comm.(*interactionCommand).interaction)
+}
@@ -0,0 +1,85 @@
+package game
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/AsynkronIT/protoactor-go/actor"
+	"github.com/pkg/errors"
+	"github.com/quorumcontrol/tupelo-go-sdk/consensus"
+
+	"github.com/quorumcontrol/jasons-game/game/trees"
+)
+
+// checkCondition returns true when every part of the condition that is set
+// holds for the current player
+func (g *Game) checkCondition(actorCtx actor.Context, condition *InteractionCondition) (bool, error) {
+	if condition == nil {
+		return true, nil
+	}
+
+	if condition.InventoryContains != "" {
+		inventoryList, err := g.getInventoryList(actorCtx, g.inventoryActor)
+		if err != nil {
+			return false, errors.Wrap(err, "error getting player inventory list")
+		}
+		if _, ok := inventoryList.Objects[condition.InventoryContains]; !ok {
+			return false, nil
+		}
+	}
+
+	if condition.TreeDid != "" {
+		met, err := g.checkTreeValueCondition(condition)
+		if err != nil || !met {
+			return false, err
+		}
+	}
+
+	if condition.OwnsDid != "" {
+		tree, err := g.network.GetTree(condition.OwnsDid)
+		if err != nil {
+			return false, errors.Wrap(err, "error fetching tree")
+		}
+		if tree == nil {
+			return false, nil
+		}
+
+		auths, err := g.playerTree.Authentications()
+		if err != nil {
+			return false, errors.Wrap(err, "error fetching player authentications")
+		}
+
+		isOwnedBy, err := trees.VerifyOwnership(context.Background(), tree.ChainTree, auths)
+		if err != nil || !isOwnedBy {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func (g *Game) checkTreeValueCondition(condition *InteractionCondition) (bool, error) {
+	tree, err := g.network.GetTree(condition.TreeDid)
+	if err != nil {
+		return false, errors.Wrap(err, "error fetching tree")
+	}
+	if tree == nil {
+		return false, fmt.Errorf("could not find tree with did %v", condition.TreeDid)
+	}
+
+	pathSlice, err := consensus.DecodePath(condition.TreePath)
+	if err != nil {
+		return false, errors.Wrap(err, "error casting path")
+	}
+
+	value, _, err := tree.ChainTree.Dag.Resolve(context.Background(), append([]string{"tree", "data", "jasons-game"}, pathSlice...))
+	if err != nil {
+		return false, errors.Wrap(err, fmt.Sprintf("error fetching value for %v", condition.TreePath))
+	}
+
+	if value == nil {
+		return condition.TreeValue == "", nil
+	}
+
+	return fmt.Sprintf("%v", value) == condition.TreeValue, nil
+}
@@ -0,0 +1,218 @@
+package game
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/AsynkronIT/protoactor-go/actor"
+	"github.com/pkg/errors"
+)
+
+// activeDialogue is the conversation the player is currently having, so that
+// `say N` knows what is being answered. Progress through each dialogue is
+// persisted on the player tree, this only lives for the session.
+type activeDialogue struct {
+	command  *interactionCommand
+	dialogue *DialogueInteraction
+	did      string
+	nodeID   string
+}
+
+func NewDialogueNode(id string, text string, interaction Interaction, choices ...*DialogueChoice) (*DialogueNode, error) {
+	node := &DialogueNode{
+		Id:      id,
+		Text:    text,
+		Choices: choices,
+	}
+
+	if interaction != nil {
+		interactionNode, err := interactionToCborNode(interaction)
+		if err != nil {
+			return nil, errors.Wrap(err, "interaction could not be encoded")
+		}
+		node.InteractionBytes = interactionNode.RawData()
+	}
+
+	return node, nil
+}
+
+func NewDialogueInteraction(command string, start string, nodes ...*DialogueNode) (*DialogueInteraction, error) {
+	dialogue := &DialogueInteraction{
+		Command: command,
+		Start:   start,
+		Nodes:   nodes,
+	}
+	return dialogue, dialogue.Validate()
+}
+
+// Validate checks that the dialogue starts and only leads to nodes that exist
+func (i *DialogueInteraction) Validate() error {
+	ids := make(map[string]bool, len(i.Nodes))
+	for _, node := range i.Nodes {
+		if node.Id == "" {
+			return fmt.Errorf("dialogue nodes must have an id")
+		}
+		if ids[node.Id] {
+			return fmt.Errorf("dialogue node %s is defined more than once", node.Id)
+		}
+		ids[node.Id] = true
+	}
+
+	if !ids[i.Start] {
+		return fmt.Errorf("dialogue start node %s does not exist", i.Start)
+	}
+
+	for _, node := range i.Nodes {
+		for _, choice := range node.Choices {
+			if choice.Next != "" && !ids[choice.Next] {
+				return fmt.Errorf("dialogue node %s leads to %s which does not exist", node.Id, choice.Next)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Node returns nil if there is no node with id
+func (i *DialogueInteraction) Node(id string) *DialogueNode {
+	for _, node := range i.Nodes {
+		if node.Id == id {
+			return node
+		}
+	}
+	return nil
+}
+
+// Interaction returns nil if the node doesn't fire an interaction
+func (n *DialogueNode) Interaction() (Interaction, error) {
+	if len(n.InteractionBytes) == 0 {
+		return nil, nil
+	}
+	interaction, err := interactionFromCborBytes(n.InteractionBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding interaction")
+	}
+	return interaction, nil
+}
+
+func formatDialogueNode(node *DialogueNode) interface{} {
+	if len(node.Choices) == 0 {
+		return node.Text
+	}
+
+	toSend := indentedList{node.Text}
+	for i, choice := range node.Choices {
+		toSend = append(toSend, fmt.Sprintf("%d. %s", i+1, choice.Text))
+	}
+	return toSend
+}
+
+func (g *Game) startDialogue(actorCtx actor.Context, cmd *interactionCommand, dialogue *DialogueInteraction) error {
+	did := cmd.did
+	if did == "" {
+		did = g.locationDid
+	}
+
+	nodeID, err := g.playerTree.DialogueNode(did, dialogue.GetCommand())
+	if err != nil {
+		return errors.Wrap(err, "error fetching dialogue progress")
+	}
+
+	// resume where the player left off, unless the dialogue has since changed
+	node := dialogue.Node(nodeID)
+	resuming := node != nil
+	if !resuming {
+		node = dialogue.Node(dialogue.Start)
+	}
+	if node == nil {
+		return fmt.Errorf("dialogue start node %s does not exist", dialogue.Start)
+	}
+
+	g.dialogue = &activeDialogue{
+		command:  cmd,
+		dialogue: dialogue,
+		did:      did,
+	}
+
+	return g.enterDialogueNode(actorCtx, node, !resuming)
+}
+
+func (g *Game) handleDialogueChoice(actorCtx actor.Context, args string) error {
+	if g.dialogue == nil {
+		g.sendUserMessage(actorCtx, "you aren't talking to anyone")
+		return nil
+	}
+
+	node := g.dialogue.dialogue.Node(g.dialogue.nodeID)
+	if node == nil {
+		g.dialogue = nil
+		return fmt.Errorf("this conversation has changed, try talking again")
+	}
+
+	choiceNum, err := strconv.Atoi(strings.TrimSpace(args))
+	if err != nil || choiceNum < 1 || choiceNum > len(node.Choices) {
+		return fmt.Errorf("choose one of the numbered responses, like: say 1")
+	}
+
+	next := node.Choices[choiceNum-1].Next
+	if next == "" {
+		return g.endDialogue(actorCtx)
+	}
+
+	nextNode := g.dialogue.dialogue.Node(next)
+	if nextNode == nil {
+		g.dialogue = nil
+		return fmt.Errorf("this conversation has changed, try talking again")
+	}
+
+	return g.enterDialogueNode(actorCtx, nextNode, true)
+}
+
+func (g *Game) enterDialogueNode(actorCtx actor.Context, node *DialogueNode, fireInteraction bool) error {
+	active := g.dialogue
+	active.nodeID = node.Id
+
+	g.sendUserMessage(actorCtx, formatDialogueNode(node))
+
+	var err error
+	if len(node.Choices) == 0 {
+		// nowhere left to go, so the next conversation starts from the beginning
+		err = g.playerTree.SetDialogueNode(active.did, active.dialogue.GetCommand(), "")
+		g.dialogue = nil
+	} else {
+		err = g.playerTree.SetDialogueNode(active.did, active.dialogue.GetCommand(), node.Id)
+	}
+	if err != nil {
+		return errors.Wrap(err, "error saving dialogue progress")
+	}
+
+	if !fireInteraction {
+		return nil
+	}
+
+	interaction, err := node.Interaction()
+	if err != nil || interaction == nil {
+		return err
+	}
+
+	return g.handleInteractionInput(actorCtx, &interactionCommand{
+		parse:       active.command.parse,
+		interaction: interaction,
+		helpGroup:   active.command.helpGroup,
+		did:         active.command.did,
+	}, "")
+}
+
+func (g *Game) endDialogue(actorCtx actor.Context) error {
+	active := g.dialogue
+	g.dialogue = nil
+
+	err := g.playerTree.SetDialogueNode(active.did, active.dialogue.GetCommand(), "")
+	if err != nil {
+		return errors.Wrap(err, "error saving dialogue progress")
+	}
+
+	g.sendUserMessage(actorCtx, "the conversation is over")
+	return nil
+}
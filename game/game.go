@@ -10,7 +10,6 @@ import (
 	"time"
 
 	"github.com/AsynkronIT/protoactor-go/actor"
-	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
 	logging "github.com/ipfs/go-log"
 	"github.com/pkg/errors"
@@ -20,6 +19,7 @@ import (
 
 	"github.com/quorumcontrol/jasons-game/cache"
 	"github.com/quorumcontrol/jasons-game/config"
+	"github.com/quorumcontrol/jasons-game/game/mail"
 	"github.com/quorumcontrol/jasons-game/game/static"
 	"github.com/quorumcontrol/jasons-game/inkfaucet/inkfaucet"
 	"github.com/quorumcontrol/jasons-game/inkfaucet/invites"
@@ -53,6 +53,41 @@ type Game struct {
 	inkDID               string
 	invitesActor         *actor.PID
 	ds                   datastore.Batching
+	dialogue             *activeDialogue
+	worldMap             *worldMap
+	viewing              *historyView
+	locationChanges      indentedList
+	locationSnapshot     *locationSnapshot
+	presenceActor        *actor.PID
+	chatActor            *actor.PID
+	chatLogged           bool
+	mailActor            *actor.PID
+	inbox                []*Letter
+	portalRequestActor   *actor.PID
+	// locked is the last password protected location the player was kept out of
+	locked string
+	// unlocked is the password hash each location was unlocked with
+	unlocked     map[string]string
+	building     bool
+	pendingBuild *pendingBuild
+	lastBuild    *lastBuild
+	prices       BuildPrices
+	inkSinkDID   string
+	mailClient   *mail.Client
+	// quests are the quests the player has started, by did
+	quests map[string]*cachedQuest
+	// unsealing are the ciphers, by did and command, with a guess still being
+	// checked
+	unsealing map[string]bool
+	// seenLocation is the latest version of the location the player has been
+	// told about, written to their journal when they leave
+	seenLocation *LocationTree
+	// ownObjectMoves are objects the player dropped (true) or picked up
+	// (false) that the location hasn't caught up with yet
+	ownObjectMoves map[string]bool
+	// accessHandlers are the handlers deciding who can enter each location,
+	// by location did
+	accessHandlers map[string]*accessHandler
 }
 
 type GameConfig struct {
@@ -61,6 +96,13 @@ type GameConfig struct {
 	Network    network.Network
 	InkDID     string
 	DataStore  datastore.Batching
+	// InkSinkDID is where ink paid for building goes, see BuildPrices. Its
+	// handler receives the ink, see inksink.InkSinkHandler.
+	InkSinkDID string
+	// BuildPrices defaults to DefaultBuildPrices
+	BuildPrices BuildPrices
+	// MailClient defaults to one for the Mailbox service, see mail.NewClient
+	MailClient *mail.Client
 }
 
 type StateChange struct {
@@ -71,13 +113,25 @@ var lastLocationKey = datastore.NewKey("last-location")
 
 func NewGameProps(cfg *GameConfig) *actor.Props {
 	g := &Game{
-		ui:         cfg.UiActor,
-		network:    cfg.Network,
-		commands:   defaultCommandList,
-		playerTree: cfg.PlayerTree,
-		behavior:   actor.NewBehavior(),
-		inkDID:     cfg.InkDID,
-		ds:         cfg.DataStore,
+		ui:             cfg.UiActor,
+		network:        cfg.Network,
+		commands:       defaultCommandsFor(false),
+		playerTree:     cfg.PlayerTree,
+		behavior:       actor.NewBehavior(),
+		inkDID:         cfg.InkDID,
+		ds:             cfg.DataStore,
+		unlocked:       make(map[string]string),
+		unsealing:      make(map[string]bool),
+		quests:         make(map[string]*cachedQuest),
+		ownObjectMoves: make(map[string]bool),
+		accessHandlers: make(map[string]*accessHandler),
+		prices:         cfg.BuildPrices,
+		inkSinkDID:     cfg.InkSinkDID,
+		mailClient:     cfg.MailClient,
+	}
+
+	if g.prices == nil {
+		g.prices = DefaultBuildPrices
 	}
 
 	if g.ds == nil {
@@ -137,6 +191,10 @@ func (g *Game) ReceiveGame(actorCtx actor.Context) {
 	case *StateChange:
 		log.Debugf("actor received state change message: %+v", msg)
 		g.handleStateChange(actorCtx, msg)
+	case *interactionContinuation:
+		g.handleInteractionContinuation(actorCtx, msg)
+	case *userEvent:
+		g.sendUserMessage(actorCtx, msg.message)
 	case *ping:
 		actorCtx.Respond(true)
 	case *actor.Terminated:
@@ -210,7 +268,35 @@ func (g *Game) initializeGame(actorCtx actor.Context) {
 		panic(errors.Wrap(err, "error attaching interactions for inventory"))
 	}
 
-	g.setLocation(actorCtx, g.getDefaultLocation())
+	err = g.publishEncryptionKey()
+	if err != nil {
+		log.Warningf("error publishing encryption key, mail can't be sent to this player: %v", err)
+	}
+	g.mailActor = actorCtx.Spawn(NewMailActorProps(&MailActorConfig{
+		Network:   g.network,
+		PlayerDid: g.playerTree.Did(),
+		Client:    g.newMailClient(),
+		DataStore: g.ds,
+	}))
+
+	g.portalRequestActor = actorCtx.Spawn(NewPortalRequestActorProps(&PortalRequestActorConfig{
+		Network:   g.network,
+		PlayerDid: g.playerTree.Did(),
+		DataStore: g.ds,
+	}))
+
+	g.chatActor = actorCtx.Spawn(NewChatActorProps(&ChatActorConfig{
+		Network:   g.network,
+		PlayerDid: g.playerTree.Did(),
+		Name:      g.playerName(),
+	}))
+
+	locationDid := g.getDefaultLocation()
+	if refusal, err := g.checkAccess(actorCtx, locationDid); err != nil || refusal != "" {
+		// the owner changed who can go there since the player left
+		locationDid = g.playerTree.HomeLocation.MustId()
+	}
+	g.setLocation(actorCtx, locationDid)
 
 	g.sendUserMessage(actorCtx, fmt.Sprintf("Welcome Player %s", g.playerTree.Did()))
 
@@ -225,6 +311,7 @@ func (g *Game) initializeGame(actorCtx actor.Context) {
 	}
 
 	g.sendUserMessage(actorCtx, l)
+	g.sendLocationChanges(actorCtx)
 }
 
 // Try to get the last visited location, else go
@@ -312,19 +399,33 @@ func (g *Game) handleInvitationInput(actorCtx actor.Context, input *jasonsgame.U
 func (g *Game) handleUserInput(actorCtx actor.Context, input *jasonsgame.UserInput) {
 	g.acknowledgeReceipt(actorCtx)
 
-	cmd, args := g.commands.findCommand(input.Message)
+	cmd, args, err := g.commands.findCommand(input.Message)
+	if ambiguousErr, ok := err.(*ambiguousCommandError); ok {
+		g.sendUserMessage(actorCtx, append(indentedList{"did you mean:"}, ambiguousErr.Candidates()...))
+		return
+	}
 	if cmd == nil {
 		g.sendUserMessage(actorCtx, "I'm sorry I don't understand.")
 		return
 	}
 
-	var err error
+	if g.viewing != nil && !historyViewCommands[cmd.Name()] {
+		g.sendUserMessage(actorCtx, "you can't do that while viewing the past, type `stop viewing` to come back to the present")
+		return
+	}
+
 	log.Debugf("received command %v", cmd.Name())
 	switch cmd.Name() {
 	case "exit":
 		g.sendUserMessage(actorCtx, "exit is unsupported in the browser")
 	case "tip-zoom":
 		err = g.handleTipZoom(actorCtx, args)
+	case "history-here":
+		err = g.handleHistoryHere(actorCtx)
+	case "view-here":
+		err = g.handleViewHere(actorCtx, args)
+	case "stop-viewing":
+		err = g.handleStopViewing(actorCtx)
 	case "refresh":
 		err = g.refreshAllInteractions(actorCtx)
 		g.sendUILocation(actorCtx)
@@ -338,6 +439,110 @@ func (g *Game) handleUserInput(actorCtx actor.Context, input *jasonsgame.UserInp
 		err = g.handleCreateLocation(actorCtx, args)
 	case "connect-location":
 		err = g.handleConnectLocation(actorCtx, args)
+	case "list-interactions":
+		err = g.handleListLocationInteractions(actorCtx)
+	case "remove-interaction":
+		err = g.handleRemoveLocationInteraction(actorCtx, args)
+	case "edit-interaction":
+		err = g.handleEditLocationInteraction(actorCtx, args)
+	case "rename-interaction":
+		err = g.handleRenameLocationInteraction(actorCtx, args)
+	case "say":
+		err = g.handleSay(actorCtx, args)
+	case "emote":
+		err = g.handleEmote(actorCtx, args)
+	case "shout":
+		err = g.handleShout(actorCtx, args)
+	case "whisper":
+		err = g.handleWhisper(actorCtx, args)
+	case "inbox":
+		err = g.handleInbox(actorCtx)
+	case "read-mail":
+		err = g.handleReadMail(actorCtx, args)
+	case "reply-mail":
+		err = g.handleReplyMail(actorCtx, args)
+	case "delete-mail":
+		err = g.handleDeleteMail(actorCtx, args)
+	case "send-mail":
+		err = g.handleSendMail(actorCtx, args)
+	case "request-portal":
+		err = g.handleRequestPortal(actorCtx, args)
+	case "portal-requests":
+		err = g.handlePortalRequests(actorCtx)
+	case "accept-portal-request":
+		err = g.handleAnswerPortalRequest(actorCtx, args, true)
+	case "decline-portal-request":
+		err = g.handleAnswerPortalRequest(actorCtx, args, false)
+	case "chat-history":
+		err = g.handleChatHistory(actorCtx, args)
+	case "enable-chat-log":
+		err = g.handleSetChatLog(actorCtx, true)
+	case "disable-chat-log":
+		err = g.handleSetChatLog(actorCtx, false)
+	case "unlock":
+		err = g.handleUnlock(actorCtx, args)
+	case "make-private":
+		err = g.handleMakePrivate(actorCtx)
+	case "make-public":
+		err = g.handleMakePublic(actorCtx)
+	case "lock-with":
+		err = g.handleLockWith(actorCtx, args)
+	case "allow-player":
+		err = g.handleAllowPlayer(actorCtx, args, true)
+	case "disallow-player":
+		err = g.handleAllowPlayer(actorCtx, args, false)
+	case "ban-player":
+		err = g.handleBanPlayer(actorCtx, args, true)
+	case "unban-player":
+		err = g.handleBanPlayer(actorCtx, args, false)
+	case "show-access":
+		err = g.handleShowAccess(actorCtx)
+	case "build":
+		err = g.handleBuild(actorCtx)
+	case "stop-building":
+		err = g.handleStopBuilding(actorCtx)
+	case "describe-here":
+		err = g.handleDescribeHere(actorCtx, args)
+	case "add-exit":
+		err = g.handleAddExit(actorCtx, args)
+	case "add-response":
+		err = g.handleAddResponse(actorCtx, args)
+	case "create-object-here":
+		err = g.handleCreateObjectHere(actorCtx, args)
+	case "set-property":
+		err = g.handleSetProperty(actorCtx, args)
+	case "confirm-build":
+		err = g.handleConfirmBuild(actorCtx)
+	case "cancel-build":
+		err = g.handleCancelBuild(actorCtx)
+	case "undo-build":
+		err = g.handleUndoBuild(actorCtx)
+	case "grant-builder":
+		err = g.handleGrantBuilder(actorCtx, args)
+	case "revoke-builder":
+		err = g.handleRevokeBuilder(actorCtx, args)
+	case "list-builders":
+		err = g.handleListBuilders(actorCtx)
+	case "map":
+		err = g.handleMap(actorCtx)
+	case "go-to":
+		err = g.handleGoTo(actorCtx, args)
+	case "who":
+		err = g.handleWho(actorCtx)
+	case "journal":
+		err = g.handleJournal(actorCtx)
+	case "bookmark":
+		err = g.handleBookmark(actorCtx, args)
+	case "bookmark-list":
+		err = g.handleBookmarkList(actorCtx)
+	case "recall":
+		err = g.handleRecall(actorCtx, args)
+	case "quest-list":
+		err = g.handleQuestList(actorCtx)
+	case "quest-details":
+		err = g.handleQuestDetails(actorCtx, args)
+	case "abandon-quest":
+		err = g.handleAbandonQuest(actorCtx, args)
 	case "transfer-object":
 		err = g.handleTransferObjectCmd(actorCtx, args)
 	case "receive-object":
@@ -345,7 +550,12 @@ func (g *Game) handleUserInput(actorCtx actor.Context, input *jasonsgame.UserInp
 	case "help":
 		err = g.handleHelp(actorCtx, args)
 	case "interaction":
-		err = g.handleInteractionInput(actorCtx, cmd.(*interactionCommand), args)
+		interactionCmd := cmd.(*interactionCommand)
+		outcome := &interactionOutcome{}
+		err = g.runInteraction(actorCtx, interactionCmd, args, outcome)
+		if err == nil && outcome.succeeded() {
+			g.updateQuests(actorCtx, &questEvent{interaction: interactionCmd.Parse()})
+		}
 	default:
 		log.Error("unhandled but matched command", cmd.Name())
 	}
@@ -552,83 +762,73 @@ func (g *Game) handleDeletePortal(actorCtx actor.Context, toDid string) error {
 	return nil
 }
 
-func (g *Game) handleTipZoom(actorCtx actor.Context, tip string) error {
-	tipCid, err := cid.Parse(tip)
-	if err != nil {
-		g.sendUserMessage(actorCtx, fmt.Sprintf("error parsing tip (%s): %v", tip, err))
-		return errors.Wrap(err, fmt.Sprintf("error parsing tip (%s)", tip))
+func (g *Game) handleInteractionInput(actorCtx actor.Context, cmd *interactionCommand, args string) error {
+	return g.runInteraction(actorCtx, cmd, args, &interactionOutcome{})
+}
+
+// runInteraction executes the interaction, recording in outcome whether it
+// did what the player asked
+func (g *Game) runInteraction(actorCtx actor.Context, cmd *interactionCommand, args string, outcome *interactionOutcome) error {
+	log.Debugf("handling interaction type %T", cmd.interaction)
+
+	registered, ok := registeredInteractionFor(cmd.interaction)
+	if !ok {
+		outcome.failed = true
+		g.sendUserMessage(actorCtx, fmt.Sprintf("no interaction matching %s, type %v", cmd.Parse(), reflect.TypeOf(cmd.interaction)))
+		return nil
 	}
-	tree, err := g.network.GetTreeByTip(tipCid)
-	if err != nil {
-		return errors.Wrap(err, fmt.Sprintf("error getting tip (%s)", tip))
+
+	err := registered.executor(&InteractionContext{
+		game:     g,
+		actorCtx: actorCtx,
+		command:  cmd,
+		outcome:  outcome,
+	}, cmd.interaction, args)
+
+	if limitErr, ok := err.(*InteractionLimitError); ok {
+		outcome.failed = true
+		g.sendUserMessage(actorCtx, limitErr.Message)
+		return nil
 	}
 
-	g.setLocation(actorCtx, tree.MustId())
-	g.sendUILocation(actorCtx)
-	return nil
+	return err
 }
 
-func (g *Game) handleInteractionInput(actorCtx actor.Context, cmd *interactionCommand, args string) error {
-	var err error
+func (g *Game) handleInteractionContinuation(actorCtx actor.Context, msg *interactionContinuation) {
+	outcome := &interactionOutcome{}
+	err := msg.then(&InteractionContext{
+		game:     g,
+		actorCtx: actorCtx,
+		command:  msg.command,
+		outcome:  outcome,
+	})
 
-	log.Debugf("handling interaction type %T", cmd.interaction)
+	if limitErr, ok := err.(*InteractionLimitError); ok {
+		g.sendUserMessage(actorCtx, limitErr.Message)
+		return
+	}
 
-	switch interaction := cmd.interaction.(type) {
-	case *RespondInteraction:
-		g.sendUserMessage(actorCtx, interaction.Response)
-	case *BuildPortalInteraction:
-		err = g.handleBuildPortal(actorCtx, args)
-	case *DeletePortalInteraction:
-		err = g.handleDeletePortal(actorCtx, args)
-	case *ChangeLocationInteraction:
-		g.handleChangeLocation(actorCtx, interaction.Did)
-	case *ChangeNamedLocationInteraction:
-		g.handleChangeNamedLocation(actorCtx, interaction.Name)
-	case *DropObjectInteraction:
-		err = g.handleDropObject(actorCtx, cmd, interaction)
-	case *PickUpObjectInteraction:
-		err = g.handlePickUpObject(actorCtx, interaction)
-	case *GetTreeValueInteraction:
-		err = g.handleGetTreeValueInteraction(actorCtx, interaction)
-	case *SetTreeValueInteraction:
-		err = g.handleSetTreeValueInteraction(actorCtx, interaction, args)
-	case *LookAroundInteraction:
-		err = g.handleLocationInventoryList(actorCtx)
-	case *CreateObjectInteraction:
-		err = g.handleCreateObjectInteraction(actorCtx, interaction)
-	case *CipherInteraction:
-		nextInteraction, _, err := interaction.Unseal(args)
-		if err != nil {
-			return err
-		}
-		nextCmd := &interactionCommand{
-			parse:       nextInteraction.GetCommand(),
-			interaction: nextInteraction,
-		}
-		return g.handleInteractionInput(actorCtx, nextCmd, args)
-	case *ChainedInteraction:
-		interactions, err := interaction.Interactions()
-		if err != nil {
-			return err
-		}
-		for _, nextInteraction := range interactions {
-			nextCmd := &interactionCommand{
-				parse:       interaction.GetCommand(),
-				interaction: nextInteraction,
-			}
-			err = g.handleInteractionInput(actorCtx, nextCmd, args)
-			if err != nil {
-				return err
-			}
-		}
-	default:
-		g.sendUserMessage(actorCtx, fmt.Sprintf("no interaction matching %s, type %v", cmd.Parse(), reflect.TypeOf(interaction)))
+	if err != nil {
+		g.sendUserMessage(actorCtx, fmt.Sprintf("error with your command: %v", err))
+		return
 	}
 
-	return err
+	if outcome.succeeded() {
+		g.updateQuests(actorCtx, &questEvent{interaction: msg.command.Parse()})
+	}
 }
 
 func (g *Game) handleChangeLocation(actorCtx actor.Context, did string) {
+	refusal, err := g.checkAccess(actorCtx, did)
+	if err != nil {
+		g.sendUserMessage(actorCtx, fmt.Sprintf("error checking if you can go there: %v", err))
+		return
+	}
+	if refusal != "" {
+		g.sendUserMessage(actorCtx, refusal)
+		return
+	}
+
 	log.Debugf("setting new location to %s", did)
 	g.setLocation(actorCtx, did)
 
@@ -636,9 +836,13 @@ func (g *Game) handleChangeLocation(actorCtx actor.Context, did string) {
 	// a hint that an artifact exists
 	inventoryList, _ := g.getInventoryList(actorCtx, g.locationActor)
 
+	g.sendMapUpdate(actorCtx)
+
 	log.Debug("sending new location to UI")
 	g.sendUILocation(actorCtx)
 	g.sendArtifactHint(actorCtx, inventoryList)
+
+	g.updateQuests(actorCtx, &questEvent{locationDid: did})
 }
 
 func (g *Game) handleChangeNamedLocation(actorCtx actor.Context, name string) {
@@ -712,10 +916,16 @@ func (g *Game) handleGetTreeValueInteraction(actorCtx actor.Context, interaction
 	switch msg := value.(type) {
 	case string:
 		toSend = msg
+		if interaction.Template {
+			toSend = g.renderResponse(actorCtx, toSend)
+		}
 	case []interface{}:
 		stringSlice := make([]string, len(msg))
 		for i, v := range msg {
 			stringSlice[i] = fmt.Sprintf("%v", v)
+			if interaction.Template {
+				stringSlice[i] = g.renderResponse(actorCtx, stringSlice[i])
+			}
 		}
 		toSend = strings.Join(stringSlice, "\n")
 	default:
@@ -734,13 +944,25 @@ func (g *Game) handleDropObject(actorCtx actor.Context, cmd *interactionCommand,
 		return fmt.Errorf("Interaction from %s tried to drop %s - this is not allowed", cmd.did, interaction.Did)
 	}
 
+	err := g.dropObject(actorCtx, interaction.Did)
+	if err != nil {
+		return err
+	}
+
+	g.sendUserMessage(actorCtx, "object has been dropped into your current location")
+	return nil
+}
+
+// dropObject moves the object with did from the player's bag into the
+// current location
+func (g *Game) dropObject(actorCtx actor.Context, did string) error {
 	locationInventoryDid, err := actorCtx.RequestFuture(g.locationActor, &GetInventoryDid{}, 5*time.Second).Result()
 	if err != nil {
 		return errors.Wrap(err, "error executing drop request")
 	}
 
 	response, err := actorCtx.RequestFuture(g.inventoryActor, &TransferObjectRequest{
-		Did: interaction.Did,
+		Did: did,
 		To:  locationInventoryDid.(string),
 	}, 30*time.Second).Result()
 
@@ -752,12 +974,11 @@ func (g *Game) handleDropObject(actorCtx actor.Context, cmd *interactionCommand,
 	if !ok {
 		return fmt.Errorf("error casting drop object response")
 	}
-
 	if resp.Error != nil {
 		return resp.Error
 	}
 
-	g.sendUserMessage(actorCtx, "object has been dropped into your current location")
+	g.ownObjectMoves[did] = true
 	return nil
 }
 
@@ -809,6 +1030,7 @@ func (g *Game) handlePickUpObject(actorCtx actor.Context, interaction *PickUpObj
 	if changeEvent.Error != "" {
 		return fmt.Errorf(changeEvent.Error)
 	}
+	g.ownObjectMoves[objectDid] = false
 
 	if changeEvent.Message != "" {
 		g.sendUserMessage(actorCtx, changeEvent.Message)
@@ -816,6 +1038,12 @@ func (g *Game) handlePickUpObject(actorCtx actor.Context, interaction *PickUpObj
 		g.sendUserMessage(actorCtx, "object has been picked up")
 	}
 
+	handlerDid, err := g.currentLocationHandlerDid()
+	if err != nil {
+		log.Warningf("error fetching location handler: %v", err)
+	}
+	g.updateQuests(actorCtx, &questEvent{pickedUp: true, prizeFrom: handlerDid})
+
 	return nil
 }
 
@@ -937,6 +1165,13 @@ func (g *Game) handleLocationInventoryList(actorCtx actor.Context) error {
 		g.sendUserMessage(actorCtx, fmt.Sprintf("you see a mysterious portal leading to %s", l.Portal.To))
 	}
 
+	occupants, err := g.getOccupants(actorCtx)
+	if err != nil {
+		log.Warningf("error getting occupants: %v", err)
+	} else if len(occupants.Names) > 0 {
+		g.sendUserMessage(actorCtx, "also here: "+strings.Join(occupants.List(), ", "))
+	}
+
 	g.sendArtifactHint(actorCtx, inventoryList)
 
 	return nil
@@ -1011,7 +1246,9 @@ func (g *Game) sendUILocation(actorCtx actor.Context) {
 		g.sendUserMessage(actorCtx, fmt.Errorf("error getting current location: %v", err))
 	}
 
+	g.fillMapCoordinates(l)
 	g.sendUserMessage(actorCtx, l)
+	g.sendLocationChanges(actorCtx)
 }
 
 func formatUserMessage(mesgInter interface{}) *jasonsgame.MessageToUser {
@@ -1075,6 +1312,17 @@ func (g *Game) setLocation(actorCtx actor.Context, locationDid string) {
 		log.Debug("found old location actor; sending stop message")
 		actorCtx.Stop(g.locationActor)
 	}
+	if g.presenceActor != nil {
+		actorCtx.Stop(g.presenceActor)
+	}
+
+	g.leaveLocation()
+	g.viewing = nil
+	g.pendingBuild = nil
+
+	// conversations end when leaving, even with objects, which could be left
+	// behind
+	g.dialogue = nil
 
 	log.Debug("spawning new location actor")
 	g.locationActor = actorCtx.Spawn(NewLocationActorProps(&LocationActorConfig{
@@ -1084,6 +1332,18 @@ func (g *Game) setLocation(actorCtx actor.Context, locationDid string) {
 	}))
 	g.locationDid = locationDid
 
+	g.presenceActor = actorCtx.Spawn(NewPresenceActorProps(&PresenceActorConfig{
+		Network:     g.network,
+		LocationDid: locationDid,
+		PlayerDid:   g.playerTree.Did(),
+		Name:        g.playerName(),
+	}))
+
+	if g.chatActor != nil {
+		g.chatLogged = g.locationChatLogged(locationDid)
+		actorCtx.Send(g.chatActor, &JoinChatLocation{Did: locationDid, Logged: g.chatLogged})
+	}
+
 	// store previous location, except for when changing to home
 	if locationDid != g.playerTree.HomeLocation.MustId() {
 		err := g.ds.Put(lastLocationKey, []byte(locationDid))
@@ -1092,6 +1352,8 @@ func (g *Game) setLocation(actorCtx actor.Context, locationDid string) {
 		}
 	}
 
+	g.visitLocation()
+
 	log.Debug("replacing interactions for new location")
 	err := g.replaceInteractionsFor(actorCtx, g.locationActor, oldLocationActor)
 	if err != nil {
@@ -1138,14 +1400,31 @@ func (g *Game) refreshInteractionsFor(actorCtx actor.Context, pid *actor.PID) er
 		return err
 	}
 
-	newCommands := defaultCommandList
+	g.updateCommands(actorCtx)
+	return nil
+}
+
+// defaultCommandsFor leaves out the build mode commands unless building, so
+// they don't shadow interactions with the same name
+func defaultCommandsFor(building bool) commandList {
+	commands := commandList{}
+	for _, cmd := range defaultCommandList {
+		if building || !buildModeCommands[cmd.Name()] {
+			commands = append(commands, cmd)
+		}
+	}
+	return commands
+}
+
+// updateCommands sets the commands from the defaults and the cached interactions
+func (g *Game) updateCommands(actorCtx actor.Context) {
+	newCommands := defaultCommandsFor(g.building)
 	for _, commands := range g.commandsByActorCache {
 		newCommands = append(newCommands, commands...)
 	}
 
 	log.Debugf("setting commands to %+v", newCommands)
 	g.setCommands(actorCtx, newCommands)
-	return nil
 }
 
 func (g *Game) interactionCommandsFor(actorCtx actor.Context, pid *actor.PID) (commandList, error) {
@@ -1165,15 +1444,13 @@ func (g *Game) interactionCommandsFor(actorCtx actor.Context, pid *actor.PID) (c
 	interactions := interactionsResponse.Interactions
 	interactionCommands := make(commandList, 0)
 	for _, interactionResp := range interactions {
-		switch interactionResp.Interaction.(type) {
-		case *PickUpObjectInteraction:
-			// Filter out PickUpObject if in player inventory
-			if g.inventoryActor == pid {
+		// Filter out interactions that don't apply to where they are attached,
+		// e.g. picking up an object already in the player's inventory
+		if registered, ok := registeredInteractionFor(interactionResp.Interaction); ok {
+			if g.inventoryActor == pid && !registered.inPlayerInventory {
 				continue
 			}
-		case *DropObjectInteraction:
-			// Filter out DropObject if NOT in player inventory
-			if g.inventoryActor != pid {
+			if g.inventoryActor != pid && !registered.outsidePlayerInventory {
 				continue
 			}
 		}
@@ -1220,4 +1497,8 @@ func (g *Game) handleStateChange(actorCtx actor.Context, msg *StateChange) {
 			log.Warningf("error refreshing interactions on state change %v", err)
 		}
 	}
+
+	if pid == g.locationActor {
+		g.pushLocationChanges(actorCtx)
+	}
 }
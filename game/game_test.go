@@ -1,15 +1,31 @@
 package game
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/AsynkronIT/protoactor-go/actor"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gogo/protobuf/proto"
+	messages "github.com/quorumcontrol/messages/build/go/community"
+	"github.com/quorumcontrol/messages/build/go/transactions"
+	"github.com/quorumcontrol/tupelo-go-sdk/consensus"
 	"github.com/stretchr/testify/require"
 
+	"github.com/quorumcontrol/jasons-game/game/mail"
 	"github.com/quorumcontrol/jasons-game/game/trees"
+	"github.com/quorumcontrol/jasons-game/handlers"
+	"github.com/quorumcontrol/jasons-game/handlers/chatlog"
+	"github.com/quorumcontrol/jasons-game/handlers/inksink"
+	"github.com/quorumcontrol/jasons-game/handlers/mailbox"
 	"github.com/quorumcontrol/jasons-game/network"
 	"github.com/quorumcontrol/jasons-game/pb/jasonsgame"
+	"github.com/quorumcontrol/jasons-game/service"
 	"github.com/quorumcontrol/jasons-game/ui"
 )
 
@@ -92,7 +108,7 @@ func TestGoThroughPortal(t *testing.T) {
 
 	did := remoteTree.MustId()
 	stream.ExpectMessage("built a portal", 2*time.Second)
-	rootCtx.Send(game, &jasonsgame.UserInput{Message: "build portal to" + did})
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "build portal to " + did})
 	stream.Wait()
 
 	rootCtx.Send(game, &jasonsgame.UserInput{Message: "refresh"})
@@ -172,6 +188,41 @@ func TestInscriptionInteractions(t *testing.T) {
 		rootCtx.Send(game, &jasonsgame.UserInput{Message: "test2 read inscriptions"})
 		stream.Wait()
 	})
+
+	t.Run("with a templated inscription", func(t *testing.T) {
+		err = obj.AddInteraction(&SetTreeValueInteraction{
+			Command: "test3 inscribe",
+			Did:     obj.MustId(),
+			Path:    "inscriptions3",
+		})
+		require.Nil(t, err)
+
+		err = obj.AddInteraction(&GetTreeValueInteraction{
+			Command: "test3 read",
+			Did:     obj.MustId(),
+			Path:    "inscriptions3",
+		})
+		require.Nil(t, err)
+		err = obj.AddInteraction(&GetTreeValueInteraction{
+			Command:  "test3 recite",
+			Did:      obj.MustId(),
+			Path:     "inscriptions3",
+			Template: true,
+		})
+		require.Nil(t, err)
+		time.Sleep(50 * time.Millisecond)
+		rootCtx.Send(game, &jasonsgame.UserInput{Message: "refresh"})
+		rootCtx.Send(game, &jasonsgame.UserInput{Message: `test3 inscribe a [[ "sharp" ]] sword`})
+
+		// only rendered when the interaction asks for it
+		stream.ExpectMessage(`a [[ "sharp" ]] sword`, 2*time.Second)
+		rootCtx.Send(game, &jasonsgame.UserInput{Message: "test3 read"})
+		stream.Wait()
+
+		stream.ExpectMessage("a sharp sword", 2*time.Second)
+		rootCtx.Send(game, &jasonsgame.UserInput{Message: "test3 recite"})
+		stream.Wait()
+	})
 }
 
 func TestCantDropFromOtherTree(t *testing.T) {
@@ -245,3 +296,1738 @@ func TestCreateDupeObject(t *testing.T) {
 	rootCtx.Send(game, &jasonsgame.UserInput{Message: "create object foo"})
 	stream.Wait()
 }
+
+func TestConditionalInteraction(t *testing.T) {
+	net := network.NewLocalNetwork()
+	stream := ui.NewTestStream(t)
+
+	simulatedUI, game := setupUiAndGame(t, stream, net)
+	defer rootCtx.Stop(simulatedUI)
+	defer rootCtx.Stop(game)
+
+	playerTree, err := GetPlayerTree(net)
+	require.Nil(t, err)
+
+	interaction, err := NewConditionalInteraction("open door",
+		&InteractionCondition{InventoryContains: "key"},
+		&RespondInteraction{Response: "the door swings open"},
+		&RespondInteraction{Response: "the door is locked"},
+	)
+	require.Nil(t, err)
+
+	err = playerTree.HomeLocation.AddInteraction(interaction)
+	require.Nil(t, err)
+	time.Sleep(50 * time.Millisecond)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "refresh"})
+
+	stream.ExpectMessage("the door is locked", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "open door"})
+	stream.Wait()
+
+	stream.ExpectMessage("key has been created", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "create object key"})
+	stream.Wait()
+
+	stream.ExpectMessage("the door swings open", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "open door"})
+	stream.Wait()
+}
+
+func TestLimitedInteraction(t *testing.T) {
+	net := network.NewLocalNetwork()
+	stream := ui.NewTestStream(t)
+
+	simulatedUI, game := setupUiAndGame(t, stream, net)
+	defer rootCtx.Stop(simulatedUI)
+	defer rootCtx.Stop(game)
+
+	playerTree, err := GetPlayerTree(net)
+	require.Nil(t, err)
+
+	interaction, err := NewLimitedInteraction("ring bell", &RespondInteraction{Response: "the bell rings"}, 1, 0)
+	require.Nil(t, err)
+	interaction.LimitMessage = "the bell is broken"
+
+	err = playerTree.HomeLocation.AddInteraction(interaction)
+	require.Nil(t, err)
+	time.Sleep(50 * time.Millisecond)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "refresh"})
+
+	stream.ExpectMessage("the bell rings", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "ring bell"})
+	stream.Wait()
+
+	stream.ExpectMessage("the bell is broken", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "ring bell"})
+	stream.Wait()
+
+	playerTree, err = GetPlayerTree(net)
+	require.Nil(t, err)
+	usage, err := playerTree.InteractionUsage(playerTree.HomeLocation.MustId(), "ring bell")
+	require.Nil(t, err)
+	require.Equal(t, uint32(1), usage.Count)
+}
+
+func TestDialogueInteraction(t *testing.T) {
+	net := network.NewLocalNetwork()
+	stream := ui.NewTestStream(t)
+
+	simulatedUI, game := setupUiAndGame(t, stream, net)
+	defer rootCtx.Stop(simulatedUI)
+	defer rootCtx.Stop(game)
+
+	playerTree, err := GetPlayerTree(net)
+	require.Nil(t, err)
+
+	greeting, err := NewDialogueNode("greeting", "the hermit grunts", nil,
+		&DialogueChoice{Text: "ask about the cave", Next: "cave"},
+		&DialogueChoice{Text: "walk away"},
+	)
+	require.Nil(t, err)
+	cave, err := NewDialogueNode("cave", "the cave is dangerous", &RespondInteraction{Response: "the hermit points north"},
+		&DialogueChoice{Text: "thank the hermit", Next: "greeting"},
+	)
+	require.Nil(t, err)
+	interaction, err := NewDialogueInteraction("talk to hermit", "greeting", greeting, cave)
+	require.Nil(t, err)
+
+	err = playerTree.HomeLocation.AddInteraction(interaction)
+	require.Nil(t, err)
+	time.Sleep(50 * time.Millisecond)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "refresh"})
+
+	stream.ExpectMessage("you say: 1", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "say 1"})
+	stream.Wait()
+
+	stream.ExpectMessage("1. ask about the cave", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "talk to hermit"})
+	stream.Wait()
+
+	stream.ExpectMessage("the hermit points north", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "say 1"})
+	stream.Wait()
+
+	playerTree, err = GetPlayerTree(net)
+	require.Nil(t, err)
+	node, err := playerTree.DialogueNode(playerTree.HomeLocation.MustId(), "talk to hermit")
+	require.Nil(t, err)
+	require.Equal(t, "cave", node)
+
+	// talking again picks up where the conversation left off
+	stream.ExpectMessage("1. thank the hermit", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "talk to hermit"})
+	stream.Wait()
+
+	stream.ExpectMessage("the hermit grunts", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "say 1"})
+	stream.Wait()
+
+	stream.ExpectMessage("the conversation is over", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "say 2"})
+	stream.Wait()
+
+	playerTree, err = GetPlayerTree(net)
+	require.Nil(t, err)
+	node, err = playerTree.DialogueNode(playerTree.HomeLocation.MustId(), "talk to hermit")
+	require.Nil(t, err)
+	require.Equal(t, "", node)
+
+	// leaving ends the conversation
+	fieldTree, err := net.CreateChainTree()
+	require.Nil(t, err)
+	field := NewLocationTree(net, fieldTree)
+	err = field.SetDescription("a windy field")
+	require.Nil(t, err)
+	err = playerTree.HomeLocation.AddInteraction(&ChangeLocationInteraction{Command: "go north", Did: field.MustId()})
+	require.Nil(t, err)
+	time.Sleep(50 * time.Millisecond)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "refresh"})
+
+	stream.ExpectMessage("1. ask about the cave", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "talk to hermit"})
+	stream.Wait()
+
+	stream.ExpectMessage("a windy field", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "go north"})
+	stream.Wait()
+
+	stream.ExpectMessage("you say: 1", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "say 1"})
+	stream.Wait()
+}
+
+func TestRandomInteractionShowRoll(t *testing.T) {
+	net := network.NewLocalNetwork()
+	stream := ui.NewTestStream(t)
+
+	simulatedUI, game := setupUiAndGame(t, stream, net)
+	defer rootCtx.Stop(simulatedUI)
+	defer rootCtx.Stop(game)
+
+	playerTree, err := GetPlayerTree(net)
+	require.Nil(t, err)
+
+	interaction, err := NewRandomInteraction("ask the fortune teller",
+		&WeightedInteraction{Weight: 1, Interaction: &RespondInteraction{Response: "you will be rich"}},
+	)
+	require.Nil(t, err)
+	interaction.ShowRoll = true
+	err = playerTree.HomeLocation.AddInteraction(interaction)
+	require.Nil(t, err)
+	time.Sleep(50 * time.Millisecond)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "refresh"})
+
+	stream.ExpectMessage("command ask the fortune teller and use 0", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "ask the fortune teller"})
+	stream.Wait()
+
+	stream.ExpectMessage("command ask the fortune teller and use 1", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "ask the fortune teller"})
+	stream.Wait()
+
+	// the count is kept on the player tree, so rolls can be re-derived
+	playerTree, err = GetPlayerTree(net)
+	require.Nil(t, err)
+	uses, err := playerTree.RandomUses(playerTree.HomeLocation.MustId(), "ask the fortune teller")
+	require.Nil(t, err)
+	require.Equal(t, uint64(2), uses)
+}
+
+func TestMultiPartCipherInteraction(t *testing.T) {
+	net := network.NewLocalNetwork()
+	stream := ui.NewTestStream(t)
+
+	simulatedUI, game := setupUiAndGame(t, stream, net)
+	defer rootCtx.Stop(simulatedUI)
+	defer rootCtx.Stop(game)
+
+	playerTree, err := GetPlayerTree(net)
+	require.Nil(t, err)
+
+	interaction, err := NewMultiPartCipherInteraction("speak", []string{"open", "sesame"},
+		&RespondInteraction{Response: "the door swings open"},
+		&RespondInteraction{Response: "the door stays shut"},
+		nil,
+	)
+	require.Nil(t, err)
+	interaction.Limit = &CipherLimit{MaxAttempts: 2, LockoutSeconds: 2, LockoutMessage: "the door ignores you"}
+
+	err = playerTree.HomeLocation.AddInteraction(interaction)
+	require.Nil(t, err)
+	time.Sleep(50 * time.Millisecond)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "refresh"})
+
+	stream.ExpectMessage("that's 1 of 2", 5*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "speak sesame"})
+	stream.Wait()
+
+	// repeating a fragment that's been found doesn't earn more guesses
+	stream.ExpectMessage("that's 1 of 2", 5*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "speak sesame"})
+	stream.Wait()
+
+	stream.ExpectMessage("the door stays shut", 5*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "speak close"})
+	stream.Wait()
+
+	stream.ExpectMessage("the door ignores you", 5*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "speak open"})
+	stream.Wait()
+
+	time.Sleep(2 * time.Second)
+
+	stream.ExpectMessage("the door swings open", 5*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "speak open"})
+	stream.Wait()
+}
+
+func TestQuests(t *testing.T) {
+	net := network.NewLocalNetwork()
+	stream := ui.NewTestStream(t)
+
+	simulatedUI, game := setupUiAndGame(t, stream, net)
+	defer rootCtx.Stop(simulatedUI)
+	defer rootCtx.Stop(game)
+
+	playerTree, err := GetPlayerTree(net)
+	require.Nil(t, err)
+
+	questTree, err := CreateQuestTree(net, &Quest{
+		Name:              "greetings",
+		Description:       "say hello to everyone",
+		CompletionMessage: "everyone has been greeted",
+		Objectives: []*QuestObjective{
+			{Description: "go home", VisitLocation: playerTree.HomeLocation.MustId()},
+			{Description: "wave hello", UseInteraction: "wave"},
+		},
+	})
+	require.Nil(t, err)
+
+	wave, err := NewConditionalInteraction("wave",
+		&InteractionCondition{InventoryContains: "flag"},
+		&RespondInteraction{Response: "you wave"},
+		&RespondInteraction{Response: "you have nothing to wave"},
+	)
+	require.Nil(t, err)
+	err = playerTree.HomeLocation.AddInteraction(wave)
+	require.Nil(t, err)
+	err = playerTree.HomeLocation.AddInteraction(&StartQuestInteraction{Command: "accept quest", Did: questTree.MustId()})
+	require.Nil(t, err)
+	time.Sleep(50 * time.Millisecond)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "refresh"})
+
+	stream.ExpectMessage("you aren't on any quests", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "quests"})
+	stream.Wait()
+
+	stream.ExpectMessage("you have started the quest greetings", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "accept quest"})
+	stream.Wait()
+
+	stream.ExpectMessage("greetings (1/2)", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "quests"})
+	stream.Wait()
+
+	// interactions that don't do what was asked don't count
+	stream.ExpectMessage("you have nothing to wave", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "wave"})
+	stream.Wait()
+
+	stream.ExpectMessage("greetings (1/2)", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "quests"})
+	stream.Wait()
+
+	stream.ExpectMessage("flag has been created", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "create object flag"})
+	stream.Wait()
+
+	stream.ExpectMessage("everyone has been greeted", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "wave"})
+	stream.Wait()
+
+	stream.ExpectMessage("[x] wave hello", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "quest greetings"})
+	stream.Wait()
+
+	stream.ExpectMessage("you have abandoned the quest greetings", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "abandon quest greetings"})
+	stream.Wait()
+
+	stream.ExpectMessage("you aren't on any quests", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "quests"})
+	stream.Wait()
+}
+
+func TestMap(t *testing.T) {
+	net := network.NewLocalNetwork()
+	stream := ui.NewTestStream(t)
+
+	simulatedUI, game := setupUiAndGame(t, stream, net)
+	defer rootCtx.Stop(simulatedUI)
+	defer rootCtx.Stop(game)
+
+	playerTree, err := GetPlayerTree(net)
+	require.Nil(t, err)
+
+	fieldTree, err := net.CreateChainTree()
+	require.Nil(t, err)
+	field := NewLocationTree(net, fieldTree)
+	err = field.SetDescription("a windy field")
+	require.Nil(t, err)
+	err = field.AddInteraction(&ChangeNamedLocationInteraction{Command: "go south", Name: "home"})
+	require.Nil(t, err)
+
+	err = playerTree.HomeLocation.AddInteraction(&ChangeLocationInteraction{Command: "go north", Did: field.MustId()})
+	require.Nil(t, err)
+	time.Sleep(50 * time.Millisecond)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "refresh"})
+
+	stream.ExpectMessage("[?]", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "map"})
+	stream.Wait()
+
+	stream.ExpectMessage("a windy field", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "go north"})
+	stream.Wait()
+
+	stream.ExpectMessage("[@]\n             |\n            [ ]", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "map"})
+	stream.Wait()
+
+	var mapUpdate *jasonsgame.MapUpdate
+	for _, msg := range stream.GetMessages() {
+		if update := msg.GetMapUpdate(); update != nil {
+			mapUpdate = update
+		}
+	}
+	require.NotNil(t, mapUpdate)
+	require.Equal(t, field.MustId(), mapUpdate.Current)
+	require.Len(t, mapUpdate.Locations, 2)
+	require.Len(t, mapUpdate.Exits, 2)
+}
+
+func TestGoTo(t *testing.T) {
+	net := network.NewLocalNetwork()
+	stream := ui.NewTestStream(t)
+
+	simulatedUI, game := setupUiAndGame(t, stream, net)
+	defer rootCtx.Stop(simulatedUI)
+	defer rootCtx.Stop(game)
+
+	playerTree, err := GetPlayerTree(net)
+	require.Nil(t, err)
+	err = playerTree.HomeLocation.SetDescription("home sweet home")
+	require.Nil(t, err)
+
+	fieldTree, err := net.CreateChainTree()
+	require.Nil(t, err)
+	field := NewLocationTree(net, fieldTree)
+	err = field.SetDescription("a windy field")
+	require.Nil(t, err)
+
+	forestTree, err := net.CreateChainTree()
+	require.Nil(t, err)
+	forest := NewLocationTree(net, forestTree)
+	err = forest.SetDescription("a dark forest")
+	require.Nil(t, err)
+
+	err = playerTree.HomeLocation.AddInteraction(&ChangeLocationInteraction{Command: "go north", Did: field.MustId()})
+	require.Nil(t, err)
+	err = field.AddInteraction(&ChangeNamedLocationInteraction{Command: "go south", Name: "home"})
+	require.Nil(t, err)
+	err = field.AddInteraction(&ChangeLocationInteraction{Command: "enter the forest", Did: forest.MustId()})
+	require.Nil(t, err)
+	err = forest.AddInteraction(&ChangeLocationInteraction{Command: "go back", Did: field.MustId()})
+	require.Nil(t, err)
+	time.Sleep(50 * time.Millisecond)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "refresh"})
+
+	stream.ExpectMessage("you don't know the way to the forest", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "go to the forest"})
+	stream.Wait()
+
+	stream.ExpectMessage("a windy field", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "go north"})
+	stream.Wait()
+
+	stream.ExpectMessage("a dark forest", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "enter the forest"})
+	stream.Wait()
+
+	stream.ExpectMessage("home sweet home", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "go to home"})
+	stream.Wait()
+
+	stream.ExpectMessage("a dark forest", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "go to the forest"})
+	stream.Wait()
+
+	stream.ExpectMessage("you are already there", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "go to the dark forest"})
+	stream.Wait()
+}
+
+func TestJournalAndBookmarks(t *testing.T) {
+	net := network.NewLocalNetwork()
+	stream := ui.NewTestStream(t)
+
+	simulatedUI, game := setupUiAndGame(t, stream, net)
+	defer rootCtx.Stop(simulatedUI)
+	defer rootCtx.Stop(game)
+
+	playerTree, err := GetPlayerTree(net)
+	require.Nil(t, err)
+	err = playerTree.HomeLocation.SetDescription("home sweet home")
+	require.Nil(t, err)
+
+	fieldTree, err := net.CreateChainTree()
+	require.Nil(t, err)
+	field := NewLocationTree(net, fieldTree)
+	err = field.SetDescription("a windy field")
+	require.Nil(t, err)
+
+	err = playerTree.HomeLocation.AddInteraction(&ChangeLocationInteraction{Command: "go north", Did: field.MustId()})
+	require.Nil(t, err)
+	err = field.AddInteraction(&ChangeNamedLocationInteraction{Command: "go south", Name: "home"})
+	require.Nil(t, err)
+	time.Sleep(50 * time.Millisecond)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "refresh"})
+
+	stream.ExpectMessage("you don't have any bookmarks", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "bookmarks"})
+	stream.Wait()
+
+	stream.ExpectMessage("a windy field", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "go north"})
+	stream.Wait()
+
+	stream.ExpectMessage("bookmarked this spot as the field", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "bookmark The  Field"})
+	stream.Wait()
+
+	stream.ExpectMessage("home sweet home", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "go south"})
+	stream.Wait()
+
+	stream.ExpectMessage("a windy field - first visited", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "journal"})
+	stream.Wait()
+
+	stream.ExpectMessage("the field - a windy field", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "bookmarks"})
+	stream.Wait()
+
+	stream.ExpectMessage("you don't have a bookmark named the forest", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "recall the forest"})
+	stream.Wait()
+
+	stream.ExpectMessage("a windy field", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "recall the field"})
+	stream.Wait()
+
+	stream.ExpectMessage("you are already there", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "recall the field"})
+	stream.Wait()
+
+	// the game updated the player tree, so fetch the latest
+	playerTree, err = GetPlayerTree(net)
+	require.Nil(t, err)
+	journal, err := playerTree.Journal()
+	require.Nil(t, err)
+	require.Len(t, journal, 2)
+	require.Equal(t, uint32(2), journal[field.MustId()].Visits)
+}
+
+func TestHistoryView(t *testing.T) {
+	net := network.NewLocalNetwork()
+	stream := ui.NewTestStream(t)
+
+	simulatedUI, game := setupUiAndGame(t, stream, net)
+	defer rootCtx.Stop(simulatedUI)
+	defer rootCtx.Stop(game)
+
+	playerTree, err := GetPlayerTree(net)
+	require.Nil(t, err)
+
+	err = playerTree.HomeLocation.SetDescription("an empty lot")
+	require.Nil(t, err)
+	emptyLotHeight, err := playerTree.HomeLocation.Height()
+	require.Nil(t, err)
+
+	err = playerTree.HomeLocation.SetDescription("a grand hall")
+	require.Nil(t, err)
+	err = playerTree.HomeLocation.AddInteraction(&RespondInteraction{Command: "wave", Response: "the hall echoes"})
+	require.Nil(t, err)
+	time.Sleep(50 * time.Millisecond)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "refresh"})
+
+	stream.ExpectMessage(fmt.Sprintf("height %d - %s", emptyLotHeight, time.Now().Format("2006-01-02")), 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "history here"})
+	stream.Wait()
+
+	stream.ExpectMessage("an empty lot", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: fmt.Sprintf("view here at %d", emptyLotHeight)})
+	stream.Wait()
+
+	stream.ExpectMessage("you can't do that while viewing the past", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "wave"})
+	stream.Wait()
+
+	stream.ExpectMessage("you can't do that while viewing the past", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "say hello"})
+	stream.Wait()
+
+	stream.ExpectMessage("there is no height 999 here", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "view here at 999"})
+	stream.Wait()
+
+	stream.ExpectMessage("back to the present", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "stop viewing"})
+	stream.Wait()
+
+	stream.ExpectMessage("the hall echoes", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "wave"})
+	stream.Wait()
+}
+
+func TestLocationChangesSinceLastVisit(t *testing.T) {
+	net := network.NewLocalNetwork()
+	stream := ui.NewTestStream(t)
+
+	simulatedUI, game := setupUiAndGame(t, stream, net)
+	defer rootCtx.Stop(simulatedUI)
+	defer rootCtx.Stop(game)
+
+	playerTree, err := GetPlayerTree(net)
+	require.Nil(t, err)
+	err = playerTree.HomeLocation.SetDescription("a bare room")
+	require.Nil(t, err)
+
+	fieldTree, err := net.CreateChainTree()
+	require.Nil(t, err)
+	field := NewLocationTree(net, fieldTree)
+	err = field.SetDescription("a windy field")
+	require.Nil(t, err)
+
+	err = playerTree.HomeLocation.AddInteraction(&ChangeLocationInteraction{Command: "go north", Did: field.MustId()})
+	require.Nil(t, err)
+	err = field.AddInteraction(&ChangeNamedLocationInteraction{Command: "go south", Name: "home"})
+	require.Nil(t, err)
+	time.Sleep(50 * time.Millisecond)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "refresh"})
+
+	stream.ExpectMessage("a windy field", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "go north"})
+	stream.Wait()
+
+	err = playerTree.HomeLocation.SetDescription("a cozy room")
+	require.Nil(t, err)
+	err = playerTree.HomeLocation.AddInteraction(&RespondInteraction{Command: "light the fire", Response: "it crackles"})
+	require.Nil(t, err)
+
+	stream.ExpectMessage("new things to do: light the fire", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "go south"})
+	stream.Wait()
+
+	// nothing has changed in the field since the first visit
+	stream.ExpectMessage("a windy field", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "go north"})
+	stream.Wait()
+
+	changeSummaries := 0
+	for _, msg := range stream.GetMessages() {
+		if userMessage := msg.GetUserMessage(); userMessage != nil && strings.Contains(userMessage.Message, "since you were last here") {
+			changeSummaries++
+		}
+	}
+	require.Equal(t, 1, changeSummaries)
+}
+
+func TestLiveLocationUpdates(t *testing.T) {
+	net := network.NewLocalNetwork()
+	stream := ui.NewTestStream(t)
+
+	simulatedUI, game := setupUiAndGame(t, stream, net)
+	defer rootCtx.Stop(simulatedUI)
+	defer rootCtx.Stop(game)
+
+	playerTree, err := GetPlayerTree(net)
+	require.Nil(t, err)
+
+	lantern, err := CreateObjectTree(net, "lantern")
+	require.Nil(t, err)
+
+	stream.ExpectMessage("A lantern appears on the ground.", 2*time.Second)
+	homeTree, err := net.GetTree(playerTree.HomeLocation.MustId())
+	require.Nil(t, err)
+	err = trees.NewInventoryTree(net, homeTree).Add(lantern.MustId())
+	require.Nil(t, err)
+	stream.Wait()
+
+	home, err := net.GetTree(playerTree.HomeLocation.MustId())
+	require.Nil(t, err)
+	homeLocation := NewLocationTree(net, home)
+
+	stream.ExpectMessage("Something new can be done here: wave.", 2*time.Second)
+	err = homeLocation.AddInteraction(&RespondInteraction{Command: "wave", Response: "nobody waves back"})
+	require.Nil(t, err)
+	stream.Wait()
+
+	stream.ExpectMessage("The room changes around you.", 2*time.Second)
+	err = homeLocation.SetDescription("a room with a lantern")
+	require.Nil(t, err)
+	stream.Wait()
+
+	// the commands were refreshed along with the update
+	stream.ExpectMessage("nobody waves back", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "wave"})
+	stream.Wait()
+}
+
+func TestPresence(t *testing.T) {
+	net := network.NewLocalNetwork()
+	stream := ui.NewTestStream(t)
+
+	simulatedUI, game := setupUiAndGame(t, stream, net)
+	defer rootCtx.Stop(simulatedUI)
+	defer rootCtx.Stop(game)
+
+	playerTree, err := GetPlayerTree(net)
+	require.Nil(t, err)
+	homeDid := playerTree.HomeLocation.MustId()
+	topic := net.Community().TopicFor(homeDid)
+
+	jasonKey, jasonTree := newChatSender(t, net, "jason")
+	impostorKey, err := crypto.GenerateKey()
+	require.Nil(t, err)
+
+	stream.ExpectMessage("there's nobody else here", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "who"})
+	stream.Wait()
+	time.Sleep(100 * time.Millisecond)
+
+	// unsigned and forged presence is ignored
+	unsigned := &jasonsgame.PresenceMessage{
+		Kind:     jasonsgame.PresenceMessage_ARRIVE,
+		From:     jasonTree.MustId(),
+		Name:     "jason",
+		Location: homeDid,
+		SentAt:   time.Now().Unix(),
+	}
+	require.Nil(t, net.Community().Send(topic, unsigned))
+	forged := &jasonsgame.PresenceMessage{
+		Kind:     jasonsgame.PresenceMessage_ARRIVE,
+		From:     jasonTree.MustId(),
+		Location: homeDid,
+		SentAt:   time.Now().Unix(),
+	}
+	require.Nil(t, chatlog.Sign(forged, impostorKey))
+	require.Nil(t, net.Community().Send(topic, forged))
+	time.Sleep(100 * time.Millisecond)
+
+	stream.ExpectMessage("there's nobody else here", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "who"})
+	stream.Wait()
+
+	arrived := &jasonsgame.PresenceMessage{
+		Kind:     jasonsgame.PresenceMessage_ARRIVE,
+		From:     jasonTree.MustId(),
+		Name:     "not jason",
+		Location: homeDid,
+		SentAt:   time.Now().Unix(),
+	}
+	require.Nil(t, chatlog.Sign(arrived, jasonKey))
+
+	// shown by the name on their tree, not the one they sent
+	stream.ExpectMessage("jason arrives.", 2*time.Second)
+	require.Nil(t, net.Community().Send(topic, arrived))
+	stream.Wait()
+
+	stream.ExpectMessage("also here:\n  > jason", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "who"})
+	stream.Wait()
+
+	stream.ExpectMessage("also here: jason", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "look around"})
+	stream.Wait()
+
+	departed := &jasonsgame.PresenceMessage{
+		Kind:     jasonsgame.PresenceMessage_DEPART,
+		From:     jasonTree.MustId(),
+		Location: homeDid,
+		SentAt:   time.Now().Unix() + 1,
+	}
+	require.Nil(t, chatlog.Sign(departed, jasonKey))
+
+	stream.ExpectMessage("jason leaves.", 2*time.Second)
+	require.Nil(t, net.Community().Send(topic, departed))
+	stream.Wait()
+
+	// replaying their arrival doesn't bring them back
+	require.Nil(t, net.Community().Send(topic, arrived))
+	time.Sleep(100 * time.Millisecond)
+
+	stream.ExpectMessage("there's nobody else here", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "who"})
+	stream.Wait()
+}
+
+// newChatSender makes a player tree called name, owned by the returned key
+func newChatSender(t *testing.T, net *network.LocalNetwork, name string) (*ecdsa.PrivateKey, *consensus.SignedChainTree) {
+	key, err := crypto.GenerateKey()
+	require.Nil(t, err)
+	tree, err := net.CreateChainTree()
+	require.Nil(t, err)
+	tree, err = net.UpdateChainTree(tree, playerTreePath, &jasonsgame.Player{Name: name})
+	require.Nil(t, err)
+	tree, err = net.ChangeChainTreeOwner(tree, []string{crypto.PubkeyToAddress(key.PublicKey).String()})
+	require.Nil(t, err)
+	return key, tree
+}
+
+func TestChat(t *testing.T) {
+	net := network.NewLocalNetwork()
+	stream := ui.NewTestStream(t)
+
+	simulatedUI, game := setupUiAndGame(t, stream, net)
+	defer rootCtx.Stop(simulatedUI)
+	defer rootCtx.Stop(game)
+
+	playerTree, err := GetPlayerTree(net)
+	require.Nil(t, err)
+	homeDid := playerTree.HomeLocation.MustId()
+
+	jasonKey, jasonTree := newChatSender(t, net, "jason")
+	impostorKey, err := crypto.GenerateKey()
+	require.Nil(t, err)
+
+	stream.ExpectMessage("you say: hello there", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "say hello there"})
+	stream.Wait()
+
+	stream.ExpectMessage("waves", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "/me waves"})
+	stream.Wait()
+
+	stream.ExpectMessage("you shout: anyone?", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "shout anyone?"})
+	stream.Wait()
+
+	forged := &jasonsgame.ChatMessage{
+		From:     jasonTree.MustId(),
+		Name:     "jason",
+		Message:  "i am not jason",
+		Location: homeDid,
+		SentAt:   time.Now().Unix(),
+	}
+	require.Nil(t, chatlog.Sign(forged, impostorKey))
+	require.Nil(t, net.Community().Send(chatTopicFor(net, homeDid), forged))
+
+	said := &jasonsgame.ChatMessage{
+		From:     jasonTree.MustId(),
+		Name:     "the real jason",
+		Message:  "hi",
+		Location: homeDid,
+		SentAt:   time.Now().Unix(),
+	}
+	require.Nil(t, chatlog.Sign(said, jasonKey))
+
+	// shown by the name on the sender's tree, not the one they sent
+	stream.ExpectMessage("jason says: hi", 2*time.Second)
+	require.Nil(t, net.Community().Send(chatTopicFor(net, homeDid), said))
+	stream.Wait()
+
+	// replayed and stale messages are ignored
+	require.Nil(t, net.Community().Send(chatTopicFor(net, homeDid), said))
+	stale := &jasonsgame.ChatMessage{
+		From:     jasonTree.MustId(),
+		Message:  "from long ago",
+		Location: homeDid,
+		SentAt:   time.Now().Add(-2 * chatlog.MaxAge).Unix(),
+	}
+	require.Nil(t, chatlog.Sign(stale, jasonKey))
+	require.Nil(t, net.Community().Send(chatTopicFor(net, homeDid), stale))
+
+	shouted := &jasonsgame.ShoutMessage{
+		From:     jasonTree.MustId(),
+		Name:     "jason",
+		Message:  "over here",
+		Location: "did:tupelo:elsewhere",
+		SentAt:   time.Now().Unix(),
+	}
+	require.Nil(t, chatlog.Sign(shouted, jasonKey))
+
+	stream.ExpectMessage("you hear jason shout from nearby: over here", 2*time.Second)
+	require.Nil(t, net.Community().Send(chatTopicFor(net, homeDid), shouted))
+	stream.Wait()
+
+	whispered := &jasonsgame.WhisperMessage{
+		From:    jasonTree.MustId(),
+		To:      playerTree.Did(),
+		Name:    "jason",
+		Message: "psst",
+		SentAt:  time.Now().Unix(),
+	}
+	require.Nil(t, chatlog.Sign(whispered, jasonKey))
+
+	stream.ExpectMessage("jason whispers: psst", 2*time.Second)
+	require.Nil(t, net.Community().Send(whisperTopicFor(net, playerTree.Did()), whispered))
+	stream.Wait()
+
+	stream.ExpectMessage(fmt.Sprintf("you whisper to %s: hello", jasonTree.MustId()), 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: fmt.Sprintf("whisper %s hello", jasonTree.MustId())})
+	stream.Wait()
+
+	saidCount := 0
+	for _, msg := range stream.GetMessages() {
+		if userMessage := msg.GetUserMessage(); userMessage != nil {
+			if userMessage.Message == "jason says: hi" {
+				saidCount++
+			}
+			require.NotContains(t, userMessage.Message, "i am not jason")
+			require.NotContains(t, userMessage.Message, "from long ago")
+			require.NotContains(t, userMessage.Message, "the real jason")
+		}
+	}
+	require.Equal(t, 1, saidCount)
+}
+
+func TestChatHistory(t *testing.T) {
+	net := network.NewLocalNetwork()
+	stream := ui.NewTestStream(t)
+
+	simulatedUI, game := setupUiAndGame(t, stream, net)
+	defer rootCtx.Stop(simulatedUI)
+	defer rootCtx.Stop(game)
+
+	playerTree, err := GetPlayerTree(net)
+	require.Nil(t, err)
+	homeDid := playerTree.HomeLocation.MustId()
+
+	stream.ExpectMessage("chat isn't kept here", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "history"})
+	stream.Wait()
+
+	stream.ExpectMessage("recent chat will be kept here", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "enable chat log"})
+	stream.Wait()
+
+	stream.ExpectMessage("nobody has said anything here yet", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "history"})
+	stream.Wait()
+
+	stream.ExpectMessage("you say: hello", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "say hello"})
+	stream.Wait()
+
+	jasonKey, jasonTree := newChatSender(t, net, "jason")
+
+	said := &jasonsgame.ChatMessage{
+		From:     jasonTree.MustId(),
+		Name:     "jason",
+		Message:  "hi",
+		Location: homeDid,
+		SentAt:   time.Now().Unix() + 1,
+	}
+	require.Nil(t, chatlog.Sign(said, jasonKey))
+
+	stream.ExpectMessage("jason says: hi", 2*time.Second)
+	require.Nil(t, net.Community().Send(chatTopicFor(net, homeDid), said))
+	stream.Wait()
+
+	stream.ExpectMessage("recent chat here:\n  > newb", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "history"})
+	stream.Wait()
+
+	stream.ExpectMessage("says: hello\n  > jason says: hi", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "history"})
+	stream.Wait()
+
+	stream.ExpectMessage("recent chat here:\n  > jason says: hi", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "history 1"})
+	stream.Wait()
+
+	stream.ExpectMessage("chat will no longer be kept here", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "disable chat log"})
+	stream.Wait()
+
+	stream.ExpectMessage("chat isn't kept here", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "history"})
+	stream.Wait()
+}
+
+func TestMailUnavailable(t *testing.T) {
+	net := network.NewLocalNetwork()
+	stream := ui.NewTestStream(t)
+
+	simulatedUI, game := setupUiAndGame(t, stream, net)
+	defer rootCtx.Stop(simulatedUI)
+	defer rootCtx.Stop(game)
+
+	stream.ExpectMessage("mail isn't available right now", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "inbox"})
+	stream.Wait()
+
+	stream.ExpectMessage("mail isn't available right now", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "mail did:tupelo:jason hello"})
+	stream.Wait()
+
+	playerTree, err := GetPlayerTree(net)
+	require.Nil(t, err)
+	pubKey, err := playerTree.EncryptionPubKey()
+	require.Nil(t, err)
+	require.Equal(t, crypto.FromECDSAPub(net.PublicKey()), pubKey)
+}
+
+func TestMail(t *testing.T) {
+	net := network.NewLocalNetwork()
+	stream := ui.NewTestStream(t)
+
+	simulatedUI, err := rootCtx.SpawnNamed(ui.NewUIProps(stream), t.Name()+"-ui")
+	require.Nil(t, err)
+	defer rootCtx.Stop(simulatedUI)
+
+	playerChain, err := net.CreateLocalChainTree("player")
+	require.Nil(t, err)
+	playerTree, err := CreatePlayerTree(net, playerChain.MustId())
+	require.Nil(t, err)
+	require.Nil(t, playerTree.SetEncryptionPubKey(crypto.FromECDSAPub(net.PublicKey())))
+
+	handler, err := mailbox.NewMailboxHandler(net)
+	require.Nil(t, err)
+	client := mail.NewClientWithHandler(net, handler)
+
+	jasonTree, err := net.CreateChainTree()
+	require.Nil(t, err)
+	jasonTree, err = net.UpdateChainTree(jasonTree, playerTreePath, &jasonsgame.Player{Name: "jason"})
+	require.Nil(t, err)
+	_, err = net.UpdateChainTree(jasonTree, strings.Join(mail.EncryptionPubKeyPath[2:], "/"), crypto.FromECDSAPub(net.PublicKey()))
+	require.Nil(t, err)
+
+	err = client.Send(playerTree.Did(), &jasonsgame.Mail{
+		From:   jasonTree.MustId(),
+		Body:   "meet me at the fountain",
+		SentAt: time.Now().Unix(),
+	})
+	require.Nil(t, err)
+
+	stream.ExpectMessage("you have an unread letter, type `inbox` to see it", 2*time.Second)
+	gameCfg := &GameConfig{PlayerTree: playerTree, UiActor: simulatedUI, Network: net, MailClient: client}
+	game, err := rootCtx.SpawnNamed(NewGameProps(gameCfg), t.Name()+"-game")
+	require.Nil(t, err)
+	defer rootCtx.Stop(game)
+	stream.Wait()
+
+	stream.ExpectMessage("1. from jason", 2*time.Second)
+	stream.ExpectMessage("meet me at the fountain (new)", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "inbox"})
+	stream.Wait()
+
+	stream.ExpectMessage("from jason", 2*time.Second)
+	stream.ExpectMessage("meet me at the fountain", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "read mail 1"})
+	stream.Wait()
+
+	stream.ExpectMessage("there's no letter numbered 2", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "read mail 2"})
+	stream.Wait()
+
+	replies := make(chan *jasonsgame.MailboxResponse, 1)
+	_, err = net.Community().Subscribe(net.Community().TopicFor(mailbox.ReplyTopic(jasonTree.MustId())), func(ctx context.Context, _ *messages.Envelope, msg proto.Message) {
+		replies <- msg.(*jasonsgame.MailboxResponse)
+	})
+	require.Nil(t, err)
+
+	stream.ExpectMessage("your letter to jason is on its way", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "reply 1 see you there"})
+	stream.Wait()
+
+	require.Nil(t, client.Request(jasonTree.MustId()))
+	select {
+	case response := <-replies:
+		require.Len(t, response.Mail, 1)
+		reply, err := client.Open(response.Mail[0])
+		require.Nil(t, err)
+		require.Equal(t, playerTree.Did(), reply.From)
+		require.Equal(t, "see you there", reply.Body)
+		require.NotEmpty(t, reply.InReplyTo)
+	case <-time.After(1 * time.Second):
+		require.Fail(t, "timeout waiting for the reply")
+	}
+
+	stream.ExpectMessage("deleted the letter from jason", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "delete mail 1"})
+	stream.Wait()
+
+	stream.ExpectMessage("your inbox is empty", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "inbox"})
+	stream.Wait()
+}
+
+func TestPortalRequests(t *testing.T) {
+	net := network.NewLocalNetwork()
+	stream := ui.NewTestStream(t)
+
+	simulatedUI, game := setupUiAndGame(t, stream, net)
+	defer rootCtx.Stop(simulatedUI)
+	defer rootCtx.Stop(game)
+
+	playerTree, err := GetPlayerTree(net)
+	require.Nil(t, err)
+	homeDid := playerTree.HomeLocation.MustId()
+
+	stream.ExpectMessage("this is your land", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "request portal"})
+	stream.Wait()
+
+	stream.ExpectMessage("nobody has asked to build a portal on your land", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "portal requests"})
+	stream.Wait()
+
+	// everything on a local network is owned by the same key, so the
+	// visitor's land and tree are too
+	visitor, err := net.CreateChainTree()
+	require.Nil(t, err)
+	visitorLand, err := net.CreateChainTree()
+	require.Nil(t, err)
+
+	request := &jasonsgame.OpenPortalMessage{
+		From:     visitor.MustId(),
+		To:       homeDid,
+		ToLandId: visitorLand.MustId(),
+	}
+	require.Nil(t, signPortalMessage(request, net.PrivateKey()))
+
+	stream.ExpectMessage("would like to build a portal at", 2*time.Second)
+	err = net.Community().Send(portalRequestTopic(net, crypto.PubkeyToAddress(*net.PublicKey()).String()), request)
+	require.Nil(t, err)
+	stream.Wait()
+
+	stream.ExpectMessage("1. "+visitor.MustId()+" would like a portal at", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "portal requests"})
+	stream.Wait()
+
+	stream.ExpectMessage("there's no portal request numbered 2", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "accept portal request 2"})
+	stream.Wait()
+
+	stream.ExpectMessage("built a portal to "+visitorLand.MustId(), 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "accept portal request 1"})
+	stream.Wait()
+
+	home, err := net.GetTree(homeDid)
+	require.Nil(t, err)
+	portal, err := NewLocationTree(net, home).GetPortal()
+	require.Nil(t, err)
+	require.Equal(t, visitorLand.MustId(), portal.To)
+
+	stream.ExpectMessage("nobody has asked to build a portal on your land", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "portal requests"})
+	stream.Wait()
+
+	// requests aren't accepted once there's already a portal
+	stream.ExpectMessage("would like to build a portal at", 2*time.Second)
+	err = net.Community().Send(portalRequestTopic(net, crypto.PubkeyToAddress(*net.PublicKey()).String()), request)
+	require.Nil(t, err)
+	stream.Wait()
+
+	stream.ExpectMessage("there is already a portal there", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "accept portal request 1"})
+	stream.Wait()
+}
+
+func TestEditLocationInteractions(t *testing.T) {
+	net := network.NewLocalNetwork()
+	stream := ui.NewTestStream(t)
+
+	simulatedUI, game := setupUiAndGame(t, stream, net)
+	defer rootCtx.Stop(simulatedUI)
+	defer rootCtx.Stop(game)
+
+	playerTree, err := GetPlayerTree(net)
+	require.Nil(t, err)
+
+	err = playerTree.HomeLocation.AddInteraction(&RespondInteraction{Command: "wave", Response: "nobody wavse back"})
+	require.Nil(t, err)
+	time.Sleep(50 * time.Millisecond)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "refresh"})
+
+	stream.ExpectMessage("wave (RespondInteraction): nobody wavse back", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "list interactions"})
+	stream.Wait()
+
+	stream.ExpectMessage("updated the interaction wave", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "edit interaction wave = nobody waves back"})
+	stream.Wait()
+
+	stream.ExpectMessage("nobody waves back", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "wave"})
+	stream.Wait()
+
+	stream.ExpectMessage("updated the interaction wave hello", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "rename interaction wave = wave hello"})
+	stream.Wait()
+
+	stream.ExpectMessage("updated the interaction wave hello", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "edit interaction wave hello = type command = yes"})
+	stream.Wait()
+
+	stream.ExpectMessage("type command = yes", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "wave hello"})
+	stream.Wait()
+
+	stream.ExpectMessage("removed the interaction wave hello", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "remove interaction wave hello"})
+	stream.Wait()
+
+	stream.ExpectMessage("I'm sorry I don't understand", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "wave hello"})
+	stream.Wait()
+}
+
+func TestLocationAccess(t *testing.T) {
+	net := network.NewLocalNetwork()
+	stream := ui.NewTestStream(t)
+
+	simulatedUI, game := setupUiAndGame(t, stream, net)
+	defer rootCtx.Stop(simulatedUI)
+	defer rootCtx.Stop(game)
+
+	playerTree, err := GetPlayerTree(net)
+	require.Nil(t, err)
+
+	gardenTree, err := net.CreateChainTree()
+	require.Nil(t, err)
+	garden := NewLocationTree(net, gardenTree)
+	err = garden.SetDescription("a walled garden")
+	require.Nil(t, err)
+	salt, hash, err := newPasswordHash("open sesame")
+	require.Nil(t, err)
+	err = garden.SetAccessPolicy(&jasonsgame.AccessPolicy{
+		Mode:         accessModePassword,
+		PasswordHash: hash,
+		PasswordSalt: salt,
+	})
+	require.Nil(t, err)
+
+	// everything on a local network is owned by the same key, so give the
+	// garden to someone else
+	key, err := crypto.GenerateKey()
+	require.Nil(t, err)
+	_, err = net.ChangeChainTreeOwner(garden.Tree(), []string{crypto.PubkeyToAddress(key.PublicKey).String()})
+	require.Nil(t, err)
+
+	err = playerTree.HomeLocation.AddInteraction(&ChangeLocationInteraction{Command: "go north", Did: gardenTree.MustId()})
+	require.Nil(t, err)
+	time.Sleep(50 * time.Millisecond)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "refresh"})
+
+	stream.ExpectMessage("that place is locked", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "go north"})
+	stream.Wait()
+
+	stream.ExpectMessage("that's not the right password", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "unlock abracadabra"})
+	stream.Wait()
+
+	stream.ExpectMessage("a walled garden", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "unlock open sesame"})
+	stream.Wait()
+
+	stream.ExpectMessage("you can only change who can come here on land you own", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "make public"})
+	stream.Wait()
+}
+
+func TestLocationAccessPolicy(t *testing.T) {
+	net := network.NewLocalNetwork()
+	stream := ui.NewTestStream(t)
+
+	simulatedUI, game := setupUiAndGame(t, stream, net)
+	defer rootCtx.Stop(simulatedUI)
+	defer rootCtx.Stop(game)
+
+	playerTree, err := GetPlayerTree(net)
+	require.Nil(t, err)
+
+	stream.ExpectMessage("only players you allow can come here now", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "make private"})
+	stream.Wait()
+
+	stream.ExpectMessage("did:tupelo:troll is banned from here", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "ban player did:tupelo:troll"})
+	stream.Wait()
+
+	stream.ExpectMessage("banned: did:tupelo:troll", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "who can come here"})
+	stream.Wait()
+
+	home, err := net.GetTree(playerTree.HomeLocation.MustId())
+	require.Nil(t, err)
+	policy, err := NewLocationTree(net, home).AccessPolicy()
+	require.Nil(t, err)
+	require.Equal(t, accessModeAllowlist, policy.Mode)
+	require.Equal(t, []string{"did:tupelo:troll"}, policy.Banned)
+}
+
+func TestBuilders(t *testing.T) {
+	net := network.NewLocalNetwork()
+	stream := ui.NewTestStream(t)
+
+	simulatedUI, game := setupUiAndGame(t, stream, net)
+	defer rootCtx.Stop(simulatedUI)
+	defer rootCtx.Stop(game)
+
+	playerTree, err := GetPlayerTree(net)
+	require.Nil(t, err)
+
+	key, err := crypto.GenerateKey()
+	require.Nil(t, err)
+	builderAddr := crypto.PubkeyToAddress(key.PublicKey).String()
+	builder, err := net.CreateChainTree()
+	require.Nil(t, err)
+	builder, err = net.ChangeChainTreeOwner(builder, []string{builderAddr})
+	require.Nil(t, err)
+
+	stream.ExpectMessage("nobody else builds here", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "builders"})
+	stream.Wait()
+
+	stream.ExpectMessage(builder.MustId()+" can build here now", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "grant builder " + builder.MustId()})
+	stream.Wait()
+
+	home, err := net.GetTree(playerTree.HomeLocation.MustId())
+	require.Nil(t, err)
+	auths, err := home.Authentications()
+	require.Nil(t, err)
+	require.Contains(t, auths, builderAddr)
+
+	stream.ExpectMessage("builders here:\n  > "+builder.MustId(), 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "builders"})
+	stream.Wait()
+
+	stream.ExpectMessage(" - by you", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "history here"})
+	stream.Wait()
+
+	stream.ExpectMessage(builder.MustId()+" can no longer build here", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "revoke builder " + builder.MustId()})
+	stream.Wait()
+
+	home, err = net.GetTree(playerTree.HomeLocation.MustId())
+	require.Nil(t, err)
+	auths, err = home.Authentications()
+	require.Nil(t, err)
+	require.NotContains(t, auths, builderAddr)
+}
+
+func TestBuildMode(t *testing.T) {
+	net := network.NewLocalNetwork()
+	stream := ui.NewTestStream(t)
+
+	simulatedUI, game := setupUiAndGame(t, stream, net)
+	defer rootCtx.Stop(simulatedUI)
+	defer rootCtx.Stop(game)
+
+	playerTree, err := GetPlayerTree(net)
+	require.Nil(t, err)
+	homeDid := playerTree.HomeLocation.MustId()
+
+	fetchHome := func() *LocationTree {
+		home, err := net.GetTree(homeDid)
+		require.Nil(t, err)
+		return NewLocationTree(net, home)
+	}
+
+	stream.ExpectMessage("type `build` to start building", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "describe here a quiet meadow"})
+	stream.Wait()
+
+	stream.ExpectMessage("I'm sorry I don't understand.", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "confirm"})
+	stream.Wait()
+
+	stream.ExpectMessage("you're building", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "build"})
+	stream.Wait()
+
+	stream.ExpectMessage("preview: this place will be described as \"a quiet meadow\"", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "describe here a quiet meadow"})
+	stream.Wait()
+
+	stream.ExpectMessage("built it", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "confirm"})
+	stream.Wait()
+
+	description, err := fetchHome().GetDescription()
+	require.Nil(t, err)
+	require.Equal(t, "a quiet meadow", description)
+
+	stream.ExpectMessage("preview: `wave` will respond with \"nobody waves back\"", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "add response wave = nobody waves back"})
+	stream.Wait()
+
+	stream.ExpectMessage("built it", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "confirm"})
+	stream.Wait()
+
+	stream.ExpectMessage("nobody waves back", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "wave"})
+	stream.Wait()
+
+	stream.ExpectMessage("undid your last build", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "undo"})
+	stream.Wait()
+
+	home := fetchHome()
+	wave, err := home.getInteractionFromTree(home, "wave")
+	require.Nil(t, err)
+	require.Nil(t, wave)
+	description, err = home.GetDescription()
+	require.Nil(t, err)
+	require.Equal(t, "a quiet meadow", description)
+
+	stream.ExpectMessage("/ isn't a property", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "set property / x"})
+	stream.Wait()
+
+	stream.ExpectMessage("weather//today isn't a property", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "set property weather//today sunny"})
+	stream.Wait()
+
+	stream.ExpectMessage("preview: weather will be set to \"sunny\"", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "set property weather sunny"})
+	stream.Wait()
+
+	stream.ExpectMessage("left it as it was", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "cancel"})
+	stream.Wait()
+
+	weather, err := fetchHome().getPath([]string{PropertiesPath, "weather"})
+	require.Nil(t, err)
+	require.Nil(t, weather)
+
+	stream.ExpectMessage("a new location will be made, with `north` leading there and `south` leading back here", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "add exit north to new location"})
+	stream.Wait()
+
+	stream.ExpectMessage("built it", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "confirm"})
+	stream.Wait()
+
+	home = fetchHome()
+	north, err := home.getInteractionFromTree(home, "north")
+	require.Nil(t, err)
+	require.IsType(t, &ChangeLocationInteraction{}, north)
+
+	stream.ExpectMessage("undid your last build", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "undo"})
+	stream.Wait()
+
+	home = fetchHome()
+	north, err = home.getInteractionFromTree(home, "north")
+	require.Nil(t, err)
+	require.Nil(t, north)
+
+	// properties are kept apart from what the game keeps up to date
+	stream.ExpectMessage("built it", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "set property description a loud meadow"})
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "confirm"})
+	stream.Wait()
+
+	description, err = fetchHome().GetDescription()
+	require.Nil(t, err)
+	require.Equal(t, "a quiet meadow", description)
+
+	stream.ExpectMessage("built it", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "set property weather sunny"})
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "confirm"})
+	stream.Wait()
+
+	err = fetchHome().updatePath([]string{PropertiesPath, "weather"}, "rainy")
+	require.Nil(t, err)
+
+	stream.ExpectMessage("this place has changed since you built that", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "undo"})
+	stream.Wait()
+
+	weather, err = fetchHome().getPath([]string{PropertiesPath, "weather"})
+	require.Nil(t, err)
+	require.Equal(t, "rainy", weather)
+
+	stream.ExpectMessage("built it", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "create object here lantern a rusty old lantern"})
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "confirm"})
+	stream.Wait()
+
+	inventory, err := trees.FindInventoryTree(net, homeDid)
+	require.Nil(t, err)
+	lanternDid, err := inventory.DidForName("lantern")
+	require.Nil(t, err)
+	require.NotEmpty(t, lanternDid)
+
+	stream.ExpectMessage("undid your last build", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "undo"})
+	stream.Wait()
+
+	inventory, err = trees.FindInventoryTree(net, homeDid)
+	require.Nil(t, err)
+	exists, err := inventory.Exists(lanternDid)
+	require.Nil(t, err)
+	require.False(t, exists)
+
+	stream.ExpectMessage("you put your tools away", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "stop building"})
+	stream.Wait()
+
+	stream.ExpectMessage("I'm sorry I don't understand.", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "undo"})
+	stream.Wait()
+}
+
+func TestBuildCosts(t *testing.T) {
+	net := network.NewLocalNetwork()
+	stream := ui.NewTestStream(t)
+
+	simulatedUI, err := rootCtx.SpawnNamed(ui.NewUIProps(stream), t.Name()+"-ui")
+	require.Nil(t, err)
+	defer rootCtx.Stop(simulatedUI)
+
+	playerChain, err := net.CreateLocalChainTree("player")
+	require.Nil(t, err)
+	playerTree, err := CreatePlayerTree(net, playerChain.MustId())
+	require.Nil(t, err)
+
+	sink, err := net.CreateChainTree()
+	require.Nil(t, err)
+
+	game, err := rootCtx.SpawnNamed(NewGameProps(&GameConfig{
+		PlayerTree:  playerTree,
+		UiActor:     simulatedUI,
+		Network:     net,
+		InkDID:      "did:tupelo:ink",
+		InkSinkDID:  sink.MustId(),
+		BuildPrices: BuildPrices{buildSetDescription: 3},
+	}), t.Name()+"-game")
+	require.Nil(t, err)
+	defer rootCtx.Stop(game)
+
+	stream.ExpectMessage("set-description costs 3 ink", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "build"})
+	stream.Wait()
+
+	stream.ExpectMessage("this costs 3 ink", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "describe here a quiet meadow"})
+	stream.Wait()
+
+	stream.ExpectMessage("that costs 3 ink, and you only have 0 ink", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "confirm"})
+	stream.Wait()
+
+	home, err := net.GetTree(playerTree.HomeLocation.MustId())
+	require.Nil(t, err)
+	description, err := NewLocationTree(net, home).GetDescription()
+	require.Nil(t, err)
+	require.NotEqual(t, "a quiet meadow", description)
+}
+
+// fakeInkNetwork keeps ink in memory, since the local network doesn't move
+// it. Like the remote network, it only sends and receives ink on trees owned
+// by its owner, so networks for different keys share a ledger.
+type fakeInkNetwork struct {
+	*network.LocalNetwork
+	owner  string
+	ledger *fakeInkLedger
+}
+
+type fakeInkLedger struct {
+	lock     sync.Mutex
+	balances map[string]uint64
+	sends    map[string]*fakeInkSend
+}
+
+type fakeInkSend struct {
+	to       string
+	amount   uint64
+	received bool
+}
+
+func newFakeInkNetwork(net *network.LocalNetwork) *fakeInkNetwork {
+	return &fakeInkNetwork{
+		LocalNetwork: net,
+		owner:        crypto.PubkeyToAddress(*net.PublicKey()).String(),
+		ledger: &fakeInkLedger{
+			balances: make(map[string]uint64),
+			sends:    make(map[string]*fakeInkSend),
+		},
+	}
+}
+
+// withOwner is the same network acting for owner
+func (n *fakeInkNetwork) withOwner(owner string) *fakeInkNetwork {
+	return &fakeInkNetwork{LocalNetwork: n.LocalNetwork, owner: owner, ledger: n.ledger}
+}
+
+func (n *fakeInkNetwork) mint(did string, amount uint64) {
+	n.ledger.lock.Lock()
+	defer n.ledger.lock.Unlock()
+	n.ledger.balances[did] += amount
+}
+
+func (n *fakeInkNetwork) checkOwner(tree *consensus.SignedChainTree) error {
+	auths, err := tree.Authentications()
+	if err != nil {
+		return err
+	}
+	for _, auth := range auths {
+		if auth == n.owner {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s isn't owned by %s", tree.MustId(), n.owner)
+}
+
+func (n *fakeInkNetwork) SendInk(tree *consensus.SignedChainTree, tokenName *consensus.TokenName, amount uint64, destinationChainId string) (*transactions.TokenPayload, error) {
+	if err := n.checkOwner(tree); err != nil {
+		return nil, err
+	}
+
+	n.ledger.lock.Lock()
+	defer n.ledger.lock.Unlock()
+	if n.ledger.balances[tree.MustId()] < amount {
+		return nil, fmt.Errorf("not enough ink")
+	}
+	n.ledger.balances[tree.MustId()] -= amount
+	id := fmt.Sprintf("send-%d", len(n.ledger.sends))
+	n.ledger.sends[id] = &fakeInkSend{to: destinationChainId, amount: amount}
+	return &transactions.TokenPayload{TransactionId: id}, nil
+}
+
+func (n *fakeInkNetwork) ReceiveInk(tree *consensus.SignedChainTree, tokenPayload *transactions.TokenPayload) error {
+	if err := n.checkOwner(tree); err != nil {
+		return err
+	}
+
+	n.ledger.lock.Lock()
+	defer n.ledger.lock.Unlock()
+	send, ok := n.ledger.sends[tokenPayload.TransactionId]
+	if !ok || send.received || send.to != tree.MustId() {
+		return fmt.Errorf("invalid token payload")
+	}
+	send.received = true
+	n.ledger.balances[tree.MustId()] += send.amount
+	return nil
+}
+
+func (n *fakeInkNetwork) InkBalance(tree *consensus.SignedChainTree, tokenName *consensus.TokenName) (uint64, error) {
+	n.ledger.lock.Lock()
+	defer n.ledger.lock.Unlock()
+	return n.ledger.balances[tree.MustId()], nil
+}
+
+func (n *fakeInkNetwork) balance(did string) uint64 {
+	n.ledger.lock.Lock()
+	defer n.ledger.lock.Unlock()
+	return n.ledger.balances[did]
+}
+
+func TestBuildCostsCharged(t *testing.T) {
+	net := newFakeInkNetwork(network.NewLocalNetwork())
+	stream := ui.NewTestStream(t)
+
+	simulatedUI, err := rootCtx.SpawnNamed(ui.NewUIProps(stream), t.Name()+"-ui")
+	require.Nil(t, err)
+	defer rootCtx.Stop(simulatedUI)
+
+	playerChain, err := net.CreateLocalChainTree("player")
+	require.Nil(t, err)
+	playerTree, err := CreatePlayerTree(net, playerChain.MustId())
+	require.Nil(t, err)
+	net.mint(playerTree.Did(), 10)
+
+	// the sink is owned by someone else, who runs its handler
+	sinkKey, err := crypto.GenerateKey()
+	require.Nil(t, err)
+	sinkNet := net.withOwner(crypto.PubkeyToAddress(sinkKey.PublicKey).String())
+	sink, err := net.CreateChainTree()
+	require.Nil(t, err)
+
+	inkDID := "did:tupelo:ink"
+	sinkHandler := inksink.NewInkSinkHandlerFor(sinkNet, sink.MustId(), &consensus.TokenName{ChainTreeDID: inkDID, LocalName: "ink"})
+	serviceTree, err := net.CreateChainTree()
+	require.Nil(t, err)
+	sinkService := rootCtx.Spawn(service.NewServiceActorPropsWithTree(sinkNet, sinkHandler, serviceTree))
+	defer rootCtx.Stop(sinkService)
+	serviceDid, err := rootCtx.RequestFuture(sinkService, &service.GetServiceDid{}, 5*time.Second).Result()
+	require.Nil(t, err)
+	// give time for the service to be subscribed
+	time.Sleep(200 * time.Millisecond)
+
+	sink, err = net.UpdateChainTree(sink, handlers.HandlerPath, serviceDid.(string))
+	require.Nil(t, err)
+	_, err = net.ChangeChainTreeOwner(sink, []string{sinkNet.owner})
+	require.Nil(t, err)
+
+	// the player can't take ink into a sink they don't own
+	tokenPayload, err := net.SendInk(playerTree.ChainTree(), &consensus.TokenName{ChainTreeDID: inkDID, LocalName: "ink"}, 1, sink.MustId())
+	require.Nil(t, err)
+	sink, err = net.GetTree(sink.MustId())
+	require.Nil(t, err)
+	require.NotNil(t, net.ReceiveInk(sink, tokenPayload))
+	net.mint(playerTree.Did(), 1)
+
+	game, err := rootCtx.SpawnNamed(NewGameProps(&GameConfig{
+		PlayerTree:  playerTree,
+		UiActor:     simulatedUI,
+		Network:     net,
+		InkDID:      inkDID,
+		InkSinkDID:  sink.MustId(),
+		BuildPrices: BuildPrices{buildSetDescription: 3, buildCreateObject: 2},
+	}), t.Name()+"-game")
+	require.Nil(t, err)
+	defer rootCtx.Stop(game)
+
+	stream.ExpectMessage("you're building", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "build"})
+	stream.Wait()
+
+	stream.ExpectMessage("this costs 3 ink", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "describe here a quiet meadow"})
+	stream.Wait()
+
+	stream.ExpectMessage("built it", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "confirm"})
+	stream.Wait()
+
+	require.Equal(t, uint64(7), net.balance(playerTree.Did()))
+	require.Equal(t, uint64(3), net.balance(sink.MustId()))
+
+	stream.ExpectMessage("lantern has been created", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "create object lantern"})
+	stream.Wait()
+
+	stream.ExpectMessage("this costs 2 ink", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "create object here lantern a rusty old lantern"})
+	stream.Wait()
+
+	stream.ExpectMessage("that couldn't be built, so your 2 ink was given back", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "confirm"})
+	stream.Wait()
+
+	require.Equal(t, uint64(7), net.balance(playerTree.Did()))
+	require.Equal(t, uint64(3), net.balance(sink.MustId()))
+}
+
+func TestBuildCostsWithoutSinkHandler(t *testing.T) {
+	net := newFakeInkNetwork(network.NewLocalNetwork())
+	stream := ui.NewTestStream(t)
+
+	simulatedUI, err := rootCtx.SpawnNamed(ui.NewUIProps(stream), t.Name()+"-ui")
+	require.Nil(t, err)
+	defer rootCtx.Stop(simulatedUI)
+
+	playerChain, err := net.CreateLocalChainTree("player")
+	require.Nil(t, err)
+	playerTree, err := CreatePlayerTree(net, playerChain.MustId())
+	require.Nil(t, err)
+	net.mint(playerTree.Did(), 10)
+
+	sinkKey, err := crypto.GenerateKey()
+	require.Nil(t, err)
+	sink, err := net.CreateChainTree()
+	require.Nil(t, err)
+	sink, err = net.ChangeChainTreeOwner(sink, []string{crypto.PubkeyToAddress(sinkKey.PublicKey).String()})
+	require.Nil(t, err)
+
+	game, err := rootCtx.SpawnNamed(NewGameProps(&GameConfig{
+		PlayerTree:  playerTree,
+		UiActor:     simulatedUI,
+		Network:     net,
+		InkDID:      "did:tupelo:ink",
+		InkSinkDID:  sink.MustId(),
+		BuildPrices: BuildPrices{buildSetDescription: 3},
+	}), t.Name()+"-game")
+	require.Nil(t, err)
+	defer rootCtx.Stop(game)
+
+	stream.ExpectMessage("you're building", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "build"})
+	stream.Wait()
+
+	stream.ExpectMessage("this costs 3 ink", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "describe here a quiet meadow"})
+	stream.Wait()
+
+	stream.ExpectMessage("the ink sink has no handler to take ink", 2*time.Second)
+	rootCtx.Send(game, &jasonsgame.UserInput{Message: "confirm"})
+	stream.Wait()
+
+	require.Equal(t, uint64(10), net.balance(playerTree.Did()))
+	home, err := net.GetTree(playerTree.HomeLocation.MustId())
+	require.Nil(t, err)
+	description, err := NewLocationTree(net, home).GetDescription()
+	require.Nil(t, err)
+	require.NotEqual(t, "a quiet meadow", description)
+}
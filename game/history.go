@@ -0,0 +1,251 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AsynkronIT/protoactor-go/actor"
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/quorumcontrol/jasons-game/game/trees"
+)
+
+const historyTimeFormat = "2006-01-02 15:04"
+
+// historyView is the version of a location the player is looking at
+type historyView struct {
+	did    string
+	height uint64
+}
+
+// historyViewCommands can be used while viewing the past, since none of
+// them change anything
+var historyViewCommands = map[string]bool{
+	"history-here":          true,
+	"view-here":             true,
+	"stop-viewing":          true,
+	"tip-zoom":              true,
+	"player-inventory-list": true,
+	"map":                   true,
+	"journal":               true,
+	"bookmark-list":         true,
+	"who":                   true,
+	"chat-history":          true,
+	"inbox":                 true,
+	"read-mail":             true,
+	"portal-requests":       true,
+	"show-access":           true,
+	"list-builders":         true,
+	"quest-list":            true,
+	"quest-details":         true,
+	"list-interactions":     true,
+	"help":                  true,
+	"exit":                  true,
+}
+
+func (g *Game) currentLocationTree() (*LocationTree, error) {
+	tree, err := g.network.GetTree(g.locationDid)
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching location")
+	}
+	if tree == nil {
+		return nil, fmt.Errorf("could not find location %s", g.locationDid)
+	}
+	return NewLocationTree(g.network, tree), nil
+}
+
+func (g *Game) handleHistoryHere(actorCtx actor.Context) error {
+	ctx := context.TODO()
+
+	location, err := g.currentLocationTree()
+	if err != nil {
+		return err
+	}
+
+	versions, err := trees.History(ctx, location.Tree().ChainTree)
+	if err != nil {
+		return errors.Wrap(err, "error fetching history")
+	}
+
+	ownershipChanges, err := trees.OwnershipChanges(ctx, location.Tree().ChainTree)
+	if err != nil {
+		return errors.Wrap(err, "error fetching ownership changes")
+	}
+	changedHands := make(map[uint64]bool, len(ownershipChanges))
+	for _, change := range ownershipChanges {
+		changedHands[change.Height] = true
+	}
+
+	toSend := indentedList{"versions of this place, type `view here at <height>` to see one:"}
+	for idx, version := range versions {
+		then, err := location.AtTip(version.Tip)
+		if err != nil {
+			return err
+		}
+		updatedAt, err := then.UpdatedAt()
+		if err != nil {
+			return err
+		}
+
+		line := fmt.Sprintf("height %d - %s", version.Height, formatHistoryTime(updatedAt))
+		switch {
+		case idx == len(versions)-1:
+			line += " - created"
+		case changedHands[version.Height]:
+			line += " - changed hands"
+		}
+		signers, err := then.Signers()
+		if err != nil {
+			return err
+		}
+		if len(signers) > 0 {
+			// builders come and go, so signers are whoever was building then
+			builders, err := then.Builders()
+			if err != nil {
+				return err
+			}
+			names := make([]string, len(signers))
+			for i, signer := range signers {
+				names[i] = g.signerName(signer, builders)
+			}
+			line += " - by " + strings.Join(names, " and ")
+		}
+		toSend = append(toSend, line)
+	}
+	g.sendUserMessage(actorCtx, toSend)
+	return nil
+}
+
+func formatHistoryTime(updatedAt time.Time) string {
+	if updatedAt.IsZero() {
+		return "unknown time"
+	}
+	return updatedAt.Format(historyTimeFormat)
+}
+
+func (g *Game) handleViewHere(actorCtx actor.Context, args string) error {
+	heightArg := strings.TrimSpace(args)
+	if heightArg == "" {
+		g.sendUserMessage(actorCtx, "which height? e.g. `view here at 2`, type `history here` to see them")
+		return nil
+	}
+
+	height, err := strconv.ParseUint(heightArg, 10, 64)
+	if err != nil {
+		g.sendUserMessage(actorCtx, fmt.Sprintf("%s isn't a height, e.g. `view here at 2`", heightArg))
+		return nil
+	}
+
+	location, err := g.currentLocationTree()
+	if err != nil {
+		return err
+	}
+
+	then, err := location.AtHeight(height)
+	if err != nil {
+		log.Debugf("error fetching %s at height %d: %v", g.locationDid, height, err)
+		g.sendUserMessage(actorCtx, fmt.Sprintf("there is no height %d here, type `history here` to see them", height))
+		return nil
+	}
+
+	return g.viewHistory(actorCtx, then)
+}
+
+func (g *Game) handleTipZoom(actorCtx actor.Context, tip string) error {
+	tipCid, err := cid.Parse(tip)
+	if err != nil {
+		g.sendUserMessage(actorCtx, fmt.Sprintf("error parsing tip (%s): %v", tip, err))
+		return errors.Wrap(err, fmt.Sprintf("error parsing tip (%s)", tip))
+	}
+	tree, err := g.network.GetTreeByTip(tipCid)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("error getting tip (%s)", tip))
+	}
+
+	return g.viewHistory(actorCtx, NewLocationTree(g.network, tree))
+}
+
+// viewHistory shows the player a past version of a location, and keeps
+// them from changing anything until they stop viewing it
+func (g *Game) viewHistory(actorCtx actor.Context, then *LocationTree) error {
+	height, err := then.Height()
+	if err != nil {
+		return err
+	}
+
+	toSend, err := g.describeHistoricalLocation(then, height)
+	if err != nil {
+		return err
+	}
+
+	g.viewing = &historyView{did: then.MustId(), height: height}
+	g.sendUserMessage(actorCtx, toSend)
+	return nil
+}
+
+func (g *Game) describeHistoricalLocation(then *LocationTree, height uint64) (indentedList, error) {
+	updatedAt, err := then.UpdatedAt()
+	if err != nil {
+		return nil, err
+	}
+
+	description, err := then.GetDescription()
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching description")
+	}
+	if description == "" {
+		description = "there was nothing here yet"
+	}
+
+	interactions, err := then.InteractionsList()
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching interactions")
+	}
+	commands := make([]string, 0, len(interactions))
+	for _, interaction := range interactions {
+		if !interaction.GetHidden() {
+			commands = append(commands, interaction.GetCommand())
+		}
+	}
+	sort.Strings(commands)
+
+	objects, err := trees.NewInventoryTree(g.network, then.Tree()).All()
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching inventory")
+	}
+	objectNames := make([]string, 0, len(objects))
+	for _, name := range objects {
+		objectNames = append(objectNames, name)
+	}
+	sort.Strings(objectNames)
+
+	toSend := indentedList{
+		fmt.Sprintf("this place at height %d, %s:", height, formatHistoryTime(updatedAt)),
+		description,
+	}
+	if len(commands) > 0 {
+		toSend = append(toSend, "you could: "+strings.Join(commands, ", "))
+	}
+	if len(objectNames) > 0 {
+		toSend = append(toSend, "you would see: "+strings.Join(objectNames, ", "))
+	}
+	toSend = append(toSend, "type `stop viewing` to come back to the present")
+	return toSend, nil
+}
+
+func (g *Game) handleStopViewing(actorCtx actor.Context) error {
+	if g.viewing == nil {
+		g.sendUserMessage(actorCtx, "you aren't viewing the past")
+		return nil
+	}
+
+	g.viewing = nil
+	g.sendUserMessage(actorCtx, "back to the present")
+	g.sendUILocation(actorCtx)
+	return nil
+}
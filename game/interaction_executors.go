@@ -0,0 +1,323 @@
+package game
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Executors for the interaction types built into the game, registered in
+// interactions.go. Most of them delegate to the matching Game handler.
+
+func executeRespondInteraction(ctx *InteractionContext, interaction Interaction, args string) error {
+	ctx.SendUserMessage(ctx.game.renderResponse(ctx.actorCtx, interaction.(*RespondInteraction).Response))
+	return nil
+}
+
+func executeBuildPortalInteraction(ctx *InteractionContext, interaction Interaction, args string) error {
+	return ctx.game.handleBuildPortal(ctx.actorCtx, args)
+}
+
+func executeDeletePortalInteraction(ctx *InteractionContext, interaction Interaction, args string) error {
+	return ctx.game.handleDeletePortal(ctx.actorCtx, args)
+}
+
+func executeChangeLocationInteraction(ctx *InteractionContext, interaction Interaction, args string) error {
+	ctx.game.handleChangeLocation(ctx.actorCtx, interaction.(*ChangeLocationInteraction).Did)
+	return nil
+}
+
+func executeChangeNamedLocationInteraction(ctx *InteractionContext, interaction Interaction, args string) error {
+	ctx.game.handleChangeNamedLocation(ctx.actorCtx, interaction.(*ChangeNamedLocationInteraction).Name)
+	return nil
+}
+
+func executeDropObjectInteraction(ctx *InteractionContext, interaction Interaction, args string) error {
+	return ctx.game.handleDropObject(ctx.actorCtx, ctx.command, interaction.(*DropObjectInteraction))
+}
+
+func executePickUpObjectInteraction(ctx *InteractionContext, interaction Interaction, args string) error {
+	return ctx.game.handlePickUpObject(ctx.actorCtx, interaction.(*PickUpObjectInteraction))
+}
+
+func executeGetTreeValueInteraction(ctx *InteractionContext, interaction Interaction, args string) error {
+	return ctx.game.handleGetTreeValueInteraction(ctx.actorCtx, interaction.(*GetTreeValueInteraction))
+}
+
+func executeSetTreeValueInteraction(ctx *InteractionContext, interaction Interaction, args string) error {
+	return ctx.game.handleSetTreeValueInteraction(ctx.actorCtx, interaction.(*SetTreeValueInteraction), args)
+}
+
+func executeLookAroundInteraction(ctx *InteractionContext, interaction Interaction, args string) error {
+	return ctx.game.handleLocationInventoryList(ctx.actorCtx)
+}
+
+func executeCreateObjectInteraction(ctx *InteractionContext, interaction Interaction, args string) error {
+	return ctx.game.handleCreateObjectInteraction(ctx.actorCtx, interaction.(*CreateObjectInteraction))
+}
+
+func executeCipherInteraction(ctx *InteractionContext, interaction Interaction, args string) error {
+	cipher := interaction.(*CipherInteraction)
+	did := ctx.attachedOrLocationDid()
+
+	return guessCipher(ctx, did, cipher.GetCommand(), cipher.Limit, func() func(ctx *InteractionContext) error {
+		nextInteraction, unsealed, err := cipher.Unseal(args)
+
+		return func(ctx *InteractionContext) error {
+			if err != nil {
+				return err
+			}
+			if unsealed {
+				err = ctx.Player().SetCipherAttempts(did, cipher.GetCommand(), nil)
+				if err != nil {
+					return err
+				}
+			} else {
+				ctx.Failed()
+			}
+			return ctx.Execute(nextInteraction, args)
+		}
+	})
+}
+
+func executeMultiPartCipherInteraction(ctx *InteractionContext, interaction Interaction, args string) error {
+	cipher := interaction.(*MultiPartCipherInteraction)
+	did := ctx.attachedOrLocationDid()
+
+	progressKey := cipherProgressKey(ctx.Network().PrivateKey(), did, cipher.GetCommand())
+
+	return guessCipher(ctx, did, cipher.GetCommand(), cipher.Limit, func() func(ctx *InteractionContext) error {
+		idx, share, err := cipher.MatchFragment(args)
+
+		return func(ctx *InteractionContext) error {
+			if err != nil {
+				return err
+			}
+
+			if idx < 0 {
+				ctx.Failed()
+				failureInteraction, err := cipher.FailureInteraction()
+				if err != nil {
+					return err
+				}
+				if failureInteraction == nil {
+					ctx.SendUserMessage("nothing happens")
+					return nil
+				}
+				return ctx.Execute(failureInteraction, args)
+			}
+
+			progress, err := ctx.Player().CipherProgress(did, cipher.GetCommand())
+			if err != nil {
+				return err
+			}
+			shares, err := openCipherProgress(&progressKey, progress, len(cipher.SealedShares))
+			if err != nil {
+				return err
+			}
+
+			// only finding a new fragment earns more guesses, repeating one
+			// that's already been found doesn't
+			if len(shares[idx]) == 0 {
+				shares[idx] = share
+
+				progress, err = sealCipherProgress(&progressKey, shares)
+				if err != nil {
+					return err
+				}
+				err = ctx.Player().SetCipherProgress(did, cipher.GetCommand(), progress)
+				if err != nil {
+					return err
+				}
+				err = ctx.Player().SetCipherAttempts(did, cipher.GetCommand(), nil)
+				if err != nil {
+					return err
+				}
+			}
+
+			if found := countCipherShares(shares); found < len(shares) {
+				// progress, but not solved yet
+				ctx.Failed()
+				progressInteraction, err := cipher.ProgressInteraction()
+				if err != nil {
+					return err
+				}
+				if progressInteraction == nil {
+					ctx.SendUserMessage(fmt.Sprintf("that's %d of %d", found, len(shares)))
+					return nil
+				}
+				return ctx.Execute(progressInteraction, args)
+			}
+
+			nextInteraction, err := cipher.Unseal(shares)
+			if err != nil {
+				return err
+			}
+			return ctx.Execute(nextInteraction, args)
+		}
+	})
+}
+
+func cipherAttemptsKey(did string, command string) string {
+	return did + "/" + command
+}
+
+// guessCipher counts the guess on the player tree before any work is done,
+// so guesses that are still being checked count towards the limit too, then
+// checks it with RunAsync. Only one guess at each cipher is checked at a
+// time, since each one derives a key with scrypt.
+func guessCipher(ctx *InteractionContext, did string, command string, limit *CipherLimit, work func() func(ctx *InteractionContext) error) error {
+	key := cipherAttemptsKey(did, command)
+	if ctx.game.unsealing[key] {
+		return &InteractionLimitError{Message: "you're still working out your last guess"}
+	}
+
+	attempts, err := ctx.Player().CipherAttempts(did, command)
+	if err != nil {
+		return err
+	}
+	attempts, err = limit.Check(attempts, time.Now())
+	if err != nil {
+		return err
+	}
+	err = ctx.Player().SetCipherAttempts(did, command, attempts)
+	if err != nil {
+		return err
+	}
+
+	ctx.game.unsealing[key] = true
+	ctx.RunAsync(func() func(ctx *InteractionContext) error {
+		then := work()
+
+		return func(ctx *InteractionContext) error {
+			delete(ctx.game.unsealing, key)
+			return then(ctx)
+		}
+	})
+	return nil
+}
+
+func executeChainedInteraction(ctx *InteractionContext, interaction Interaction, args string) error {
+	interactions, err := interaction.(*ChainedInteraction).Interactions()
+	if err != nil {
+		return err
+	}
+	for _, nextInteraction := range interactions {
+		err = ctx.Execute(nextInteraction, args)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func executeConditionalInteraction(ctx *InteractionContext, interaction Interaction, args string) error {
+	conditional := interaction.(*ConditionalInteraction)
+
+	met, err := ctx.game.checkCondition(ctx.actorCtx, conditional.Condition)
+	if err != nil {
+		return err
+	}
+
+	var nextInteraction Interaction
+	if met {
+		nextInteraction, err = conditional.SuccessInteraction()
+	} else {
+		ctx.Failed()
+		nextInteraction, err = conditional.FailureInteraction()
+	}
+	if err != nil {
+		return err
+	}
+
+	if nextInteraction == nil {
+		ctx.SendUserMessage("nothing happens")
+		return nil
+	}
+
+	return ctx.Execute(nextInteraction, args)
+}
+
+func executeRandomInteraction(ctx *InteractionContext, interaction Interaction, args string) error {
+	random := interaction.(*RandomInteraction)
+
+	location, err := ctx.Location()
+	if err != nil {
+		return err
+	}
+
+	use, err := ctx.nextRandomUse(random.GetCommand())
+	if err != nil {
+		return err
+	}
+
+	playerDid, locationTip := ctx.Player().Did(), location.Tip().String()
+	seed := RandomSeed(playerDid, locationTip, random.GetCommand(), use)
+	roll, err := random.Roll(seed)
+	if err != nil {
+		return err
+	}
+
+	if random.ShowRoll {
+		ctx.SendUserMessage(indentedList{
+			fmt.Sprintf("you rolled %d out of %d (seed %x)", roll.Roll+1, roll.Total, roll.Seed),
+			fmt.Sprintf("from player %s, location tip %s, command %s and use %d", playerDid, locationTip, random.GetCommand(), use),
+		})
+	}
+
+	return ctx.Execute(roll.Interaction, args)
+}
+
+// nextRandomUse counts, on the player tree, how many times the player has
+// rolled command, so each roll gets a new seed even while the location stays
+// the same and anyone can re-derive it
+func (c *InteractionContext) nextRandomUse(command string) (uint64, error) {
+	did := c.attachedOrLocationDid()
+
+	use, err := c.Player().RandomUses(did, command)
+	if err != nil {
+		return 0, errors.Wrap(err, "error fetching random interaction uses")
+	}
+
+	err = c.Player().SetRandomUses(did, command, use+1)
+	if err != nil {
+		return 0, errors.Wrap(err, "error saving random interaction uses")
+	}
+	return use, nil
+}
+
+func executeLimitedInteraction(ctx *InteractionContext, interaction Interaction, args string) error {
+	limited := interaction.(*LimitedInteraction)
+	did := ctx.attachedOrLocationDid()
+
+	usage, err := ctx.Player().InteractionUsage(did, limited.GetCommand())
+	if err != nil {
+		return err
+	}
+
+	newUsage, err := limited.Check(usage, time.Now())
+	if err != nil {
+		return err
+	}
+
+	nextInteraction, err := limited.Interaction()
+	if err != nil {
+		return err
+	}
+
+	err = ctx.Execute(nextInteraction, args)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Player().SetInteractionUsage(did, limited.GetCommand(), newUsage)
+}
+
+func executeDialogueInteraction(ctx *InteractionContext, interaction Interaction, args string) error {
+	return ctx.game.startDialogue(ctx.actorCtx, ctx.command, interaction.(*DialogueInteraction))
+}
+
+func executeStartQuestInteraction(ctx *InteractionContext, interaction Interaction, args string) error {
+	return ctx.game.handleStartQuest(ctx.actorCtx, interaction.(*StartQuestInteraction).Did)
+}
@@ -0,0 +1,205 @@
+package game
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/AsynkronIT/protoactor-go/actor"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/pkg/errors"
+	"github.com/quorumcontrol/chaintree/typecaster"
+
+	"github.com/quorumcontrol/jasons-game/network"
+)
+
+// InteractionExecutor runs an interaction of a registered type when a player
+// enters its command. The interaction is always of the type it was registered with.
+type InteractionExecutor func(ctx *InteractionContext, interaction Interaction, args string) error
+
+// InteractionOption customizes how a registered interaction type is offered to players
+type InteractionOption func(*registeredInteraction)
+
+// OnlyInPlayerInventory only offers the interaction when it is attached to
+// an object in the player's bag of hodling
+func OnlyInPlayerInventory() InteractionOption {
+	return func(r *registeredInteraction) {
+		r.inPlayerInventory = true
+		r.outsidePlayerInventory = false
+	}
+}
+
+// OutsidePlayerInventory only offers the interaction when it is attached to
+// the current location or an object in it
+func OutsidePlayerInventory() InteractionOption {
+	return func(r *registeredInteraction) {
+		r.inPlayerInventory = false
+		r.outsidePlayerInventory = true
+	}
+}
+
+type registeredInteraction struct {
+	executor               InteractionExecutor
+	inPlayerInventory      bool
+	outsidePlayerInventory bool
+}
+
+var interactionRegistry = struct {
+	sync.RWMutex
+	byType map[reflect.Type]*registeredInteraction
+}{
+	byType: make(map[reflect.Type]*registeredInteraction),
+}
+
+// RegisterInteraction makes a protobuf interaction type storable on trees and
+// executable by the game. prototype should be a pointer to the generated
+// protobuf struct, e.g. &RespondInteraction{}. Registering the same type twice
+// replaces the executor.
+func RegisterInteraction(prototype Interaction, executor InteractionExecutor, opts ...InteractionOption) {
+	structVal := reflect.ValueOf(prototype)
+	if structVal.Kind() != reflect.Ptr || structVal.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("RegisterInteraction requires a pointer to a struct, got %T", prototype))
+	}
+	cbor.RegisterCborType(structVal.Elem().Interface())
+	typecaster.AddType(structVal.Elem().Interface())
+
+	registerInteractionExecutor(prototype, executor, opts...)
+}
+
+// registerInteractionExecutor is used for interactions that are generated by
+// the game rather than stored on trees, so don't need cbor registration
+func registerInteractionExecutor(prototype Interaction, executor InteractionExecutor, opts ...InteractionOption) {
+	if executor == nil {
+		panic(fmt.Sprintf("RegisterInteraction requires an executor for %T", prototype))
+	}
+
+	registered := &registeredInteraction{
+		executor:               executor,
+		inPlayerInventory:      true,
+		outsidePlayerInventory: true,
+	}
+	for _, opt := range opts {
+		opt(registered)
+	}
+
+	interactionRegistry.Lock()
+	defer interactionRegistry.Unlock()
+	interactionRegistry.byType[reflect.TypeOf(prototype)] = registered
+}
+
+func registeredInteractionFor(interaction Interaction) (*registeredInteraction, bool) {
+	interactionRegistry.RLock()
+	defer interactionRegistry.RUnlock()
+	registered, ok := interactionRegistry.byType[reflect.TypeOf(interaction)]
+	return registered, ok
+}
+
+// InteractionContext gives an InteractionExecutor access to the game, the
+// player and their current location while an interaction is running.
+type InteractionContext struct {
+	game     *Game
+	actorCtx actor.Context
+	command  *interactionCommand
+	outcome  *interactionOutcome
+}
+
+// interactionOutcome is shared by an interaction and the interactions it
+// executes, so only interactions that did what the player asked count
+// towards their quests
+type interactionOutcome struct {
+	failed bool
+	// pending is set by RunAsync, whose continuation has its own outcome
+	pending bool
+}
+
+func (o *interactionOutcome) succeeded() bool {
+	return !o.failed && !o.pending
+}
+
+func (c *InteractionContext) ActorContext() actor.Context {
+	return c.actorCtx
+}
+
+func (c *InteractionContext) Network() network.Network {
+	return c.game.network
+}
+
+func (c *InteractionContext) Player() *PlayerTree {
+	return c.game.playerTree
+}
+
+func (c *InteractionContext) LocationDid() string {
+	return c.game.locationDid
+}
+
+func (c *InteractionContext) Location() (*LocationTree, error) {
+	tree, err := c.game.network.GetTree(c.game.locationDid)
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching location")
+	}
+	if tree == nil {
+		return nil, fmt.Errorf("could not find location %s", c.game.locationDid)
+	}
+	return NewLocationTree(c.game.network, tree), nil
+}
+
+// Command is the command the player entered to trigger the interaction
+func (c *InteractionContext) Command() string {
+	return c.command.parse
+}
+
+// AttachedToDid is the did of the location or object the interaction came from
+func (c *InteractionContext) AttachedToDid() string {
+	return c.command.did
+}
+
+// attachedOrLocationDid is used to key per-player state for an interaction
+func (c *InteractionContext) attachedOrLocationDid() string {
+	if c.command.did != "" {
+		return c.command.did
+	}
+	return c.game.locationDid
+}
+
+func (c *InteractionContext) SendUserMessage(msg interface{}) {
+	c.game.sendUserMessage(c.actorCtx, msg)
+}
+
+// Failed marks the interaction as not having done what the player asked,
+// like a wrong guess, so it doesn't count towards their quests
+func (c *InteractionContext) Failed() {
+	c.outcome.failed = true
+}
+
+// Execute runs another interaction as if it were attached to the same
+// location or object, used by interactions that wrap other interactions
+func (c *InteractionContext) Execute(interaction Interaction, args string) error {
+	return c.game.runInteraction(c.actorCtx, &interactionCommand{
+		parse:       c.command.parse,
+		interaction: interaction,
+		helpGroup:   c.command.helpGroup,
+		did:         c.command.did,
+	}, args, c.outcome)
+}
+
+// interactionContinuation is sent back to the game actor by RunAsync
+type interactionContinuation struct {
+	command *interactionCommand
+	then    func(ctx *InteractionContext) error
+}
+
+// RunAsync runs expensive work, like deriving cipher keys, off of the game
+// actor. The function work returns is then run back on the game actor, where
+// it is safe to touch the game and the player tree again.
+func (c *InteractionContext) RunAsync(work func() func(ctx *InteractionContext) error) {
+	c.outcome.pending = true
+	self := c.actorCtx.Self()
+	command := c.command
+	go func() {
+		then := work()
+		actor.EmptyRootContext.Send(self, &interactionContinuation{
+			command: command,
+			then:    then,
+		})
+	}()
+}
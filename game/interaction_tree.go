@@ -47,6 +47,14 @@ func (l *InteractionTree) InteractionsList() ([]Interaction, error) {
 	return l.interactionsListFromTree(l)
 }
 
+func (l *InteractionTree) RemoveInteraction(command string) error {
+	return l.removeInteractionFromTree(l, command)
+}
+
+func (l *InteractionTree) ReplaceInteraction(command string, i Interaction) error {
+	return l.replaceInteractionInTree(l, command, i)
+}
+
 func (l *InteractionTree) updatePath(path []string, val interface{}) error {
 	newTree, err := l.network.UpdateChainTree(l.tree, strings.Join(append([]string{"jasons-game"}, path...), "/"), val)
 	if err != nil {
@@ -56,6 +64,20 @@ func (l *InteractionTree) updatePath(path []string, val interface{}) error {
 	return nil
 }
 
+func (l *InteractionTree) updatePaths(updates []pathUpdate) error {
+	txs, err := setDataTransactions(updates)
+	if err != nil {
+		return err
+	}
+
+	newTree, err := l.network.PlayTransactions(l.tree, txs)
+	if err != nil {
+		return err
+	}
+	l.tree = newTree
+	return nil
+}
+
 func (l *InteractionTree) getPath(path []string) (interface{}, error) {
 	ctx := context.TODO()
 	resp, _, err := l.tree.ChainTree.Dag.Resolve(ctx, append([]string{"tree", "data", "jasons-game"}, path...))
@@ -3,43 +3,61 @@ package game
 import (
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gogo/protobuf/proto"
 	ptypes "github.com/gogo/protobuf/types"
 	cbor "github.com/ipfs/go-ipld-cbor"
 	"github.com/pkg/errors"
+	"github.com/quorumcontrol/chaintree/chaintree"
 	"github.com/quorumcontrol/chaintree/safewrap"
 	"github.com/quorumcontrol/chaintree/typecaster"
 	"github.com/quorumcontrol/jasons-game/network"
+	"github.com/quorumcontrol/messages/build/go/transactions"
 	"github.com/quorumcontrol/tupelo-go-sdk/consensus"
 	"golang.org/x/crypto/nacl/secretbox"
 	"golang.org/x/crypto/scrypt"
 )
 
 func init() {
-	cbor.RegisterCborType(RespondInteraction{})
-	typecaster.AddType(RespondInteraction{})
-	cbor.RegisterCborType(ChangeLocationInteraction{})
-	typecaster.AddType(ChangeLocationInteraction{})
-	cbor.RegisterCborType(ChangeNamedLocationInteraction{})
-	typecaster.AddType(ChangeNamedLocationInteraction{})
-	cbor.RegisterCborType(CreateObjectInteraction{})
-	typecaster.AddType(CreateObjectInteraction{})
-	cbor.RegisterCborType(PickUpObjectInteraction{})
-	typecaster.AddType(PickUpObjectInteraction{})
-	cbor.RegisterCborType(DropObjectInteraction{})
-	typecaster.AddType(DropObjectInteraction{})
-	cbor.RegisterCborType(GetTreeValueInteraction{})
-	typecaster.AddType(GetTreeValueInteraction{})
-	cbor.RegisterCborType(SetTreeValueInteraction{})
-	typecaster.AddType(SetTreeValueInteraction{})
-	cbor.RegisterCborType(CipherInteraction{})
-	typecaster.AddType(CipherInteraction{})
-	cbor.RegisterCborType(ChainedInteraction{})
-	typecaster.AddType(ChainedInteraction{})
+	cbor.RegisterCborType(InteractionCondition{})
+	typecaster.AddType(InteractionCondition{})
+	cbor.RegisterCborType(InteractionUsage{})
+	typecaster.AddType(InteractionUsage{})
+	cbor.RegisterCborType(DialogueNode{})
+	typecaster.AddType(DialogueNode{})
+	cbor.RegisterCborType(DialogueChoice{})
+	typecaster.AddType(DialogueChoice{})
+	cbor.RegisterCborType(CipherLimit{})
+	typecaster.AddType(CipherLimit{})
+	cbor.RegisterCborType(CipherProgress{})
+	typecaster.AddType(CipherProgress{})
+
+	RegisterInteraction(&RespondInteraction{}, executeRespondInteraction)
+	RegisterInteraction(&ChangeLocationInteraction{}, executeChangeLocationInteraction)
+	RegisterInteraction(&ChangeNamedLocationInteraction{}, executeChangeNamedLocationInteraction)
+	RegisterInteraction(&CreateObjectInteraction{}, executeCreateObjectInteraction)
+	RegisterInteraction(&PickUpObjectInteraction{}, executePickUpObjectInteraction, OutsidePlayerInventory())
+	RegisterInteraction(&DropObjectInteraction{}, executeDropObjectInteraction, OnlyInPlayerInventory())
+	RegisterInteraction(&GetTreeValueInteraction{}, executeGetTreeValueInteraction)
+	RegisterInteraction(&SetTreeValueInteraction{}, executeSetTreeValueInteraction)
+	RegisterInteraction(&CipherInteraction{}, executeCipherInteraction)
+	RegisterInteraction(&MultiPartCipherInteraction{}, executeMultiPartCipherInteraction)
+	RegisterInteraction(&ChainedInteraction{}, executeChainedInteraction)
+	RegisterInteraction(&ConditionalInteraction{}, executeConditionalInteraction)
+	RegisterInteraction(&RandomInteraction{}, executeRandomInteraction)
+	RegisterInteraction(&LimitedInteraction{}, executeLimitedInteraction)
+	RegisterInteraction(&DialogueInteraction{}, executeDialogueInteraction)
+	RegisterInteraction(&StartQuestInteraction{}, executeStartQuestInteraction)
+	registerInteractionExecutor(&BuildPortalInteraction{}, executeBuildPortalInteraction)
+	registerInteractionExecutor(&DeletePortalInteraction{}, executeDeletePortalInteraction)
+	registerInteractionExecutor(&LookAroundInteraction{}, executeLookAroundInteraction)
 }
 
 type Interaction interface {
@@ -56,7 +74,13 @@ var _ Interaction = (*DropObjectInteraction)(nil)
 var _ Interaction = (*GetTreeValueInteraction)(nil)
 var _ Interaction = (*SetTreeValueInteraction)(nil)
 var _ Interaction = (*CipherInteraction)(nil)
+var _ Interaction = (*MultiPartCipherInteraction)(nil)
 var _ Interaction = (*ChainedInteraction)(nil)
+var _ Interaction = (*ConditionalInteraction)(nil)
+var _ Interaction = (*RandomInteraction)(nil)
+var _ Interaction = (*LimitedInteraction)(nil)
+var _ Interaction = (*DialogueInteraction)(nil)
+var _ Interaction = (*StartQuestInteraction)(nil)
 
 type ListInteractionsRequest struct{}
 
@@ -79,12 +103,50 @@ type AddInteractionResponse struct {
 	Error error
 }
 
+type RemoveInteractionRequest struct {
+	Command string
+}
+
+type RemoveInteractionResponse struct {
+	Error error
+}
+
+type ReplaceInteractionRequest struct {
+	Command     string
+	Interaction Interaction
+}
+
+type ReplaceInteractionResponse struct {
+	Error error
+}
+
 type withInteractions struct {
 }
 
 type updatableTree interface {
 	getPath([]string) (interface{}, error)
 	updatePath([]string, interface{}) error
+	// updatePaths makes all the updates in one block
+	updatePaths([]pathUpdate) error
+}
+
+// pathUpdate sets val at path, clearing it when val is nil
+type pathUpdate struct {
+	path []string
+	val  interface{}
+}
+
+// setDataTransactions are the transactions to make updates under jasons-game
+func setDataTransactions(updates []pathUpdate) ([]*transactions.Transaction, error) {
+	txs := make([]*transactions.Transaction, len(updates))
+	for i, update := range updates {
+		transaction, err := chaintree.NewSetDataTransaction(strings.Join(append([]string{"jasons-game"}, update.path...), "/"), update.val)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating set data transaction")
+		}
+		txs[i] = transaction
+	}
+	return txs, nil
 }
 
 type BuildPortalInteraction struct {
@@ -174,6 +236,54 @@ func (w *withInteractions) addInteractionToTree(tree updatableTree, i Interactio
 	return tree.updatePath([]string{"interactions", i.GetCommand()}, toStore)
 }
 
+func (w *withInteractions) removeInteractionFromTree(tree updatableTree, command string) error {
+	resp, err := w.getInteractionFromTree(tree, command)
+	if err != nil {
+		return err
+	}
+	if resp == nil {
+		return fmt.Errorf("interaction %v does not exist", command)
+	}
+
+	return tree.updatePath([]string{"interactions", command}, nil)
+}
+
+// replaceInteractionInTree swaps the interaction stored as command for i,
+// which may have a different command. Renames happen in one block, so the
+// interaction can't end up under both commands or neither.
+func (w *withInteractions) replaceInteractionInTree(tree updatableTree, command string, i Interaction) error {
+	resp, err := w.getInteractionFromTree(tree, command)
+	if err != nil {
+		return err
+	}
+	if resp == nil {
+		return fmt.Errorf("interaction %v does not exist", command)
+	}
+
+	if i.GetCommand() != command {
+		resp, err = w.getInteractionFromTree(tree, i.GetCommand())
+		if err != nil {
+			return err
+		}
+		if resp != nil {
+			return fmt.Errorf("interaction %v already exists", i.GetCommand())
+		}
+	}
+
+	toStore, err := interactionToCborNode(i)
+	if err != nil {
+		return errors.Wrap(err, "error turning interaction into cbor")
+	}
+
+	if i.GetCommand() == command {
+		return tree.updatePath([]string{"interactions", command}, toStore)
+	}
+	return tree.updatePaths([]pathUpdate{
+		{path: []string{"interactions", i.GetCommand()}, val: toStore},
+		{path: []string{"interactions", command}, val: nil},
+	})
+}
+
 func (w *withInteractions) getInteractionFromTree(tree updatableTree, command string) (Interaction, error) {
 	val, err := tree.getPath([]string{"interactions", command})
 	if err != nil || val == nil {
@@ -188,15 +298,17 @@ func (w *withInteractions) interactionsListFromTree(tree updatableTree) ([]Inter
 		return nil, err
 	}
 
-	interactions := make([]Interaction, len(val.(map[string]interface{})))
-	i := 0
+	interactions := make([]Interaction, 0, len(val.(map[string]interface{})))
 	for cmd := range val.(map[string]interface{}) {
 		interaction, err := w.getInteractionFromTree(tree, cmd)
 		if err != nil {
 			return nil, err
 		}
-		interactions[i] = interaction
-		i++
+		// removed interactions are left as nil
+		if interaction == nil {
+			continue
+		}
+		interactions = append(interactions, interaction)
 	}
 	return interactions, nil
 }
@@ -357,3 +469,217 @@ func (i *CipherInteraction) Unseal(secret string) (Interaction, bool, error) {
 	}
 	return interaction, unsealSuccess, nil
 }
+
+func NewConditionalInteraction(command string, condition *InteractionCondition, successInteraction Interaction, failureInteraction Interaction) (*ConditionalInteraction, error) {
+	if condition == nil {
+		return nil, fmt.Errorf("condition is required")
+	}
+
+	successInteractionNode, err := interactionToCborNode(successInteraction)
+	if err != nil {
+		return nil, errors.Wrap(err, "successInteraction could not be encoded")
+	}
+
+	var failureInteractionBytes []byte
+	if failureInteraction != nil {
+		failureInteractionNode, err := interactionToCborNode(failureInteraction)
+		if err != nil {
+			return nil, errors.Wrap(err, "failureInteraction could not be encoded")
+		}
+		failureInteractionBytes = failureInteractionNode.RawData()
+	}
+
+	return &ConditionalInteraction{
+		Command:                 command,
+		Condition:               condition,
+		SuccessInteractionBytes: successInteractionNode.RawData(),
+		FailureInteractionBytes: failureInteractionBytes,
+	}, nil
+}
+
+func (i *ConditionalInteraction) SuccessInteraction() (Interaction, error) {
+	interaction, err := interactionFromCborBytes(i.SuccessInteractionBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding success interaction")
+	}
+	return interaction, nil
+}
+
+// FailureInteraction returns nil if the interaction was created without one
+func (i *ConditionalInteraction) FailureInteraction() (Interaction, error) {
+	if len(i.FailureInteractionBytes) == 0 {
+		return nil, nil
+	}
+	interaction, err := interactionFromCborBytes(i.FailureInteractionBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding failure interaction")
+	}
+	return interaction, nil
+}
+
+type WeightedInteraction struct {
+	Weight      uint32
+	Interaction Interaction
+}
+
+type RandomRoll struct {
+	Seed        []byte
+	Roll        uint64
+	Total       uint64
+	Index       int
+	Interaction Interaction
+}
+
+func NewRandomInteraction(command string, outcomes ...*WeightedInteraction) (*RandomInteraction, error) {
+	interactionBytes := make([][]byte, len(outcomes))
+	weights := make([]uint32, len(outcomes))
+
+	var total uint64
+	for i, outcome := range outcomes {
+		interactionNode, err := interactionToCborNode(outcome.Interaction)
+		if err != nil {
+			return nil, errors.Wrap(err, "interaction could not be encoded")
+		}
+		interactionBytes[i] = interactionNode.RawData()
+		weights[i] = outcome.Weight
+		total += uint64(outcome.Weight)
+	}
+
+	if total == 0 {
+		return nil, fmt.Errorf("at least one outcome must have a weight")
+	}
+
+	return &RandomInteraction{
+		Command:           command,
+		InteractionsBytes: interactionBytes,
+		Weights:           weights,
+	}, nil
+}
+
+// RandomSeed derives the seed for a RandomInteraction from the player, the tip
+// of the location they are in, the command and how many times the player has
+// rolled it before, so any roll can be re-derived and audited from those values
+func RandomSeed(playerDid string, locationTip string, command string, use uint64) []byte {
+	seed := sha256.Sum256([]byte(strings.Join([]string{playerDid, locationTip, command, strconv.FormatUint(use, 10)}, "/")))
+	return seed[:]
+}
+
+// Roll deterministically picks one of the interactions by weight using seed
+func (i *RandomInteraction) Roll(seed []byte) (*RandomRoll, error) {
+	if len(i.Weights) != len(i.InteractionsBytes) {
+		return nil, fmt.Errorf("random interaction has %d weights for %d interactions", len(i.Weights), len(i.InteractionsBytes))
+	}
+	if len(seed) < 8 {
+		return nil, fmt.Errorf("seed must be at least 8 bytes")
+	}
+
+	var total uint64
+	for _, weight := range i.Weights {
+		total += uint64(weight)
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("random interaction has no weighted outcomes")
+	}
+
+	roll := binary.BigEndian.Uint64(seed[:8]) % total
+
+	var cumulative uint64
+	for idx, weight := range i.Weights {
+		cumulative += uint64(weight)
+		if roll >= cumulative {
+			continue
+		}
+
+		interaction, err := interactionFromCborBytes(i.InteractionsBytes[idx])
+		if err != nil {
+			return nil, errors.Wrap(err, "error decoding interaction")
+		}
+
+		return &RandomRoll{
+			Seed:        seed,
+			Roll:        roll,
+			Total:       total,
+			Index:       idx,
+			Interaction: interaction,
+		}, nil
+	}
+
+	// unreachable since roll < total
+	return nil, fmt.Errorf("no outcome for roll %d", roll)
+}
+
+// InteractionLimitError is returned when a player has used up a LimitedInteraction
+type InteractionLimitError struct {
+	Message string
+}
+
+func (e *InteractionLimitError) Error() string {
+	return e.Message
+}
+
+func NewLimitedInteraction(command string, interaction Interaction, maxUses uint32, window time.Duration) (*LimitedInteraction, error) {
+	interactionNode, err := interactionToCborNode(interaction)
+	if err != nil {
+		return nil, errors.Wrap(err, "interaction could not be encoded")
+	}
+
+	return &LimitedInteraction{
+		Command:          command,
+		InteractionBytes: interactionNode.RawData(),
+		MaxUses:          maxUses,
+		WindowSeconds:    int64(window / time.Second),
+	}, nil
+}
+
+func (i *LimitedInteraction) Interaction() (Interaction, error) {
+	interaction, err := interactionFromCborBytes(i.InteractionBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding interaction")
+	}
+	return interaction, nil
+}
+
+// maxUses is how many times a player can use the interaction, 0 for
+// unlimited. A window without a max is once per window, since a window
+// limits nothing on its own.
+func (i *LimitedInteraction) maxUses() uint32 {
+	if i.MaxUses == 0 && i.WindowSeconds > 0 {
+		return 1
+	}
+	return i.MaxUses
+}
+
+// Check returns the usage to record if the interaction can be used at now,
+// or an InteractionLimitError if the limit has been reached
+func (i *LimitedInteraction) Check(usage *InteractionUsage, now time.Time) (*InteractionUsage, error) {
+	current := &InteractionUsage{}
+	if usage != nil {
+		*current = *usage
+	}
+
+	window := time.Duration(i.WindowSeconds) * time.Second
+	windowStart := time.Unix(current.WindowStart, 0)
+
+	if window > 0 && current.WindowStart > 0 && !now.Before(windowStart.Add(window)) {
+		current = &InteractionUsage{}
+	}
+
+	if maxUses := i.maxUses(); maxUses > 0 && current.Count >= maxUses {
+		msg := i.LimitMessage
+		if msg == "" {
+			if window > 0 {
+				msg = fmt.Sprintf("you can't do that again for another %v", windowStart.Add(window).Sub(now).Round(time.Second))
+			} else {
+				msg = "you can't do that again"
+			}
+		}
+		return nil, &InteractionLimitError{Message: msg}
+	}
+
+	if current.WindowStart == 0 {
+		current.WindowStart = now.Unix()
+	}
+	current.Count++
+
+	return current, nil
+}
@@ -1,9 +1,12 @@
 package game
 
 import (
+	"context"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/quorumcontrol/jasons-game/game/trees"
 	"github.com/quorumcontrol/jasons-game/network"
 	"github.com/stretchr/testify/require"
 )
@@ -78,3 +81,333 @@ func TestChainedInteraction(t *testing.T) {
 		require.Equal(t, interactions[i].(*RespondInteraction).Response, interaction.(*RespondInteraction).Response)
 	}
 }
+
+type testRegisteredInteraction struct {
+	Interaction
+}
+
+func TestRegisterInteraction(t *testing.T) {
+	_, ok := registeredInteractionFor(&RespondInteraction{})
+	require.True(t, ok)
+
+	_, ok = registeredInteractionFor(&testRegisteredInteraction{})
+	require.False(t, ok)
+
+	var executedArgs string
+	registerInteractionExecutor(&testRegisteredInteraction{}, func(ctx *InteractionContext, interaction Interaction, args string) error {
+		executedArgs = args
+		return nil
+	}, OnlyInPlayerInventory())
+
+	registered, ok := registeredInteractionFor(&testRegisteredInteraction{})
+	require.True(t, ok)
+	require.True(t, registered.inPlayerInventory)
+	require.False(t, registered.outsidePlayerInventory)
+
+	err := registered.executor(nil, &testRegisteredInteraction{}, "some args")
+	require.Nil(t, err)
+	require.Equal(t, "some args", executedArgs)
+}
+
+func TestConditionalInteractionEncoding(t *testing.T) {
+	condition := &InteractionCondition{OwnsDid: "did:tupelo:test"}
+	si := &RespondInteraction{Response: "success response"}
+
+	ci, err := NewConditionalInteraction("open door", condition, si, nil)
+	require.Nil(t, err)
+
+	interaction, err := ci.SuccessInteraction()
+	require.Nil(t, err)
+	require.Equal(t, si.Response, interaction.(*RespondInteraction).Response)
+
+	interaction, err = ci.FailureInteraction()
+	require.Nil(t, err)
+	require.Nil(t, interaction)
+
+	net := network.NewLocalNetwork()
+	signedTree, err := net.CreateChainTree()
+	require.Nil(t, err)
+	tree := NewLocationTree(net, signedTree)
+
+	err = tree.AddInteraction(ci)
+	require.Nil(t, err)
+
+	list, err := tree.InteractionsList()
+	require.Nil(t, err)
+	require.Len(t, list, 1)
+	require.Equal(t, condition.OwnsDid, list[0].(*ConditionalInteraction).Condition.OwnsDid)
+}
+
+func TestRandomInteraction(t *testing.T) {
+	ri, err := NewRandomInteraction("ask the fortune teller",
+		&WeightedInteraction{Weight: 0, Interaction: &RespondInteraction{Response: "never"}},
+		&WeightedInteraction{Weight: 2, Interaction: &RespondInteraction{Response: "sometimes"}},
+		&WeightedInteraction{Weight: 2, Interaction: &RespondInteraction{Response: "other times"}},
+	)
+	require.Nil(t, err)
+
+	seen := make(map[string]bool)
+	for i := 0; i < 50; i++ {
+		seed := RandomSeed("did:tupelo:player", "tip", ri.Command, uint64(i))
+		roll, err := ri.Roll(seed)
+		require.Nil(t, err)
+		require.NotEqual(t, 0, roll.Index)
+		require.Equal(t, uint64(4), roll.Total)
+
+		again, err := ri.Roll(seed)
+		require.Nil(t, err)
+		require.Equal(t, roll.Index, again.Index)
+
+		seen[roll.Interaction.(*RespondInteraction).Response] = true
+	}
+	require.Len(t, seen, 2)
+	require.NotEqual(t, RandomSeed("did:tupelo:player", "tip", ri.Command, 0), RandomSeed("did:tupelo:player", "tip", ri.Command, 1))
+
+	_, err = NewRandomInteraction("nothing", &WeightedInteraction{Weight: 0, Interaction: &RespondInteraction{}})
+	require.NotNil(t, err)
+}
+
+func TestLimitedInteractionCheck(t *testing.T) {
+	now := time.Now()
+
+	t.Run("n times per player", func(t *testing.T) {
+		li, err := NewLimitedInteraction("ring bell", &RespondInteraction{Response: "dong"}, 2, 0)
+		require.Nil(t, err)
+
+		usage, err := li.Check(nil, now)
+		require.Nil(t, err)
+		require.Equal(t, uint32(1), usage.Count)
+
+		usage, err = li.Check(usage, now)
+		require.Nil(t, err)
+		require.Equal(t, uint32(2), usage.Count)
+
+		_, err = li.Check(usage, now.Add(24*time.Hour))
+		require.IsType(t, &InteractionLimitError{}, err)
+	})
+
+	t.Run("once per window", func(t *testing.T) {
+		li, err := NewLimitedInteraction("pray", &RespondInteraction{Response: "amen"}, 1, time.Hour)
+		require.Nil(t, err)
+		li.LimitMessage = "the gods are not listening"
+
+		usage, err := li.Check(nil, now)
+		require.Nil(t, err)
+
+		_, err = li.Check(usage, now.Add(30*time.Minute))
+		require.NotNil(t, err)
+		require.Equal(t, li.LimitMessage, err.Error())
+
+		usage, err = li.Check(usage, now.Add(time.Hour))
+		require.Nil(t, err)
+		require.Equal(t, uint32(1), usage.Count)
+		require.Equal(t, now.Add(time.Hour).Unix(), usage.WindowStart)
+	})
+
+	t.Run("a window without max uses is once per window", func(t *testing.T) {
+		li, err := NewLimitedInteraction("pray", &RespondInteraction{Response: "amen"}, 0, time.Hour)
+		require.Nil(t, err)
+
+		usage, err := li.Check(nil, now)
+		require.Nil(t, err)
+
+		_, err = li.Check(usage, now.Add(30*time.Minute))
+		require.IsType(t, &InteractionLimitError{}, err)
+
+		_, err = li.Check(usage, now.Add(time.Hour))
+		require.Nil(t, err)
+	})
+
+	t.Run("unlimited without a window", func(t *testing.T) {
+		li, err := NewLimitedInteraction("wave", &RespondInteraction{Response: "hi"}, 0, 0)
+		require.Nil(t, err)
+
+		usage, err := li.Check(&InteractionUsage{Count: 1000, WindowStart: now.Unix()}, now)
+		require.Nil(t, err)
+		require.Equal(t, uint32(1001), usage.Count)
+	})
+}
+
+func TestRemoveAndReplaceInteraction(t *testing.T) {
+	net := network.NewLocalNetwork()
+	signedTree, err := net.CreateChainTree()
+	require.Nil(t, err)
+	tree := NewObjectTree(net, signedTree)
+
+	err = tree.AddInteraction(&RespondInteraction{Command: "marco", Response: "plo"})
+	require.Nil(t, err)
+
+	err = tree.ReplaceInteraction("marco", &RespondInteraction{Command: "marco", Response: "polo"})
+	require.Nil(t, err)
+
+	list, err := tree.InteractionsList()
+	require.Nil(t, err)
+	require.Len(t, list, 1)
+	require.Equal(t, "polo", list[0].(*RespondInteraction).Response)
+
+	// a rename is one block
+	before := trees.MustHeight(context.Background(), tree.ChainTree().ChainTree)
+	err = tree.ReplaceInteraction("marco", &RespondInteraction{Command: "marco!", Response: "polo"})
+	require.Nil(t, err)
+	require.Equal(t, before+1, trees.MustHeight(context.Background(), tree.ChainTree().ChainTree))
+
+	list, err = tree.InteractionsList()
+	require.Nil(t, err)
+	require.Len(t, list, 1)
+	require.Equal(t, "marco!", list[0].GetCommand())
+
+	err = tree.ReplaceInteraction("marco", &RespondInteraction{Command: "marco", Response: "polo"})
+	require.NotNil(t, err)
+
+	err = tree.RemoveInteraction("marco!")
+	require.Nil(t, err)
+
+	list, err = tree.InteractionsList()
+	require.Nil(t, err)
+	require.Len(t, list, 0)
+
+	err = tree.RemoveInteraction("marco!")
+	require.NotNil(t, err)
+
+	err = tree.AddInteraction(&RespondInteraction{Command: "marco!", Response: "polo"})
+	require.Nil(t, err)
+}
+
+func TestDialogueInteractionValidate(t *testing.T) {
+	end, err := NewDialogueNode("end", "farewell", &RespondInteraction{Response: "the hermit waves"})
+	require.Nil(t, err)
+	start, err := NewDialogueNode("start", "hello", nil,
+		&DialogueChoice{Text: "bye", Next: "end"},
+		&DialogueChoice{Text: "leave"},
+	)
+	require.Nil(t, err)
+
+	dialogue, err := NewDialogueInteraction("talk", "start", start, end)
+	require.Nil(t, err)
+	require.Equal(t, end, dialogue.Node("end"))
+	require.Nil(t, dialogue.Node("nowhere"))
+
+	startInteraction, err := start.Interaction()
+	require.Nil(t, err)
+	require.Nil(t, startInteraction)
+
+	endInteraction, err := end.Interaction()
+	require.Nil(t, err)
+	require.Equal(t, "the hermit waves", endInteraction.(*RespondInteraction).Response)
+
+	_, err = NewDialogueInteraction("talk", "nowhere", start, end)
+	require.NotNil(t, err)
+
+	_, err = NewDialogueInteraction("talk", "start", start)
+	require.NotNil(t, err)
+
+	_, err = NewDialogueInteraction("talk", "start", start, end, end)
+	require.NotNil(t, err)
+}
+
+func TestCipherLimitCheck(t *testing.T) {
+	now := time.Now()
+
+	// a nil limit uses the defaults
+	var limit *CipherLimit
+	_, err := limit.Check(&InteractionUsage{Count: 100, WindowStart: now.Unix()}, now)
+	require.IsType(t, &InteractionLimitError{}, err)
+
+	// and so do unset fields
+	limit = &CipherLimit{}
+	attempts, err := limit.Check(nil, now)
+	require.Nil(t, err)
+	for i := 1; i < defaultCipherMaxAttempts; i++ {
+		attempts, err = limit.Check(attempts, now)
+		require.Nil(t, err)
+	}
+	require.Equal(t, uint32(defaultCipherMaxAttempts), attempts.Count)
+
+	_, err = limit.Check(attempts, now.Add(30*time.Second))
+	require.IsType(t, &InteractionLimitError{}, err)
+
+	attempts, err = limit.Check(attempts, now.Add(defaultCipherLockout))
+	require.Nil(t, err)
+	require.Equal(t, uint32(1), attempts.Count)
+
+	limit = &CipherLimit{MaxAttempts: 1, LockoutSeconds: 10, LockoutMessage: "the lock is jammed"}
+	attempts, err = limit.Check(nil, now)
+	require.Nil(t, err)
+	_, err = limit.Check(attempts, now.Add(5*time.Second))
+	require.Equal(t, "the lock is jammed", err.(*InteractionLimitError).Message)
+}
+
+func TestMultiPartCipherInteraction(t *testing.T) {
+	si := &RespondInteraction{Response: "the door swings open"}
+
+	ci, err := NewMultiPartCipherInteraction("speak", []string{"open", "sesame"}, si, nil, nil)
+	require.Nil(t, err)
+
+	idx, _, err := ci.MatchFragment("close")
+	require.Nil(t, err)
+	require.Equal(t, -1, idx)
+
+	shares := make([][]byte, 2)
+	idx, share, err := ci.MatchFragment("sesame")
+	require.Nil(t, err)
+	require.Equal(t, 1, idx)
+	shares[idx] = share
+
+	_, err = ci.Unseal(shares)
+	require.NotNil(t, err)
+
+	idx, share, err = ci.MatchFragment("open")
+	require.Nil(t, err)
+	require.Equal(t, 0, idx)
+	shares[idx] = share
+
+	interaction, err := ci.Unseal(shares)
+	require.Nil(t, err)
+	require.Equal(t, si.Response, interaction.(*RespondInteraction).Response)
+
+	_, err = NewMultiPartCipherInteraction("speak", []string{"open", "open"}, si, nil, nil)
+	require.NotNil(t, err)
+}
+
+func TestCipherProgressSealing(t *testing.T) {
+	var key [32]byte
+	copy(key[:], "a key that is thirty two bytes!!")
+
+	shares := [][]byte{nil, []byte("second share"), nil}
+	progress, err := sealCipherProgress(&key, shares)
+	require.Nil(t, err)
+	require.Empty(t, progress.Shares[0])
+	require.NotEqual(t, shares[1], progress.Shares[1])
+
+	opened, err := openCipherProgress(&key, progress, 3)
+	require.Nil(t, err)
+	require.Equal(t, shares[1], opened[1])
+	require.Equal(t, 1, countCipherShares(opened))
+
+	var otherKey [32]byte
+	_, err = openCipherProgress(&otherKey, progress, 3)
+	require.NotNil(t, err)
+}
+
+func TestQuestObjectiveMatches(t *testing.T) {
+	visit := &QuestObjective{VisitLocation: "did:tupelo:location"}
+	require.True(t, visit.matches(&questEvent{locationDid: "did:tupelo:location"}))
+	require.False(t, visit.matches(&questEvent{locationDid: "did:tupelo:elsewhere"}))
+	require.Equal(t, "visit did:tupelo:location", visit.Describe())
+
+	use := &QuestObjective{Description: "ring the bell", UseInteraction: "ring bell"}
+	require.True(t, use.matches(&questEvent{interaction: "Ring Bell"}))
+	require.False(t, use.matches(&questEvent{locationDid: "did:tupelo:location"}))
+	require.Equal(t, "ring the bell", use.Describe())
+
+	prize := &QuestObjective{ReceivePrize: "did:tupelo:handler"}
+	require.True(t, prize.matches(&questEvent{pickedUp: true, prizeFrom: "did:tupelo:handler"}))
+	require.False(t, prize.matches(&questEvent{pickedUp: true}))
+
+	progress := &QuestProgress{}
+	progress.CompleteObjective(1)
+	progress.CompleteObjective(1)
+	require.Len(t, progress.CompletedObjectives, 1)
+	require.True(t, progress.IsObjectiveComplete(1))
+	require.False(t, progress.IsObjectiveComplete(0))
+}
@@ -0,0 +1,206 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/AsynkronIT/protoactor-go/actor"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/pkg/errors"
+	"github.com/quorumcontrol/chaintree/typecaster"
+)
+
+func init() {
+	cbor.RegisterCborType(JournalEntry{})
+	typecaster.AddType(JournalEntry{})
+	cbor.RegisterCborType(Bookmark{})
+	typecaster.AddType(Bookmark{})
+}
+
+const journalTimeFormat = "2006-01-02 15:04"
+
+// recordVisit adds a visit to the location to the player's journal,
+// returning the tip of the location when they were last there, empty on
+// a first visit
+func (g *Game) recordVisit(location *LocationTree, now time.Time) (string, error) {
+	did := location.MustId()
+
+	entry, err := g.playerTree.JournalEntry(did)
+	if err != nil {
+		return "", errors.Wrap(err, "error fetching journal entry")
+	}
+	if entry == nil {
+		entry = &JournalEntry{FirstVisit: now.Unix()}
+	}
+
+	lastTip := entry.LastTip
+	entry.LastVisit = now.Unix()
+	entry.LastTip = location.Tip().String()
+	entry.Visits++
+
+	err = g.playerTree.SetJournalEntry(did, entry)
+	if err != nil {
+		return "", err
+	}
+	return lastTip, nil
+}
+
+// markLocationSeen updates the tip the player last saw the location at,
+// without counting it as a visit
+func (g *Game) markLocationSeen(location *LocationTree) error {
+	entry, err := g.playerTree.JournalEntry(location.MustId())
+	if err != nil {
+		return errors.Wrap(err, "error fetching journal entry")
+	}
+	if entry == nil {
+		return nil
+	}
+
+	entry.LastTip = location.Tip().String()
+	return g.playerTree.SetJournalEntry(location.MustId(), entry)
+}
+
+// locationDescription is empty if the location no longer exists
+func (g *Game) locationDescription(did string) (string, error) {
+	tree, err := g.network.GetTree(did)
+	if err != nil {
+		return "", errors.Wrap(err, fmt.Sprintf("error fetching location %s", did))
+	}
+	if tree == nil {
+		return "", nil
+	}
+	return NewLocationTree(g.network, tree).GetDescription()
+}
+
+func (g *Game) handleJournal(actorCtx actor.Context) error {
+	journal, err := g.playerTree.Journal()
+	if err != nil {
+		return errors.Wrap(err, "error fetching journal")
+	}
+
+	if len(journal) == 0 {
+		g.sendUserMessage(actorCtx, "your journal is empty")
+		return nil
+	}
+
+	dids := make([]string, 0, len(journal))
+	for did := range journal {
+		dids = append(dids, did)
+	}
+	// most recently visited first
+	sort.Slice(dids, func(i, j int) bool {
+		if journal[dids[i]].LastVisit != journal[dids[j]].LastVisit {
+			return journal[dids[i]].LastVisit > journal[dids[j]].LastVisit
+		}
+		return dids[i] < dids[j]
+	})
+
+	toSend := indentedList{"your journal:"}
+	for _, did := range dids {
+		entry := journal[did]
+
+		description, err := g.locationDescription(did)
+		if err != nil {
+			return err
+		}
+		if description == "" {
+			description = "somewhere that no longer exists"
+		}
+
+		toSend = append(toSend, fmt.Sprintf("%s - first visited %s, last visited %s",
+			description,
+			time.Unix(entry.FirstVisit, 0).Format(journalTimeFormat),
+			time.Unix(entry.LastVisit, 0).Format(journalTimeFormat),
+		))
+	}
+	g.sendUserMessage(actorCtx, toSend)
+	return nil
+}
+
+func bookmarkName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+func (g *Game) handleBookmark(actorCtx actor.Context, name string) error {
+	name = bookmarkName(name)
+	if name == "" {
+		g.sendUserMessage(actorCtx, "name your bookmark, e.g. `bookmark the forest`")
+		return nil
+	}
+
+	err := g.playerTree.SetBookmark(name, &Bookmark{Location: g.locationDid, CreatedAt: time.Now().Unix()})
+	if err != nil {
+		return errors.Wrap(err, "error saving bookmark")
+	}
+
+	g.sendUserMessage(actorCtx, fmt.Sprintf("bookmarked this spot as %s, type `recall %s` to come back", name, name))
+	return nil
+}
+
+func (g *Game) handleBookmarkList(actorCtx actor.Context) error {
+	bookmarks, err := g.playerTree.Bookmarks()
+	if err != nil {
+		return errors.Wrap(err, "error fetching bookmarks")
+	}
+
+	if len(bookmarks) == 0 {
+		g.sendUserMessage(actorCtx, "you don't have any bookmarks")
+		return nil
+	}
+
+	names := make([]string, 0, len(bookmarks))
+	for name := range bookmarks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	toSend := indentedList{"your bookmarks:"}
+	for _, name := range names {
+		description, err := g.locationDescription(bookmarks[name].Location)
+		if err != nil {
+			return err
+		}
+		if description == "" {
+			description = "somewhere that no longer exists"
+		}
+		toSend = append(toSend, fmt.Sprintf("%s - %s", name, description))
+	}
+	g.sendUserMessage(actorCtx, toSend)
+	return nil
+}
+
+func (g *Game) handleRecall(actorCtx actor.Context, name string) error {
+	name = bookmarkName(name)
+	if name == "" {
+		g.sendUserMessage(actorCtx, "recall which bookmark? type `bookmarks` to see them")
+		return nil
+	}
+
+	bookmark, err := g.playerTree.Bookmark(name)
+	if err != nil {
+		return errors.Wrap(err, "error fetching bookmark")
+	}
+	if bookmark == nil {
+		g.sendUserMessage(actorCtx, fmt.Sprintf("you don't have a bookmark named %s", name))
+		return nil
+	}
+
+	if bookmark.Location == g.locationDid {
+		g.sendUserMessage(actorCtx, "you are already there")
+		return nil
+	}
+
+	// setLocation can't recover from a location that won't load, so make
+	// sure it's still around before leaving this one
+	tree, err := g.network.GetTree(bookmark.Location)
+	if err != nil || tree == nil {
+		log.Warningf("error fetching bookmarked location %s: %v", bookmark.Location, err)
+		g.sendUserMessage(actorCtx, fmt.Sprintf("you can't find your way back to %s, it may no longer exist", name))
+		return nil
+	}
+
+	g.handleChangeLocation(actorCtx, bookmark.Location)
+	return nil
+}
@@ -47,6 +47,16 @@ type SetLocationDescriptionResponse struct {
 	Error error
 }
 
+// SetLocationPathRequest sets any value on the location, for building
+type SetLocationPathRequest struct {
+	Path  []string
+	Value interface{}
+}
+
+type SetLocationPathResponse struct {
+	Error error
+}
+
 type BuildPortalRequest struct {
 	To string
 }
@@ -103,6 +113,9 @@ func (l *LocationActor) Receive(actorCtx actor.Context) {
 	case *SetLocationDescriptionRequest:
 		err := l.location.SetDescription(msg.Description)
 		actorCtx.Respond(&SetLocationDescriptionResponse{Error: err})
+	case *SetLocationPathRequest:
+		err := l.location.updatePath(msg.Path, msg.Value)
+		actorCtx.Respond(&SetLocationPathResponse{Error: err})
 	case *InventoryListRequest:
 		actorCtx.Forward(l.inventoryActor)
 	case *TransferObjectRequest:
@@ -117,6 +130,14 @@ func (l *LocationActor) Receive(actorCtx actor.Context) {
 		actorCtx.Respond(&AddInteractionResponse{
 			Error: l.location.AddInteraction(msg.Interaction),
 		})
+	case *RemoveInteractionRequest:
+		actorCtx.Respond(&RemoveInteractionResponse{
+			Error: l.location.RemoveInteraction(msg.Command),
+		})
+	case *ReplaceInteractionRequest:
+		actorCtx.Respond(&ReplaceInteractionResponse{
+			Error: l.location.ReplaceInteraction(msg.Command, msg.Interaction),
+		})
 	case *ListInteractionsRequest:
 		l.handleListInteractionsRequest(actorCtx, msg)
 	case *GetInventoryDid:
@@ -350,7 +371,7 @@ func (l *LocationActor) handleListInteractionsRequest(actorCtx actor.Context, ms
 			AttachedTo:    "location",
 			AttachedToDid: l.did,
 			Interaction: &ChangeLocationInteraction{
-				Command: "go through portal",
+				Command: portalCommand,
 				Did:     portal.To,
 			},
 		})
@@ -0,0 +1,338 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/AsynkronIT/protoactor-go/actor"
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/quorumcontrol/jasons-game/game/trees"
+)
+
+// locationSnapshot is what a player notices about a location
+type locationSnapshot struct {
+	description  string
+	objects      map[string]string
+	exits        map[string]bool
+	interactions map[string]bool
+}
+
+func (g *Game) snapshotLocation(location *LocationTree) (*locationSnapshot, error) {
+	description, err := location.GetDescription()
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching description")
+	}
+
+	objects, err := trees.NewInventoryTree(g.network, location.Tree()).All()
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching inventory")
+	}
+
+	exits, err := locationExits(g.network, g.playerTree.HomeLocation.MustId(), location)
+	if err != nil {
+		return nil, err
+	}
+
+	interactions, err := location.InteractionsList()
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching interactions")
+	}
+
+	snapshot := &locationSnapshot{
+		description:  description,
+		objects:      objects,
+		exits:        make(map[string]bool, len(exits)),
+		interactions: make(map[string]bool, len(interactions)),
+	}
+	for _, exit := range exits {
+		snapshot.exits[exit.command] = true
+	}
+	for _, interaction := range interactions {
+		if !interaction.GetHidden() && !snapshot.exits[interaction.GetCommand()] {
+			snapshot.interactions[interaction.GetCommand()] = true
+		}
+	}
+	return snapshot, nil
+}
+
+// withOwnObjectMoves is the snapshot as if the objects the player dropped
+// (true) or picked up (false) had already been moved, so they aren't told
+// about their own changes. Moves that after shows have happened are removed
+// from moves.
+func (s *locationSnapshot) withOwnObjectMoves(moves map[string]bool, after *locationSnapshot) *locationSnapshot {
+	objects := make(map[string]string, len(s.objects))
+	for did, name := range s.objects {
+		objects[did] = name
+	}
+
+	for did, dropped := range moves {
+		name, here := after.objects[did]
+		if dropped && here {
+			objects[did] = name
+			delete(moves, did)
+		}
+		if !dropped && !here {
+			delete(objects, did)
+			delete(moves, did)
+		}
+	}
+
+	moved := *s
+	moved.objects = objects
+	return &moved
+}
+
+// locationChanges are the differences between two snapshots of a location
+type locationChanges struct {
+	descriptionChanged bool
+	newObjects         []string
+	removedObjects     []string
+	newExits           []string
+	removedExits       []string
+	newInteractions    []string
+}
+
+func diffLocationSnapshots(before *locationSnapshot, after *locationSnapshot) *locationChanges {
+	return &locationChanges{
+		descriptionChanged: before.description != after.description,
+		newObjects:         addedNames(before.objects, after.objects),
+		removedObjects:     addedNames(after.objects, before.objects),
+		newExits:           addedKeys(before.exits, after.exits),
+		removedExits:       addedKeys(after.exits, before.exits),
+		newInteractions:    addedKeys(before.interactions, after.interactions),
+	}
+}
+
+// describeLocationChanges is empty when nothing a player would notice changed
+func describeLocationChanges(before *locationSnapshot, after *locationSnapshot) indentedList {
+	changes := diffLocationSnapshots(before, after)
+	var toSend indentedList
+
+	if changes.descriptionChanged {
+		toSend = append(toSend, "the description has changed")
+	}
+	if len(changes.newObjects) > 0 {
+		toSend = append(toSend, "new here: "+strings.Join(changes.newObjects, ", "))
+	}
+	if len(changes.removedObjects) > 0 {
+		toSend = append(toSend, "gone: "+strings.Join(changes.removedObjects, ", "))
+	}
+	if len(changes.newExits) > 0 {
+		toSend = append(toSend, "new ways out: "+strings.Join(changes.newExits, ", "))
+	}
+	if len(changes.removedExits) > 0 {
+		toSend = append(toSend, "ways out that are gone: "+strings.Join(changes.removedExits, ", "))
+	}
+	if len(changes.newInteractions) > 0 {
+		toSend = append(toSend, "new things to do: "+strings.Join(changes.newInteractions, ", "))
+	}
+
+	if len(toSend) == 0 {
+		return nil
+	}
+	return append(indentedList{"since you were last here:"}, toSend...)
+}
+
+// describeLiveLocationChanges are the events shown to players in a location
+// as it changes around them
+func describeLiveLocationChanges(changes *locationChanges) []string {
+	var events []string
+
+	if changes.descriptionChanged {
+		events = append(events, "The room changes around you.")
+	}
+	for _, name := range changes.newObjects {
+		events = append(events, fmt.Sprintf("%s appears on the ground.", withIndefiniteArticle(name)))
+	}
+	for _, name := range changes.removedObjects {
+		events = append(events, fmt.Sprintf("The %s is no longer here.", name))
+	}
+	for _, command := range changes.newExits {
+		if command == portalCommand {
+			events = append(events, "A mysterious portal opens.")
+			continue
+		}
+		events = append(events, fmt.Sprintf("There is a new way out: %s.", command))
+	}
+	for _, command := range changes.removedExits {
+		if command == portalCommand {
+			events = append(events, "The portal closes.")
+			continue
+		}
+		events = append(events, fmt.Sprintf("The way out through %s is gone.", command))
+	}
+	for _, command := range changes.newInteractions {
+		events = append(events, fmt.Sprintf("Something new can be done here: %s.", command))
+	}
+	return events
+}
+
+func withIndefiniteArticle(name string) string {
+	if name != "" && strings.ContainsRune("aeiouAEIOU", rune(name[0])) {
+		return "An " + name
+	}
+	return "A " + name
+}
+
+// addedNames are the names of objects in after but not before, keyed by did
+func addedNames(before map[string]string, after map[string]string) []string {
+	var added []string
+	for did, name := range after {
+		if _, ok := before[did]; !ok {
+			added = append(added, name)
+		}
+	}
+	sort.Strings(added)
+	return added
+}
+
+func addedKeys(before map[string]bool, after map[string]bool) []string {
+	var added []string
+	for key := range after {
+		if !before[key] {
+			added = append(added, key)
+		}
+	}
+	sort.Strings(added)
+	return added
+}
+
+func (g *Game) locationChangesSince(location *LocationTree, lastTip string) (indentedList, error) {
+	tip, err := cid.Parse(lastTip)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing last tip")
+	}
+
+	then, err := location.AtTip(tip)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := g.snapshotLocation(then)
+	if err != nil {
+		return nil, err
+	}
+	return describeLocationChanges(before, g.locationSnapshot), nil
+}
+
+// visitLocation records the visit in the journal and works out what changed
+// since the last one, which is sent along with the location. Neither should
+// keep the player from getting there, so failures are only logged.
+func (g *Game) visitLocation() {
+	g.locationChanges = nil
+	g.locationSnapshot = nil
+	g.seenLocation = nil
+	g.ownObjectMoves = make(map[string]bool)
+
+	location, err := g.currentLocationTree()
+	if err != nil {
+		log.Warningf("error fetching location to record visit: %v", err)
+		return
+	}
+
+	g.locationSnapshot, err = g.snapshotLocation(location)
+	if err != nil {
+		log.Warningf("error snapshotting location: %v", err)
+	}
+
+	lastTip, err := g.recordVisit(location, time.Now())
+	if err != nil {
+		log.Warningf("error recording visit: %v", err)
+		return
+	}
+
+	if g.locationSnapshot == nil || lastTip == "" || lastTip == location.Tip().String() {
+		return
+	}
+
+	g.locationChanges, err = g.locationChangesSince(location, lastTip)
+	if err != nil {
+		log.Warningf("error comparing %s to last visit: %v", g.locationDid, err)
+	}
+}
+
+// pushLocationChanges tells the player about changes to the location they
+// are in as they happen
+func (g *Game) pushLocationChanges(actorCtx actor.Context) {
+	if g.locationSnapshot == nil {
+		return
+	}
+
+	location, err := g.currentLocationTree()
+	if err != nil {
+		log.Warningf("error fetching changed location: %v", err)
+		return
+	}
+	g.syncChatLogged(actorCtx, location)
+
+	after, err := g.snapshotLocation(location)
+	if err != nil {
+		log.Warningf("error snapshotting changed location: %v", err)
+		return
+	}
+
+	changes := diffLocationSnapshots(g.locationSnapshot.withOwnObjectMoves(g.ownObjectMoves, after), after)
+	g.locationSnapshot = after
+
+	events := describeLiveLocationChanges(changes)
+	if len(events) == 0 {
+		return
+	}
+
+	for _, event := range events {
+		g.sendUserMessage(actorCtx, event)
+	}
+	if changes.descriptionChanged && g.viewing == nil {
+		g.sendUILocation(actorCtx)
+	}
+
+	// they've seen these changes, so don't tell them again on their next
+	// visit, which is written to the journal once they leave
+	g.seenLocation = location
+}
+
+// refreshLocationSnapshot takes the changes the player has just made to the
+// location as already seen
+func (g *Game) refreshLocationSnapshot() {
+	if g.locationSnapshot == nil {
+		return
+	}
+
+	location, err := g.currentLocationTree()
+	if err != nil {
+		log.Warningf("error fetching location: %v", err)
+		return
+	}
+	snapshot, err := g.snapshotLocation(location)
+	if err != nil {
+		log.Warningf("error snapshotting location: %v", err)
+		return
+	}
+	g.locationSnapshot = snapshot
+	g.seenLocation = location
+}
+
+// leaveLocation records the changes the player saw while they were here
+func (g *Game) leaveLocation() {
+	if g.seenLocation == nil {
+		return
+	}
+
+	err := g.markLocationSeen(g.seenLocation)
+	if err != nil {
+		log.Warningf("error updating journal: %v", err)
+	}
+	g.seenLocation = nil
+}
+
+func (g *Game) sendLocationChanges(actorCtx actor.Context) {
+	if len(g.locationChanges) == 0 {
+		return
+	}
+	g.sendUserMessage(actorCtx, g.locationChanges)
+	g.locationChanges = nil
+}
@@ -0,0 +1,80 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribeLocationChanges(t *testing.T) {
+	before := &locationSnapshot{
+		description:  "a bare room",
+		objects:      map[string]string{"did:sword": "sword", "did:shield": "shield"},
+		exits:        map[string]bool{"go north": true, "go south": true},
+		interactions: map[string]bool{"wave": true},
+	}
+
+	require.Nil(t, describeLocationChanges(before, before))
+
+	after := &locationSnapshot{
+		description:  "a cozy room",
+		objects:      map[string]string{"did:sword": "sword", "did:lamp": "lamp", "did:rug": "rug"},
+		exits:        map[string]bool{"go north": true, "climb the ladder": true},
+		interactions: map[string]bool{"light the fire": true},
+	}
+
+	require.Equal(t, indentedList{
+		"since you were last here:",
+		"the description has changed",
+		"new here: lamp, rug",
+		"gone: shield",
+		"new ways out: climb the ladder",
+		"ways out that are gone: go south",
+		"new things to do: light the fire",
+	}, describeLocationChanges(before, after))
+}
+
+func TestDescribeLiveLocationChanges(t *testing.T) {
+	before := &locationSnapshot{
+		description:  "a bare room",
+		objects:      map[string]string{"did:sword": "sword"},
+		exits:        map[string]bool{"go north": true},
+		interactions: map[string]bool{},
+	}
+	after := &locationSnapshot{
+		description:  "a bare room",
+		objects:      map[string]string{"did:lantern": "lantern", "did:apple": "apple"},
+		exits:        map[string]bool{portalCommand: true},
+		interactions: map[string]bool{"wave": true},
+	}
+
+	require.Equal(t, []string{
+		"An apple appears on the ground.",
+		"A lantern appears on the ground.",
+		"The sword is no longer here.",
+		"A mysterious portal opens.",
+		"The way out through go north is gone.",
+		"Something new can be done here: wave.",
+	}, describeLiveLocationChanges(diffLocationSnapshots(before, after)))
+
+	require.Empty(t, describeLiveLocationChanges(diffLocationSnapshots(after, after)))
+}
+
+func TestLocationSnapshotWithOwnObjectMoves(t *testing.T) {
+	before := &locationSnapshot{
+		objects: map[string]string{"did:sword": "sword", "did:shield": "shield"},
+	}
+	after := &locationSnapshot{
+		objects: map[string]string{"did:sword": "sword", "did:lamp": "lamp", "did:rug": "rug"},
+	}
+
+	// the player dropped the lamp and picked up the shield, and has dropped a
+	// hat that hasn't arrived yet
+	moves := map[string]bool{"did:lamp": true, "did:shield": false, "did:hat": true}
+
+	changes := diffLocationSnapshots(before.withOwnObjectMoves(moves, after), after)
+	require.Equal(t, []string{"rug"}, changes.newObjects)
+	require.Empty(t, changes.removedObjects)
+	require.Equal(t, map[string]bool{"did:hat": true}, moves)
+	require.Len(t, before.objects, 2)
+}
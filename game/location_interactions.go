@@ -0,0 +1,187 @@
+package game
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/AsynkronIT/protoactor-go/actor"
+	"github.com/pkg/errors"
+)
+
+// editInteractionRegex splits `CMD = VALUE`, for editing the response of
+// an interaction and for renaming it
+var editInteractionRegex = regexp.MustCompile(`^(.+?)\s*=\s*(.+)$`)
+
+// ownedCurrentLocation returns the current location if the player owns it
+func (g *Game) ownedCurrentLocation() (*LocationTree, error) {
+	tree, err := g.network.GetTree(g.locationDid)
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching location")
+	}
+	if tree == nil {
+		return nil, fmt.Errorf("could not find location %s", g.locationDid)
+	}
+
+	loc := NewLocationTree(g.network, tree)
+
+	auths, err := g.playerTree.Authentications()
+	if err != nil {
+		return nil, fmt.Errorf("error fetching player authentications")
+	}
+	isOwnedBy, _ := loc.IsOwnedBy(auths)
+	if !isOwnedBy {
+		return nil, fmt.Errorf("you can only change interactions on land you own")
+	}
+
+	return loc, nil
+}
+
+func (g *Game) handleListLocationInteractions(actorCtx actor.Context) error {
+	loc, err := g.ownedCurrentLocation()
+	if err != nil {
+		return err
+	}
+
+	interactions, err := loc.InteractionsList()
+	if err != nil {
+		return errors.Wrap(err, "error fetching interactions")
+	}
+
+	if len(interactions) == 0 {
+		g.sendUserMessage(actorCtx, "this location has no interactions")
+		return nil
+	}
+
+	sort.Slice(interactions, func(i, j int) bool {
+		return interactions[i].GetCommand() < interactions[j].GetCommand()
+	})
+
+	toSend := indentedList{"interactions on this location:"}
+	for _, interaction := range interactions {
+		toSend = append(toSend, describeInteraction(interaction))
+	}
+	g.sendUserMessage(actorCtx, toSend)
+	return nil
+}
+
+func (g *Game) handleRemoveLocationInteraction(actorCtx actor.Context, command string) error {
+	if command == "" {
+		return fmt.Errorf("must specify an interaction in the syntax of: remove interaction CMD")
+	}
+
+	_, err := g.ownedCurrentLocation()
+	if err != nil {
+		return err
+	}
+
+	result, err := actorCtx.RequestFuture(g.locationActor, &RemoveInteractionRequest{Command: command}, 30*time.Second).Result()
+	if err != nil {
+		return fmt.Errorf("error removing interaction: %v", err)
+	}
+
+	resp, ok := result.(*RemoveInteractionResponse)
+	if !ok {
+		return fmt.Errorf("error casting remove interaction response")
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("error removing interaction: %v", resp.Error)
+	}
+
+	g.refreshLocationSnapshot()
+	g.sendUserMessage(actorCtx, fmt.Sprintf("removed the interaction %s", command))
+	return g.refreshInteractionsFor(actorCtx, g.locationActor)
+}
+
+func (g *Game) handleEditLocationInteraction(actorCtx actor.Context, args string) error {
+	matches := editInteractionRegex.FindStringSubmatch(args)
+	if len(matches) != 3 {
+		return fmt.Errorf("must edit interactions in the syntax of: edit interaction CMD = RESPONSE")
+	}
+
+	return g.replaceLocationInteraction(actorCtx, matches[1], func(interaction Interaction) error {
+		return setInteractionResponse(interaction, matches[2])
+	})
+}
+
+func (g *Game) handleRenameLocationInteraction(actorCtx actor.Context, args string) error {
+	matches := editInteractionRegex.FindStringSubmatch(args)
+	if len(matches) != 3 {
+		return fmt.Errorf("must rename interactions in the syntax of: rename interaction CMD = NEW CMD")
+	}
+
+	return g.replaceLocationInteraction(actorCtx, matches[1], func(interaction Interaction) error {
+		return setInteractionCommand(interaction, matches[2])
+	})
+}
+
+// replaceLocationInteraction changes the interaction with command on the
+// current location with edit
+func (g *Game) replaceLocationInteraction(actorCtx actor.Context, command string, edit func(Interaction) error) error {
+	loc, err := g.ownedCurrentLocation()
+	if err != nil {
+		return err
+	}
+
+	interaction, err := loc.getInteractionFromTree(loc, command)
+	if err != nil {
+		return errors.Wrap(err, "error fetching interaction")
+	}
+	if interaction == nil {
+		return fmt.Errorf("interaction %s does not exist", command)
+	}
+
+	err = edit(interaction)
+	if err != nil {
+		return err
+	}
+
+	result, err := actorCtx.RequestFuture(g.locationActor, &ReplaceInteractionRequest{
+		Command:     command,
+		Interaction: interaction,
+	}, 30*time.Second).Result()
+	if err != nil {
+		return fmt.Errorf("error editing interaction: %v", err)
+	}
+
+	resp, ok := result.(*ReplaceInteractionResponse)
+	if !ok {
+		return fmt.Errorf("error casting replace interaction response")
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("error editing interaction: %v", resp.Error)
+	}
+
+	g.refreshLocationSnapshot()
+	g.sendUserMessage(actorCtx, fmt.Sprintf("updated the interaction %s", interaction.GetCommand()))
+	return g.refreshInteractionsFor(actorCtx, g.locationActor)
+}
+
+func describeInteraction(interaction Interaction) string {
+	typeName := reflect.TypeOf(interaction).Elem().Name()
+	if respond, ok := interaction.(*RespondInteraction); ok {
+		return fmt.Sprintf("%s (%s): %s", respond.Command, typeName, respond.Response)
+	}
+	return fmt.Sprintf("%s (%s)", interaction.GetCommand(), typeName)
+}
+
+// setInteractionCommand relies on all protobuf interactions having a command field
+func setInteractionCommand(interaction Interaction, command string) error {
+	field := reflect.ValueOf(interaction).Elem().FieldByName("Command")
+	if !field.IsValid() || field.Kind() != reflect.String || !field.CanSet() {
+		return fmt.Errorf("the command of %T can't be edited", interaction)
+	}
+	field.SetString(command)
+	return nil
+}
+
+func setInteractionResponse(interaction Interaction, response string) error {
+	respond, ok := interaction.(*RespondInteraction)
+	if !ok {
+		return fmt.Errorf("only responses can be edited, remove the interaction and add it again instead")
+	}
+	respond.Response = response
+	return nil
+}
@@ -4,18 +4,29 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/ipfs/go-cid"
 	"github.com/pkg/errors"
+	"github.com/quorumcontrol/chaintree/chaintree"
 	"github.com/quorumcontrol/chaintree/typecaster"
 	"github.com/quorumcontrol/jasons-game/game/trees"
+	"github.com/quorumcontrol/jasons-game/handlers/chatlog"
 	"github.com/quorumcontrol/jasons-game/network"
 	"github.com/quorumcontrol/jasons-game/pb/jasonsgame"
+	"github.com/quorumcontrol/jasons-game/utils/stringslice"
+	"github.com/quorumcontrol/messages/build/go/transactions"
 	"github.com/quorumcontrol/tupelo-go-sdk/consensus"
 )
 
 var portalPath = []string{"portal"}
 
+var buildersPath = []string{"builders"}
+
+var chatLogPath = []string{chatlog.EnabledPath}
+
+var accessPolicyPath = []string{"access"}
+
 type LocationTree struct {
 	tree    *consensus.SignedChainTree
 	network network.Network
@@ -45,6 +56,38 @@ func (l *LocationTree) Tip() cid.Cid {
 	return l.tree.Tip()
 }
 
+// AtTip returns the location as it was at tip, which can only be read from
+func (l *LocationTree) AtTip(tip cid.Cid) (*LocationTree, error) {
+	treeAt, err := l.tree.ChainTree.At(context.TODO(), &tip)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("error fetching location at %s", tip.String()))
+	}
+	return NewLocationTree(l.network, consensus.NewSignedChainTreeFromChainTree(treeAt)), nil
+}
+
+// AtHeight returns the location as it was at height, which can only be read from
+func (l *LocationTree) AtHeight(height uint64) (*LocationTree, error) {
+	treeAt, err := trees.AtHeight(context.TODO(), l.tree.ChainTree, height)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("error fetching location at height %d", height))
+	}
+	return NewLocationTree(l.network, consensus.NewSignedChainTreeFromChainTree(treeAt)), nil
+}
+
+func (l *LocationTree) Height() (uint64, error) {
+	return trees.Height(context.TODO(), l.tree.ChainTree)
+}
+
+// Signers are the addresses of the keys that made the latest change
+func (l *LocationTree) Signers() ([]string, error) {
+	return trees.Signers(context.TODO(), l.tree.ChainTree)
+}
+
+// UpdatedAt is when the latest change was made, zero if its block doesn't say
+func (l *LocationTree) UpdatedAt() (time.Time, error) {
+	return trees.BlockTime(context.TODO(), l.tree.ChainTree)
+}
+
 func (l *LocationTree) GetDescription() (string, error) {
 	val, err := l.getPath([]string{"description"})
 	if err != nil || val == nil {
@@ -57,6 +100,47 @@ func (l *LocationTree) SetDescription(description string) error {
 	return l.updatePath([]string{"description"}, description)
 }
 
+// ChatLogEnabled is true when the owner wants recent chat kept for players
+// arriving later
+func (l *LocationTree) ChatLogEnabled() (bool, error) {
+	val, err := l.getPath(chatLogPath)
+	if err != nil || val == nil {
+		return false, err
+	}
+
+	enabled, ok := val.(bool)
+	if !ok {
+		return false, fmt.Errorf("error casting chat-log; type is %T", val)
+	}
+	return enabled, nil
+}
+
+func (l *LocationTree) SetChatLogEnabled(enabled bool) error {
+	return l.updatePath(chatLogPath, enabled)
+}
+
+// AccessPolicy is who may enter the location, nil when anyone can
+func (l *LocationTree) AccessPolicy() (*jasonsgame.AccessPolicy, error) {
+	val, err := l.getPath(accessPolicyPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching access policy")
+	}
+	if val == nil {
+		return nil, nil
+	}
+
+	policy := new(jasonsgame.AccessPolicy)
+	err = typecaster.ToType(val, policy)
+	if err != nil {
+		return nil, errors.Wrap(err, "error casting access policy")
+	}
+	return policy, nil
+}
+
+func (l *LocationTree) SetAccessPolicy(policy *jasonsgame.AccessPolicy) error {
+	return l.updatePath(accessPolicyPath, policy)
+}
+
 func (l *LocationTree) AddInteraction(i Interaction) error {
 	return l.addInteractionToTree(l, i)
 }
@@ -65,6 +149,14 @@ func (l *LocationTree) InteractionsList() ([]Interaction, error) {
 	return l.interactionsListFromTree(l)
 }
 
+func (l *LocationTree) RemoveInteraction(command string) error {
+	return l.removeInteractionFromTree(l, command)
+}
+
+func (l *LocationTree) ReplaceInteraction(command string, i Interaction) error {
+	return l.replaceInteractionInTree(l, command, i)
+}
+
 func (l *LocationTree) SetHandler(handlerDid string) error {
 	locationAuths, err := l.tree.Authentications()
 	if err != nil {
@@ -96,6 +188,125 @@ func (l *LocationTree) SetHandler(handlerDid string) error {
 	return nil
 }
 
+// Builders are the players the owner has let build here, by did, with the
+// key addresses they were added to the location's authentications with
+func (l *LocationTree) Builders() (map[string][]string, error) {
+	val, err := l.getPath(buildersPath)
+	if err != nil || val == nil {
+		return map[string][]string{}, err
+	}
+
+	stored, ok := val.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("error casting builders; type is %T", val)
+	}
+
+	builders := make(map[string][]string, len(stored))
+	for did, addrs := range stored {
+		if addrs == nil {
+			continue
+		}
+		addrList, ok := addrs.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("error casting builder %s; type is %T", did, addrs)
+		}
+		for _, uncastAddr := range addrList {
+			addr, ok := uncastAddr.(string)
+			if !ok {
+				return nil, fmt.Errorf("error casting builder %s address; type is %T", did, uncastAddr)
+			}
+			builders[did] = append(builders[did], addr)
+		}
+	}
+	return builders, nil
+}
+
+// AddBuilder adds the keys of the player with did to the location's
+// authentications, so they can change it too
+func (l *LocationTree) AddBuilder(did string, keyAddrs []string) error {
+	locationAuths, err := l.tree.Authentications()
+	if err != nil {
+		return errors.Wrap(err, "error fetching location auths")
+	}
+
+	newAuths := locationAuths
+	for _, addr := range keyAddrs {
+		if !stringslice.Include(newAuths, addr) {
+			newAuths = append(newAuths, addr)
+		}
+	}
+
+	err = l.changeBuilders(newAuths, did, keyAddrs)
+	if err != nil {
+		return errors.Wrap(err, "error adding builder")
+	}
+	return nil
+}
+
+// RemoveBuilder takes the keys of the builder with did back out of the
+// location's authentications, except for any in keep or belonging to
+// another builder
+func (l *LocationTree) RemoveBuilder(did string, keep []string) error {
+	builders, err := l.Builders()
+	if err != nil {
+		return err
+	}
+	removing, ok := builders[did]
+	if !ok {
+		return fmt.Errorf("%s isn't a builder", did)
+	}
+
+	keep = append([]string{}, keep...)
+	for builderDid, addrs := range builders {
+		if builderDid != did {
+			keep = append(keep, addrs...)
+		}
+	}
+
+	locationAuths, err := l.tree.Authentications()
+	if err != nil {
+		return errors.Wrap(err, "error fetching location auths")
+	}
+
+	newAuths := []string{}
+	for _, addr := range locationAuths {
+		if !stringslice.Include(removing, addr) || stringslice.Include(keep, addr) {
+			newAuths = append(newAuths, addr)
+		}
+	}
+
+	err = l.changeBuilders(newAuths, did, nil)
+	if err != nil {
+		return errors.Wrap(err, "error removing builder")
+	}
+	return nil
+}
+
+// changeBuilders sets the location's owners and the keys of the builder
+// with did in one block, so they can't get out of step
+func (l *LocationTree) changeBuilders(auths []string, did string, keyAddrs []string) error {
+	ownershipTransaction, err := chaintree.NewSetOwnershipTransaction(auths)
+	if err != nil {
+		return errors.Wrap(err, "error creating set ownership transaction")
+	}
+
+	var val interface{}
+	if keyAddrs != nil {
+		val = keyAddrs
+	}
+	dataTransaction, err := chaintree.NewSetDataTransaction(strings.Join(append([]string{"jasons-game"}, append(buildersPath, did)...), "/"), val)
+	if err != nil {
+		return errors.Wrap(err, "error creating set data transaction")
+	}
+
+	newTree, err := l.network.PlayTransactions(l.tree, []*transactions.Transaction{ownershipTransaction, dataTransaction})
+	if err != nil {
+		return err
+	}
+	l.tree = newTree
+	return nil
+}
+
 func (l *LocationTree) BuildPortal(toDid string) error {
 	currentPortal, err := l.GetPortal()
 
@@ -149,7 +360,26 @@ func (l *LocationTree) IsOwnedBy(keyAddrs []string) (bool, error) {
 }
 
 func (l *LocationTree) updatePath(path []string, val interface{}) error {
-	newTree, err := l.network.UpdateChainTree(l.tree, strings.Join(append([]string{"jasons-game"}, path...), "/"), val)
+	transaction, err := chaintree.NewSetDataTransaction(strings.Join(append([]string{"jasons-game"}, path...), "/"), val)
+	if err != nil {
+		return errors.Wrap(err, "error creating set data transaction")
+	}
+
+	newTree, err := l.network.PlayTransactions(l.tree, []*transactions.Transaction{transaction})
+	if err != nil {
+		return err
+	}
+	l.tree = newTree
+	return nil
+}
+
+func (l *LocationTree) updatePaths(updates []pathUpdate) error {
+	txs, err := setDataTransactions(updates)
+	if err != nil {
+		return err
+	}
+
+	newTree, err := l.network.PlayTransactions(l.tree, txs)
 	if err != nil {
 		return err
 	}
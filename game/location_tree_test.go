@@ -4,6 +4,7 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/quorumcontrol/jasons-game/handlers"
 	"github.com/quorumcontrol/jasons-game/network"
+	"github.com/quorumcontrol/jasons-game/pb/jasonsgame"
 	"github.com/stretchr/testify/require"
 	"testing"
 )
@@ -29,3 +30,101 @@ func TestLocationTree_SetHandler(t *testing.T) {
 	require.Nil(t, err)
 	require.Equal(t, handlerTree.MustId(), handler.Did())
 }
+
+func TestLocationTree_AtHeight(t *testing.T) {
+	net := network.NewLocalNetwork()
+
+	locationTree, err := net.CreateChainTree()
+	require.Nil(t, err)
+	location := NewLocationTree(net, locationTree)
+
+	err = location.SetDescription("a quiet meadow")
+	require.Nil(t, err)
+	firstHeight, err := location.Height()
+	require.Nil(t, err)
+
+	err = location.SetDescription("a meadow full of tents")
+	require.Nil(t, err)
+
+	then, err := location.AtHeight(firstHeight)
+	require.Nil(t, err)
+	description, err := then.GetDescription()
+	require.Nil(t, err)
+	require.Equal(t, "a quiet meadow", description)
+
+	updatedAt, err := then.UpdatedAt()
+	require.Nil(t, err)
+	require.False(t, updatedAt.IsZero())
+
+	now, err := location.AtTip(location.Tip())
+	require.Nil(t, err)
+	description, err = now.GetDescription()
+	require.Nil(t, err)
+	require.Equal(t, "a meadow full of tents", description)
+}
+
+func TestLocationTree_AccessPolicy(t *testing.T) {
+	net := network.NewLocalNetwork()
+
+	locationTree, err := net.CreateChainTree()
+	require.Nil(t, err)
+	location := NewLocationTree(net, locationTree)
+
+	policy, err := location.AccessPolicy()
+	require.Nil(t, err)
+	require.Nil(t, policy)
+
+	err = location.SetAccessPolicy(&jasonsgame.AccessPolicy{
+		Mode:    accessModeAllowlist,
+		Allowed: []string{"did:tupelo:friend"},
+		Banned:  []string{"did:tupelo:troll"},
+	})
+	require.Nil(t, err)
+
+	policy, err = location.AccessPolicy()
+	require.Nil(t, err)
+	require.Equal(t, accessModeAllowlist, policy.Mode)
+	require.Equal(t, []string{"did:tupelo:friend"}, policy.Allowed)
+	require.Equal(t, []string{"did:tupelo:troll"}, policy.Banned)
+}
+
+func TestLocationTree_Builders(t *testing.T) {
+	net := network.NewLocalNetwork()
+
+	locationTree, err := net.CreateChainTree()
+	require.Nil(t, err)
+	location := NewLocationTree(net, locationTree)
+
+	ownerAuths, err := locationTree.Authentications()
+	require.Nil(t, err)
+
+	key, err := crypto.GenerateKey()
+	require.Nil(t, err)
+	builderAddr := crypto.PubkeyToAddress(key.PublicKey).String()
+
+	err = location.AddBuilder("did:tupelo:builder", []string{builderAddr})
+	require.Nil(t, err)
+
+	builders, err := location.Builders()
+	require.Nil(t, err)
+	require.Equal(t, map[string][]string{"did:tupelo:builder": {builderAddr}}, builders)
+
+	auths, err := location.Tree().Authentications()
+	require.Nil(t, err)
+	require.ElementsMatch(t, append(ownerAuths, builderAddr), auths)
+
+	signers, err := location.Signers()
+	require.Nil(t, err)
+	require.Equal(t, []string{crypto.PubkeyToAddress(*net.PublicKey()).String()}, signers)
+
+	err = location.RemoveBuilder("did:tupelo:builder", ownerAuths)
+	require.Nil(t, err)
+
+	builders, err = location.Builders()
+	require.Nil(t, err)
+	require.Empty(t, builders)
+
+	auths, err = location.Tree().Authentications()
+	require.Nil(t, err)
+	require.ElementsMatch(t, ownerAuths, auths)
+}
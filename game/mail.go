@@ -0,0 +1,247 @@
+package game
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AsynkronIT/protoactor-go/actor"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+
+	"github.com/quorumcontrol/jasons-game/game/mail"
+)
+
+const mailTimeFormat = "2006-01-02 15:04"
+
+// mailPreviewLength is how much of a letter the inbox shows
+const mailPreviewLength = 30
+
+// publishEncryptionKey lets other players encrypt mail to this one
+func (g *Game) publishEncryptionKey() error {
+	pubKey := crypto.FromECDSAPub(g.network.PublicKey())
+
+	existing, err := g.playerTree.EncryptionPubKey()
+	if err != nil {
+		return errors.Wrap(err, "error fetching encryption key")
+	}
+	if bytes.Equal(existing, pubKey) {
+		return nil
+	}
+
+	return g.playerTree.SetEncryptionPubKey(pubKey)
+}
+
+func (g *Game) newMailClient() *mail.Client {
+	if g.mailClient != nil {
+		return g.mailClient
+	}
+
+	client, err := mail.NewClient(g.network)
+	if err != nil {
+		log.Debugf("mail is unavailable: %v", err)
+		return nil
+	}
+	return client
+}
+
+func (g *Game) fetchInbox(actorCtx actor.Context) (*Inbox, error) {
+	response, err := actorCtx.RequestFuture(g.mailActor, &GetInbox{}, 10*time.Second).Result()
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching mail")
+	}
+
+	inbox, ok := response.(*Inbox)
+	if !ok {
+		return nil, fmt.Errorf("error casting Inbox")
+	}
+	if !inbox.Disabled {
+		g.inbox = inbox.Letters
+	}
+	return inbox, nil
+}
+
+func (g *Game) requestMail(actorCtx actor.Context, request interface{}) error {
+	response, err := actorCtx.RequestFuture(g.mailActor, request, 10*time.Second).Result()
+	if err != nil {
+		return err
+	}
+
+	mailResponse, ok := response.(*MailResponse)
+	if !ok {
+		return fmt.Errorf("error casting MailResponse")
+	}
+	return mailResponse.Error
+}
+
+func (g *Game) handleInbox(actorCtx actor.Context) error {
+	inbox, err := g.fetchInbox(actorCtx)
+	if err != nil {
+		return err
+	}
+
+	if inbox.Disabled {
+		g.sendUserMessage(actorCtx, "mail isn't available right now")
+		return nil
+	}
+	if len(inbox.Letters) == 0 {
+		g.sendUserMessage(actorCtx, "your inbox is empty")
+		return nil
+	}
+
+	toSend := indentedList{"your mail, type `read mail <number>` to read one:"}
+	for i, letter := range inbox.Letters {
+		line := fmt.Sprintf("%d. from %s, %s - %s", i+1, letter.Name, formatMailTime(letter.Mail.SentAt), mailPreview(letter.Mail.Body))
+		if !letter.Read {
+			line += " (new)"
+		}
+		toSend = append(toSend, line)
+	}
+	g.sendUserMessage(actorCtx, toSend)
+	return nil
+}
+
+func formatMailTime(sentAt int64) string {
+	return time.Unix(sentAt, 0).Format(mailTimeFormat)
+}
+
+func mailPreview(body string) string {
+	body = strings.Join(strings.Fields(body), " ")
+	if len(body) <= mailPreviewLength {
+		return body
+	}
+	return body[:mailPreviewLength] + "..."
+}
+
+// letterNumbered finds a letter by its number in the inbox, or nil after
+// telling the player why it couldn't
+func (g *Game) letterNumbered(actorCtx actor.Context, numberArg string) (*Letter, error) {
+	numberArg = strings.TrimSpace(numberArg)
+	if numberArg == "" {
+		g.sendUserMessage(actorCtx, "which letter? e.g. `read mail 1`, type `inbox` to see your mail")
+		return nil, nil
+	}
+
+	if g.inbox == nil {
+		inbox, err := g.fetchInbox(actorCtx)
+		if err != nil {
+			return nil, err
+		}
+		if inbox.Disabled {
+			g.sendUserMessage(actorCtx, "mail isn't available right now")
+			return nil, nil
+		}
+	}
+
+	number, err := strconv.Atoi(numberArg)
+	if err != nil || number < 1 || number > len(g.inbox) {
+		g.sendUserMessage(actorCtx, fmt.Sprintf("there's no letter numbered %s, type `inbox` to see your mail", numberArg))
+		return nil, nil
+	}
+	return g.inbox[number-1], nil
+}
+
+func (g *Game) handleReadMail(actorCtx actor.Context, args string) error {
+	letter, err := g.letterNumbered(actorCtx, args)
+	if err != nil || letter == nil {
+		return err
+	}
+
+	if !letter.Read {
+		err = g.ds.Put(mailReadKey(letter.Id), []byte{1})
+		if err != nil {
+			return errors.Wrap(err, "error marking mail read")
+		}
+		letter.Read = true
+	}
+
+	g.sendUserMessage(actorCtx, indentedList{
+		fmt.Sprintf("from %s, %s:", letter.Name, formatMailTime(letter.Mail.SentAt)),
+		letter.Mail.Body,
+		"type `reply <number> <message>` to reply",
+	})
+	return nil
+}
+
+func (g *Game) handleReplyMail(actorCtx actor.Context, args string) error {
+	fields := strings.SplitN(strings.TrimSpace(args), " ", 2)
+	if len(fields) < 2 || strings.TrimSpace(fields[1]) == "" {
+		g.sendUserMessage(actorCtx, "reply to which letter, with what? e.g. `reply 1 thanks!`")
+		return nil
+	}
+
+	letter, err := g.letterNumbered(actorCtx, fields[0])
+	if err != nil || letter == nil {
+		return err
+	}
+
+	return g.sendMail(actorCtx, letter.Name, &SendMailRequest{
+		To:        letter.Mail.From,
+		Body:      strings.TrimSpace(fields[1]),
+		InReplyTo: letter.Id,
+	})
+}
+
+func (g *Game) handleDeleteMail(actorCtx actor.Context, args string) error {
+	letter, err := g.letterNumbered(actorCtx, args)
+	if err != nil || letter == nil {
+		return err
+	}
+
+	err = g.requestMail(actorCtx, &DeleteMailRequest{Id: letter.Id})
+	if err != nil {
+		return errors.Wrap(err, "error deleting mail")
+	}
+
+	// numbers change once it's gone, so make them check the inbox again
+	g.inbox = nil
+	g.sendUserMessage(actorCtx, fmt.Sprintf("deleted the letter from %s", letter.Name))
+	return nil
+}
+
+// handleSendMail sends a letter to anyone in the location by name, or
+// anyone at all by did
+func (g *Game) handleSendMail(actorCtx actor.Context, args string) error {
+	args = strings.TrimSpace(args)
+	if args == "" {
+		g.sendUserMessage(actorCtx, "mail who? e.g. `mail jason see you tomorrow`")
+		return nil
+	}
+
+	occupants, err := g.getOccupants(actorCtx)
+	if err != nil {
+		return err
+	}
+
+	name, to, body := whisperRecipient(occupants.Names, args)
+	if to == "" {
+		g.sendUserMessage(actorCtx, "there's nobody here by that name, mail someone elsewhere by their did")
+		return nil
+	}
+	if body == "" {
+		g.sendUserMessage(actorCtx, fmt.Sprintf("what do you want to say to %s?", name))
+		return nil
+	}
+
+	return g.sendMail(actorCtx, name, &SendMailRequest{To: to, Body: body})
+}
+
+func (g *Game) sendMail(actorCtx actor.Context, name string, request *SendMailRequest) error {
+	err := g.requestMail(actorCtx, request)
+	if err == errNoMailbox {
+		g.sendUserMessage(actorCtx, err.Error())
+		return nil
+	}
+	if errors.Cause(err) == mail.ErrNoRecipientKey {
+		g.sendUserMessage(actorCtx, fmt.Sprintf("%s can't receive mail yet", name))
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "error sending mail")
+	}
+
+	g.sendUserMessage(actorCtx, fmt.Sprintf("your letter to %s is on its way", name))
+	return nil
+}
@@ -0,0 +1,230 @@
+package mail
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/ecies"
+	"github.com/gogo/protobuf/proto"
+	"github.com/pkg/errors"
+	"github.com/quorumcontrol/jasons-game/game/static"
+	"github.com/quorumcontrol/jasons-game/handlers"
+	"github.com/quorumcontrol/jasons-game/handlers/mailbox"
+	"github.com/quorumcontrol/jasons-game/network"
+	"github.com/quorumcontrol/jasons-game/pb/jasonsgame"
+	"github.com/quorumcontrol/jasons-game/utils/stringslice"
+)
+
+// EncryptionPubKeyPath is where players publish the key mail to them is
+// encrypted with
+var EncryptionPubKeyPath = []string{"tree", "data", "jasons-game", "encryption-pubkey"}
+
+var ErrNoRecipientKey = errors.New("recipient hasn't published an encryption key")
+
+type Client struct {
+	network network.Network
+	handler handlers.Handler
+}
+
+func NewClient(net network.Network) (*Client, error) {
+	did, err := static.Get(net, "Mailbox")
+	if err != nil {
+		return nil, err
+	}
+
+	if did == "" {
+		return nil, fmt.Errorf("Mailbox service has not yet been established: static.Mailbox is empty")
+	}
+
+	handler, err := handlers.GetRemoteHandler(net, did)
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching mailbox handler")
+	}
+
+	return NewClientWithHandler(net, handler), nil
+}
+
+func NewClientWithHandler(net network.Network, handler handlers.Handler) *Client {
+	return &Client{
+		network: net,
+		handler: handler,
+	}
+}
+
+// EncryptionPubKey is the published key of the player with did, which must
+// belong to one of the owners of their tree
+func (c *Client) EncryptionPubKey(did string) (*ecdsa.PublicKey, error) {
+	tree, err := c.network.GetTree(did)
+	if err != nil {
+		return nil, errors.Wrap(err, "error finding tree")
+	}
+	if tree == nil {
+		return nil, fmt.Errorf("player %s not found", did)
+	}
+
+	encryptionPubKeyRaw, _, err := tree.ChainTree.Dag.Resolve(context.Background(), EncryptionPubKeyPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "error finding pubkey")
+	}
+	encryptionPubKeyBytes, ok := encryptionPubKeyRaw.([]byte)
+	if !ok || len(encryptionPubKeyBytes) == 0 {
+		return nil, ErrNoRecipientKey
+	}
+
+	encryptionPubKey, err := crypto.UnmarshalPubkey(encryptionPubKeyBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "error converting pubkey")
+	}
+
+	auths, err := tree.Authentications()
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching auths")
+	}
+	if !stringslice.Include(auths, crypto.PubkeyToAddress(*encryptionPubKey).String()) {
+		return nil, ErrNoRecipientKey
+	}
+
+	return encryptionPubKey, nil
+}
+
+// Send signs the mail with this network's key and delivers it to the mailbox
+func (c *Client) Send(to string, msg *jasonsgame.Mail) error {
+	encryptionPubKey, err := c.EncryptionPubKey(to)
+	if err != nil {
+		return err
+	}
+
+	err = Sign(msg, c.network.PrivateKey())
+	if err != nil {
+		return err
+	}
+
+	marshaled, err := proto.Marshal(msg)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling")
+	}
+
+	encrypted, err := ecies.Encrypt(rand.Reader, ecies.ImportECDSAPublic(encryptionPubKey), marshaled, nil, nil)
+	if err != nil {
+		return errors.Wrap(err, "error encrypting")
+	}
+
+	id := mailbox.MailId(encrypted)
+	signature, err := crypto.Sign(mailbox.SendSigningHash(to, id, msg.From, msg.SentAt), c.network.PrivateKey())
+	if err != nil {
+		return errors.Wrap(err, "error signing")
+	}
+
+	return c.handler.Handle(&jasonsgame.MailEncrypted{
+		Id:        id,
+		To:        to,
+		Encrypted: encrypted,
+		From:      msg.From,
+		Signature: signature,
+		SentAt:    msg.SentAt,
+	})
+}
+
+// Request asks the mailbox for the mail to did, which is sent on its
+// mailbox.ReplyTopic
+func (c *Client) Request(did string) error {
+	sentAt := time.Now().Unix()
+	signature, err := crypto.Sign(mailbox.RequestSigningHash(did, sentAt), c.network.PrivateKey())
+	if err != nil {
+		return errors.Wrap(err, "error signing")
+	}
+	return c.handler.Handle(&jasonsgame.MailboxRequest{To: did, SentAt: sentAt, Signature: signature})
+}
+
+func (c *Client) Delete(did string, id string) error {
+	signature, err := crypto.Sign(mailbox.DeleteSigningHash(did, id), c.network.PrivateKey())
+	if err != nil {
+		return errors.Wrap(err, "error signing")
+	}
+	return c.handler.Handle(&jasonsgame.MailDeleteRequest{To: did, Id: id, Signature: signature})
+}
+
+// Open decrypts mail with this network's key, and verifies who it's from
+func (c *Client) Open(encrypted *jasonsgame.MailEncrypted) (*jasonsgame.Mail, error) {
+	decrypted, err := ecies.ImportECDSA(c.network.PrivateKey()).Decrypt(encrypted.Encrypted, nil, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decrypting")
+	}
+
+	msg := &jasonsgame.Mail{}
+	err = proto.Unmarshal(decrypted, msg)
+	if err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling")
+	}
+
+	if msg.From != encrypted.From {
+		return nil, fmt.Errorf("mail from %s was delivered as from %s", msg.From, encrypted.From)
+	}
+	if msg.SentAt != encrypted.SentAt {
+		return nil, fmt.Errorf("mail sent at %d was delivered as sent at %d", msg.SentAt, encrypted.SentAt)
+	}
+
+	signer, err := Signer(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := c.network.GetTree(msg.From)
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching sender")
+	}
+	if tree == nil {
+		return nil, fmt.Errorf("sender %s not found", msg.From)
+	}
+	auths, err := tree.Authentications()
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching sender auths")
+	}
+	if !stringslice.Include(auths, signer) {
+		return nil, fmt.Errorf("mail claiming to be from %s wasn't signed by them", msg.From)
+	}
+
+	return msg, nil
+}
+
+func signingHash(msg *jasonsgame.Mail) ([]byte, error) {
+	unsigned := proto.Clone(msg).(*jasonsgame.Mail)
+	unsigned.Signature = nil
+
+	bits, err := proto.Marshal(unsigned)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling mail")
+	}
+	return crypto.Keccak256(bits), nil
+}
+
+func Sign(msg *jasonsgame.Mail, key *ecdsa.PrivateKey) error {
+	hash, err := signingHash(msg)
+	if err != nil {
+		return err
+	}
+
+	msg.Signature, err = crypto.Sign(hash, key)
+	if err != nil {
+		return errors.Wrap(err, "error signing mail")
+	}
+	return nil
+}
+
+// Signer returns the address of the key that signed the mail
+func Signer(msg *jasonsgame.Mail) (string, error) {
+	hash, err := signingHash(msg)
+	if err != nil {
+		return "", err
+	}
+
+	pubKey, err := crypto.SigToPub(hash, msg.Signature)
+	if err != nil {
+		return "", errors.Wrap(err, "error recovering signer")
+	}
+	return crypto.PubkeyToAddress(*pubKey).String(), nil
+}
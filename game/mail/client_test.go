@@ -0,0 +1,84 @@
+package mail
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gogo/protobuf/proto"
+	messages "github.com/quorumcontrol/messages/build/go/community"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quorumcontrol/jasons-game/handlers/mailbox"
+	"github.com/quorumcontrol/jasons-game/network"
+	"github.com/quorumcontrol/jasons-game/pb/jasonsgame"
+)
+
+func TestClient(t *testing.T) {
+	net := network.NewLocalNetwork()
+
+	handler, err := mailbox.NewMailboxHandler(net)
+	require.Nil(t, err)
+	client := NewClientWithHandler(net, handler)
+
+	player, err := net.CreateChainTree()
+	require.Nil(t, err)
+
+	_, err = client.EncryptionPubKey(player.MustId())
+	require.Equal(t, ErrNoRecipientKey, err)
+
+	_, err = net.UpdateChainTree(player, strings.Join(EncryptionPubKeyPath[2:], "/"), crypto.FromECDSAPub(net.PublicKey()))
+	require.Nil(t, err)
+
+	err = client.Send(player.MustId(), &jasonsgame.Mail{
+		From:   player.MustId(),
+		Body:   "meet me at the fountain",
+		SentAt: time.Now().Unix(),
+	})
+	require.Nil(t, err)
+
+	received := make(chan *jasonsgame.MailboxResponse, 2)
+	_, err = net.Community().Subscribe(net.Community().TopicFor(mailbox.ReplyTopic(player.MustId())), func(ctx context.Context, _ *messages.Envelope, msg proto.Message) {
+		received <- msg.(*jasonsgame.MailboxResponse)
+	})
+	require.Nil(t, err)
+
+	require.Nil(t, client.Request(player.MustId()))
+
+	var response *jasonsgame.MailboxResponse
+	select {
+	case response = <-received:
+	case <-time.After(1 * time.Second):
+		require.Fail(t, "timeout waiting for mail")
+	}
+	require.Len(t, response.Mail, 1)
+
+	opened, err := client.Open(response.Mail[0])
+	require.Nil(t, err)
+	require.Equal(t, "meet me at the fountain", opened.Body)
+	require.Equal(t, player.MustId(), opened.From)
+
+	require.Nil(t, client.Delete(player.MustId(), response.Mail[0].Id))
+	require.Nil(t, client.Request(player.MustId()))
+
+	select {
+	case response = <-received:
+	case <-time.After(1 * time.Second):
+		require.Fail(t, "timeout waiting for mail")
+	}
+	require.Len(t, response.Mail, 0)
+}
+
+func TestSign(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.Nil(t, err)
+
+	msg := &jasonsgame.Mail{From: "did:tupelo:jason", Body: "hi"}
+	require.Nil(t, Sign(msg, key))
+
+	signer, err := Signer(msg)
+	require.Nil(t, err)
+	require.Equal(t, crypto.PubkeyToAddress(key.PublicKey).String(), signer)
+}
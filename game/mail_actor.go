@@ -0,0 +1,202 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/AsynkronIT/protoactor-go/actor"
+	"github.com/AsynkronIT/protoactor-go/plugin"
+	"github.com/ipfs/go-datastore"
+	"github.com/quorumcontrol/tupelo-go-sdk/gossip3/middleware"
+
+	"github.com/quorumcontrol/jasons-game/game/mail"
+	"github.com/quorumcontrol/jasons-game/handlers/mailbox"
+	"github.com/quorumcontrol/jasons-game/network"
+	"github.com/quorumcontrol/jasons-game/pb/jasonsgame"
+)
+
+// GetInbox is answered with *Inbox once the mailbox responds
+type GetInbox struct{}
+
+type Inbox struct {
+	// Disabled is set when there's no mailbox service to use
+	Disabled bool
+	Letters  []*Letter
+}
+
+// Letter is mail that has been decrypted and verified
+type Letter struct {
+	Id   string
+	Mail *jasonsgame.Mail
+	// Name is the name on the sender's tree
+	Name string
+	Read bool
+}
+
+type SendMailRequest struct {
+	To        string
+	Body      string
+	InReplyTo string
+}
+
+type DeleteMailRequest struct {
+	Id string
+}
+
+type MailResponse struct {
+	Error error
+}
+
+var errNoMailbox = fmt.Errorf("mail isn't available right now")
+
+func mailReadKey(id string) datastore.Key {
+	return datastore.NewKey("mail-read").ChildString(id)
+}
+
+// MailActor fetches the player's mail from the mailbox service, and sends
+// mail to others. When it starts, it lets its parent know about unread mail
+// with a userEvent.
+type MailActor struct {
+	middleware.LogAwareHolder
+	network      network.Network
+	playerDid    string
+	client       *mail.Client
+	ds           datastore.Batching
+	pending      []*actor.PID
+	notifyUnread bool
+}
+
+type MailActorConfig struct {
+	Network   network.Network
+	PlayerDid string
+	// Client is nil when there is no mailbox service
+	Client    *mail.Client
+	DataStore datastore.Batching
+}
+
+func NewMailActorProps(cfg *MailActorConfig) *actor.Props {
+	return actor.PropsFromProducer(func() actor.Actor {
+		return &MailActor{
+			network:   cfg.Network,
+			playerDid: cfg.PlayerDid,
+			client:    cfg.Client,
+			ds:        cfg.DataStore,
+		}
+	}).WithReceiverMiddleware(
+		middleware.LoggingMiddleware,
+		plugin.Use(&middleware.LogPlugin{}),
+	)
+}
+
+func (m *MailActor) Receive(actorCtx actor.Context) {
+	switch msg := actorCtx.Message().(type) {
+	case *actor.Started:
+		if m.client == nil {
+			return
+		}
+		actorCtx.Spawn(m.network.Community().NewSubscriberProps(m.network.Community().TopicFor(mailbox.ReplyTopic(m.playerDid))))
+		m.notifyUnread = true
+		m.requestMail()
+	case *GetInbox:
+		if m.client == nil {
+			actorCtx.Respond(&Inbox{Disabled: true})
+			return
+		}
+		// answered when the mailbox responds
+		m.pending = append(m.pending, actorCtx.Sender())
+		m.requestMail()
+	case *jasonsgame.MailboxResponse:
+		m.handleMailboxResponse(actorCtx, msg)
+	case *SendMailRequest:
+		actorCtx.Respond(&MailResponse{Error: m.send(msg)})
+	case *DeleteMailRequest:
+		if m.client == nil {
+			actorCtx.Respond(&MailResponse{Error: errNoMailbox})
+			return
+		}
+		actorCtx.Respond(&MailResponse{Error: m.client.Delete(m.playerDid, msg.Id)})
+	}
+}
+
+func (m *MailActor) requestMail() {
+	err := m.client.Request(m.playerDid)
+	if err != nil {
+		m.Log.Errorw("error requesting mail", "err", err)
+	}
+}
+
+func (m *MailActor) send(msg *SendMailRequest) error {
+	if m.client == nil {
+		return errNoMailbox
+	}
+
+	return m.client.Send(msg.To, &jasonsgame.Mail{
+		From:      m.playerDid,
+		Body:      msg.Body,
+		InReplyTo: msg.InReplyTo,
+		SentAt:    time.Now().Unix(),
+	})
+}
+
+func (m *MailActor) handleMailboxResponse(actorCtx actor.Context, msg *jasonsgame.MailboxResponse) {
+	if msg.To != m.playerDid {
+		return
+	}
+
+	inbox := &Inbox{}
+	names := make(map[string]string)
+	for _, encrypted := range msg.Mail {
+		opened, err := m.client.Open(encrypted)
+		if err != nil {
+			m.Log.Warnw("ignoring mail that couldn't be opened", "id", encrypted.Id, "err", err)
+			continue
+		}
+
+		read, err := m.ds.Has(mailReadKey(encrypted.Id))
+		if err != nil {
+			m.Log.Warnw("error checking if mail was read", "id", encrypted.Id, "err", err)
+		}
+		name, ok := names[opened.From]
+		if !ok {
+			name = playerNameFor(m.network, opened.From)
+			names[opened.From] = name
+		}
+		inbox.Letters = append(inbox.Letters, &Letter{Id: encrypted.Id, Mail: opened, Name: name, Read: read})
+	}
+	// newest first
+	sort.Slice(inbox.Letters, func(i, j int) bool {
+		if inbox.Letters[i].Mail.SentAt != inbox.Letters[j].Mail.SentAt {
+			return inbox.Letters[i].Mail.SentAt > inbox.Letters[j].Mail.SentAt
+		}
+		return inbox.Letters[i].Id < inbox.Letters[j].Id
+	})
+
+	for _, pid := range m.pending {
+		actorCtx.Send(pid, inbox)
+	}
+	m.pending = nil
+
+	if !m.notifyUnread {
+		return
+	}
+	m.notifyUnread = false
+
+	unread := 0
+	for _, letter := range inbox.Letters {
+		if !letter.Read {
+			unread++
+		}
+	}
+	if unread == 0 {
+		return
+	}
+
+	message := fmt.Sprintf("you have %d unread letters, type `inbox` to see them", unread)
+	if unread == 1 {
+		message = "you have an unread letter, type `inbox` to see it"
+	}
+	if parent := actorCtx.Parent(); parent != nil {
+		actorCtx.Send(parent, &userEvent{message: message})
+	}
+}
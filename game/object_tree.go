@@ -136,6 +136,14 @@ func (o *ObjectTree) InteractionsList() ([]Interaction, error) {
 	return o.interactionsListFromTree(o)
 }
 
+func (o *ObjectTree) RemoveInteraction(command string) error {
+	return o.removeInteractionFromTree(o, command)
+}
+
+func (o *ObjectTree) ReplaceInteraction(command string, i Interaction) error {
+	return o.replaceInteractionInTree(o, command, i)
+}
+
 func (o *ObjectTree) AddDefaultInscriptionInteractions() error {
 	name, err := o.GetName()
 	if err != nil {
@@ -203,6 +211,20 @@ func (o *ObjectTree) updatePath(path []string, val interface{}) error {
 	return nil
 }
 
+func (o *ObjectTree) updatePaths(updates []pathUpdate) error {
+	txs, err := setDataTransactions(updates)
+	if err != nil {
+		return err
+	}
+
+	newTree, err := o.network.PlayTransactions(o.tree, txs)
+	if err != nil {
+		return err
+	}
+	o.tree = newTree
+	return nil
+}
+
 func (o *ObjectTree) GetPath(path []string) (interface{}, error) {
 	return o.getPath(path)
 }
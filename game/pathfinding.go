@@ -0,0 +1,149 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/AsynkronIT/protoactor-go/actor"
+	"github.com/pkg/errors"
+)
+
+// matchPlaces finds locations the player could mean by place, either by
+// their description or by the commands of exits leading to them
+func (m *worldMap) matchPlaces(place string, homeDid string) []string {
+	place = strings.ToLower(strings.TrimSpace(place))
+	if place == "home" {
+		return []string{homeDid}
+	}
+
+	matched := make(map[string]bool)
+	for did, loc := range m.locations {
+		if loc.description != "" && strings.Contains(strings.ToLower(loc.description), place) {
+			matched[did] = true
+		}
+	}
+	for _, exit := range m.exits {
+		if strings.Contains(strings.ToLower(exit.command), place) {
+			matched[exit.to] = true
+		}
+	}
+
+	dids := make([]string, 0, len(matched))
+	for did := range matched {
+		dids = append(dids, did)
+	}
+	sort.Strings(dids)
+	return dids
+}
+
+// shortestRoute returns the exits to take from one location to another,
+// nil if there is no known route
+func (m *worldMap) shortestRoute(from string, to string) []*worldMapExit {
+	if from == to {
+		return []*worldMapExit{}
+	}
+
+	cameBy := map[string]*worldMapExit{from: nil}
+	queue := []string{from}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, exit := range m.exitsFrom(current) {
+			if _, seen := cameBy[exit.to]; seen {
+				continue
+			}
+			cameBy[exit.to] = exit
+
+			if exit.to == to {
+				var route []*worldMapExit
+				for step := exit; step != nil; step = cameBy[step.from] {
+					route = append([]*worldMapExit{step}, route...)
+				}
+				return route
+			}
+			queue = append(queue, exit.to)
+		}
+	}
+	return nil
+}
+
+func (g *Game) handleGoTo(actorCtx actor.Context, place string) error {
+	place = strings.TrimSpace(place)
+	if place == "" {
+		return fmt.Errorf("where to? e.g. `go to the forest`")
+	}
+
+	m, err := g.buildWorldMap()
+	if err != nil {
+		return err
+	}
+
+	var route []*worldMapExit
+	for _, did := range m.matchPlaces(place, g.playerTree.HomeLocation.MustId()) {
+		if did == g.locationDid {
+			g.sendUserMessage(actorCtx, "you are already there")
+			return nil
+		}
+		candidate := m.shortestRoute(g.locationDid, did)
+		if candidate != nil && (route == nil || len(candidate) < len(route)) {
+			route = candidate
+		}
+	}
+
+	if route == nil {
+		g.sendUserMessage(actorCtx, fmt.Sprintf("you don't know the way to %s", place))
+		return nil
+	}
+
+	return g.walkRoute(actorCtx, route)
+}
+
+// walkRoute takes each exit in turn, checking it is still there first since
+// locations may have changed since the player last saw them
+func (g *Game) walkRoute(actorCtx actor.Context, route []*worldMapExit) error {
+	homeDid := g.playerTree.HomeLocation.MustId()
+
+	for _, step := range route {
+		tree, err := g.network.GetTree(g.locationDid)
+		if err != nil {
+			return errors.Wrap(err, "error fetching location")
+		}
+		if tree == nil {
+			return fmt.Errorf("could not find location %s", g.locationDid)
+		}
+
+		exits, err := locationExits(g.network, homeDid, NewLocationTree(g.network, tree))
+		if err != nil {
+			return err
+		}
+
+		stillThere := false
+		for _, exit := range exits {
+			if exit.command == step.command && exit.to == step.to {
+				stillThere = true
+				break
+			}
+		}
+		if !stillThere {
+			g.sendUserMessage(actorCtx, fmt.Sprintf("the way to %s is blocked, stopping here", step.command))
+			return nil
+		}
+
+		destination, err := g.network.GetTree(step.to)
+		if err != nil || destination == nil {
+			g.sendUserMessage(actorCtx, fmt.Sprintf("%s leads nowhere anymore, stopping here", step.command))
+			return nil
+		}
+
+		g.sendUserMessage(actorCtx, fmt.Sprintf("> %s", step.command))
+		g.handleChangeLocation(actorCtx, step.to)
+		if g.locationDid != step.to {
+			// kept out, handleChangeLocation has said why
+			return nil
+		}
+	}
+
+	return nil
+}
@@ -3,6 +3,7 @@ package game
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"strings"
 
 	"github.com/ipfs/go-cid"
@@ -15,6 +16,25 @@ import (
 
 var playerTreePath = "jasons-game/player"
 
+const interactionUsagePath = "interaction-usage"
+
+const dialoguesPath = "dialogues"
+
+const cipherProgressPath = "cipher-progress"
+
+const cipherAttemptsPath = "cipher-attempts"
+
+const randomUsesPath = "random-uses"
+
+const questsPath = "quests"
+
+const journalPath = "journal"
+
+const bookmarksPath = "bookmarks"
+
+// encryptionPubKeyPath must match mail.EncryptionPubKeyPath
+const encryptionPubKeyPath = "encryption-pubkey"
+
 type PlayerTree struct {
 	tree         *consensus.SignedChainTree
 	HomeLocation *LocationTree
@@ -92,6 +112,314 @@ func (pt *PlayerTree) SetPlayer(p *jasonsgame.Player) error {
 	return nil
 }
 
+// InteractionUsage returns how often the player has used the interaction
+// with command attached to did, nil if never
+func (pt *PlayerTree) InteractionUsage(did string, command string) (*InteractionUsage, error) {
+	uncastUsage, err := pt.getPath(interactionUsagePathFor(did, command))
+	if err != nil {
+		return nil, err
+	}
+	if uncastUsage == nil {
+		return nil, nil
+	}
+
+	usage := new(InteractionUsage)
+	err = typecaster.ToType(uncastUsage, usage)
+	if err != nil {
+		return nil, errors.Wrap(err, "error casting interaction usage")
+	}
+	return usage, nil
+}
+
+func (pt *PlayerTree) SetInteractionUsage(did string, command string, usage *InteractionUsage) error {
+	return pt.updatePath(interactionUsagePathFor(did, command), usage)
+}
+
+func interactionUsagePathFor(did string, command string) []string {
+	return []string{interactionUsagePath, did, url.PathEscape(command)}
+}
+
+func (pt *PlayerTree) CipherProgress(did string, command string) (*CipherProgress, error) {
+	uncastProgress, err := pt.getPath([]string{cipherProgressPath, did, url.PathEscape(command)})
+	if err != nil || uncastProgress == nil {
+		return nil, err
+	}
+
+	progress := new(CipherProgress)
+	err = typecaster.ToType(uncastProgress, progress)
+	if err != nil {
+		return nil, errors.Wrap(err, "error casting cipher progress")
+	}
+	return progress, nil
+}
+
+func (pt *PlayerTree) SetCipherProgress(did string, command string, progress *CipherProgress) error {
+	return pt.updatePath([]string{cipherProgressPath, did, url.PathEscape(command)}, progress)
+}
+
+// CipherAttempts returns the player's recent guesses at the cipher with
+// command attached to did, nil if there haven't been any
+func (pt *PlayerTree) CipherAttempts(did string, command string) (*InteractionUsage, error) {
+	uncastAttempts, err := pt.getPath([]string{cipherAttemptsPath, did, url.PathEscape(command)})
+	if err != nil || uncastAttempts == nil {
+		return nil, err
+	}
+
+	attempts := new(InteractionUsage)
+	err = typecaster.ToType(uncastAttempts, attempts)
+	if err != nil {
+		return nil, errors.Wrap(err, "error casting cipher attempts")
+	}
+	return attempts, nil
+}
+
+// SetCipherAttempts clears the attempts when attempts is nil
+func (pt *PlayerTree) SetCipherAttempts(did string, command string, attempts *InteractionUsage) error {
+	var val interface{}
+	if attempts != nil {
+		val = attempts
+	}
+	return pt.updatePath([]string{cipherAttemptsPath, did, url.PathEscape(command)}, val)
+}
+
+// RandomUses is how many times the player has rolled the random interaction
+// with command attached to did
+func (pt *PlayerTree) RandomUses(did string, command string) (uint64, error) {
+	uncastUses, err := pt.getPath([]string{randomUsesPath, did, url.PathEscape(command)})
+	if err != nil || uncastUses == nil {
+		return 0, err
+	}
+
+	switch uses := uncastUses.(type) {
+	case uint64:
+		return uses, nil
+	case int64:
+		return uint64(uses), nil
+	default:
+		return 0, fmt.Errorf("error casting random uses; type is %T", uncastUses)
+	}
+}
+
+func (pt *PlayerTree) SetRandomUses(did string, command string, uses uint64) error {
+	return pt.updatePath([]string{randomUsesPath, did, url.PathEscape(command)}, uses)
+}
+
+// QuestProgress returns nil if the player hasn't started, or has abandoned,
+// the quest with questDid
+func (pt *PlayerTree) QuestProgress(questDid string) (*QuestProgress, error) {
+	uncastProgress, err := pt.getPath([]string{questsPath, questDid})
+	if err != nil || uncastProgress == nil {
+		return nil, err
+	}
+
+	progress := new(QuestProgress)
+	err = typecaster.ToType(uncastProgress, progress)
+	if err != nil {
+		return nil, errors.Wrap(err, "error casting quest progress")
+	}
+	return progress, nil
+}
+
+// QuestProgresses returns the progress of every quest the player is on or
+// has completed, keyed by quest did
+func (pt *PlayerTree) QuestProgresses() (map[string]*QuestProgress, error) {
+	uncastQuests, err := pt.getPath([]string{questsPath})
+	if err != nil || uncastQuests == nil {
+		return nil, err
+	}
+
+	questsMap, ok := uncastQuests.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("error casting quests; type is %T", uncastQuests)
+	}
+
+	progresses := make(map[string]*QuestProgress, len(questsMap))
+	for questDid, uncastProgress := range questsMap {
+		// abandoned quests are left as nil
+		if uncastProgress == nil {
+			continue
+		}
+
+		progress := new(QuestProgress)
+		err = typecaster.ToType(uncastProgress, progress)
+		if err != nil {
+			return nil, errors.Wrap(err, "error casting quest progress")
+		}
+		progresses[questDid] = progress
+	}
+	return progresses, nil
+}
+
+func (pt *PlayerTree) SetQuestProgress(questDid string, progress *QuestProgress) error {
+	return pt.updatePath([]string{questsPath, questDid}, progress)
+}
+
+// JournalEntry returns nil if the player has never visited the location
+func (pt *PlayerTree) JournalEntry(locationDid string) (*JournalEntry, error) {
+	uncastEntry, err := pt.getPath([]string{journalPath, locationDid})
+	if err != nil || uncastEntry == nil {
+		return nil, err
+	}
+
+	entry := new(JournalEntry)
+	err = typecaster.ToType(uncastEntry, entry)
+	if err != nil {
+		return nil, errors.Wrap(err, "error casting journal entry")
+	}
+	return entry, nil
+}
+
+// Journal returns an entry for every location the player has visited,
+// keyed by location did
+func (pt *PlayerTree) Journal() (map[string]*JournalEntry, error) {
+	uncastJournal, err := pt.getPath([]string{journalPath})
+	if err != nil || uncastJournal == nil {
+		return nil, err
+	}
+
+	journalMap, ok := uncastJournal.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("error casting journal; type is %T", uncastJournal)
+	}
+
+	journal := make(map[string]*JournalEntry, len(journalMap))
+	for locationDid, uncastEntry := range journalMap {
+		if uncastEntry == nil {
+			continue
+		}
+
+		entry := new(JournalEntry)
+		err = typecaster.ToType(uncastEntry, entry)
+		if err != nil {
+			return nil, errors.Wrap(err, "error casting journal entry")
+		}
+		journal[locationDid] = entry
+	}
+	return journal, nil
+}
+
+func (pt *PlayerTree) SetJournalEntry(locationDid string, entry *JournalEntry) error {
+	return pt.updatePath([]string{journalPath, locationDid}, entry)
+}
+
+// Bookmark returns nil if the player has no bookmark with name
+func (pt *PlayerTree) Bookmark(name string) (*Bookmark, error) {
+	uncastBookmark, err := pt.getPath([]string{bookmarksPath, url.PathEscape(name)})
+	if err != nil || uncastBookmark == nil {
+		return nil, err
+	}
+
+	bookmark := new(Bookmark)
+	err = typecaster.ToType(uncastBookmark, bookmark)
+	if err != nil {
+		return nil, errors.Wrap(err, "error casting bookmark")
+	}
+	return bookmark, nil
+}
+
+// Bookmarks returns every bookmark the player has saved, keyed by name
+func (pt *PlayerTree) Bookmarks() (map[string]*Bookmark, error) {
+	uncastBookmarks, err := pt.getPath([]string{bookmarksPath})
+	if err != nil || uncastBookmarks == nil {
+		return nil, err
+	}
+
+	bookmarksMap, ok := uncastBookmarks.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("error casting bookmarks; type is %T", uncastBookmarks)
+	}
+
+	bookmarks := make(map[string]*Bookmark, len(bookmarksMap))
+	for escapedName, uncastBookmark := range bookmarksMap {
+		// forgotten bookmarks are left as nil
+		if uncastBookmark == nil {
+			continue
+		}
+
+		name, err := url.PathUnescape(escapedName)
+		if err != nil {
+			return nil, errors.Wrap(err, "error decoding bookmark name")
+		}
+
+		bookmark := new(Bookmark)
+		err = typecaster.ToType(uncastBookmark, bookmark)
+		if err != nil {
+			return nil, errors.Wrap(err, "error casting bookmark")
+		}
+		bookmarks[name] = bookmark
+	}
+	return bookmarks, nil
+}
+
+func (pt *PlayerTree) SetBookmark(name string, bookmark *Bookmark) error {
+	return pt.updatePath([]string{bookmarksPath, url.PathEscape(name)}, bookmark)
+}
+
+// EncryptionPubKey is the key other players encrypt mail to this one with
+func (pt *PlayerTree) EncryptionPubKey() ([]byte, error) {
+	val, err := pt.getPath([]string{encryptionPubKeyPath})
+	if err != nil || val == nil {
+		return nil, err
+	}
+
+	pubKey, ok := val.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("error casting encryption pubkey; type is %T", val)
+	}
+	return pubKey, nil
+}
+
+func (pt *PlayerTree) SetEncryptionPubKey(pubKey []byte) error {
+	return pt.updatePath([]string{encryptionPubKeyPath}, pubKey)
+}
+
+// DialogueNode returns the id of the node the player is at in the dialogue
+// with command attached to did, empty if they haven't started it
+func (pt *PlayerTree) DialogueNode(did string, command string) (string, error) {
+	uncastNode, err := pt.getPath([]string{dialoguesPath, did, url.PathEscape(command)})
+	if err != nil || uncastNode == nil {
+		return "", err
+	}
+
+	node, ok := uncastNode.(string)
+	if !ok {
+		return "", fmt.Errorf("error casting dialogue node; type is %T", uncastNode)
+	}
+	return node, nil
+}
+
+func (pt *PlayerTree) SetDialogueNode(did string, command string, node string) error {
+	return pt.updatePath([]string{dialoguesPath, did, url.PathEscape(command)}, node)
+}
+
+func (pt *PlayerTree) getPath(path []string) (interface{}, error) {
+	ctx := context.TODO()
+	resp, _, err := pt.tree.ChainTree.Dag.Resolve(ctx, append([]string{"tree", "data", "jasons-game"}, path...))
+	if err != nil {
+		return nil, fmt.Errorf("error resolving %v on player: %v", strings.Join(path, "/"), err)
+	}
+	return resp, nil
+}
+
+func (pt *PlayerTree) updatePath(path []string, val interface{}) error {
+	// the player tree is also updated outside the game actor, e.g. for
+	// per-player location inventories, so start from the latest tree
+	tree, err := pt.network.GetTree(pt.did)
+	if err != nil {
+		return errors.Wrap(err, "error fetching player tree")
+	}
+	if tree == nil {
+		tree = pt.tree
+	}
+
+	newTree, err := pt.network.UpdateChainTree(tree, strings.Join(append([]string{"jasons-game"}, path...), "/"), val)
+	if err != nil {
+		return errors.Wrap(err, "error updating player tree")
+	}
+	pt.setTree(newTree)
+	return nil
+}
+
 func (pt *PlayerTree) setTree(tree *consensus.SignedChainTree) {
 	pt.tree = tree
 	pt.did = tree.MustId()
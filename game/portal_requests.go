@@ -0,0 +1,511 @@
+package game
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/AsynkronIT/protoactor-go/actor"
+	"github.com/AsynkronIT/protoactor-go/plugin"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gogo/protobuf/proto"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/pkg/errors"
+	"github.com/quorumcontrol/tupelo-go-sdk/consensus"
+	"github.com/quorumcontrol/tupelo-go-sdk/gossip3/middleware"
+
+	"github.com/quorumcontrol/jasons-game/network"
+	"github.com/quorumcontrol/jasons-game/pb/jasonsgame"
+	"github.com/quorumcontrol/jasons-game/utils/stringslice"
+)
+
+const portalTopicSuffix = "/portals"
+
+var portalRequestsKey = datastore.NewKey("portal-requests")
+
+// portalRequestTopic is where requests for portals on land owned by the key
+// with addr are sent, since locations only know the keys of their owners
+func portalRequestTopic(net network.Network, addr string) []byte {
+	return net.Community().TopicFor(addr + portalTopicSuffix)
+}
+
+// portalResponseTopic is where the player with did hears back about their
+// portal requests
+func portalResponseTopic(net network.Network, did string) []byte {
+	return net.Community().TopicFor(did + portalTopicSuffix)
+}
+
+func portalRequestKey(msg *jasonsgame.OpenPortalMessage) datastore.Key {
+	return portalRequestsKey.ChildString(msg.To + "-" + msg.From)
+}
+
+// pendingPortalRequests are the requests for portals on the player's land
+// they haven't answered yet, kept across restarts
+func pendingPortalRequests(ds datastore.Batching) ([]*jasonsgame.OpenPortalMessage, error) {
+	results, err := ds.Query(query.Query{Prefix: portalRequestsKey.String()})
+	if err != nil {
+		return nil, errors.Wrap(err, "error querying portal requests")
+	}
+	entries, err := results.Rest()
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching portal requests")
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+
+	requests := make([]*jasonsgame.OpenPortalMessage, 0, len(entries))
+	for _, entry := range entries {
+		request := &jasonsgame.OpenPortalMessage{}
+		err = proto.Unmarshal(entry.Value, request)
+		if err != nil {
+			return nil, errors.Wrap(err, "error unmarshaling portal request")
+		}
+		requests = append(requests, request)
+	}
+	return requests, nil
+}
+
+// PortalRequestActor listens for requests to build portals on land owned by
+// this player, and for answers to their own requests. Both are sent to its
+// parent as userEvents.
+type PortalRequestActor struct {
+	middleware.LogAwareHolder
+	network   network.Network
+	playerDid string
+	ds        datastore.Batching
+}
+
+type PortalRequestActorConfig struct {
+	Network   network.Network
+	PlayerDid string
+	DataStore datastore.Batching
+}
+
+func NewPortalRequestActorProps(cfg *PortalRequestActorConfig) *actor.Props {
+	return actor.PropsFromProducer(func() actor.Actor {
+		return &PortalRequestActor{
+			network:   cfg.Network,
+			playerDid: cfg.PlayerDid,
+			ds:        cfg.DataStore,
+		}
+	}).WithReceiverMiddleware(
+		middleware.LoggingMiddleware,
+		plugin.Use(&middleware.LogPlugin{}),
+	)
+}
+
+func (p *PortalRequestActor) keyAddr() string {
+	return crypto.PubkeyToAddress(*p.network.PublicKey()).String()
+}
+
+func (p *PortalRequestActor) Receive(actorCtx actor.Context) {
+	switch msg := actorCtx.Message().(type) {
+	case *actor.Started:
+		actorCtx.Spawn(p.network.Community().NewSubscriberProps(portalRequestTopic(p.network, p.keyAddr())))
+		actorCtx.Spawn(p.network.Community().NewSubscriberProps(portalResponseTopic(p.network, p.playerDid)))
+		p.notifyPending(actorCtx)
+	case *jasonsgame.OpenPortalMessage:
+		err := p.handleOpenPortalMessage(actorCtx, msg)
+		if err != nil {
+			p.Log.Warnw("ignoring portal request", "from", msg.From, "location", msg.To, "err", err)
+		}
+	case *jasonsgame.OpenPortalResponseMessage:
+		p.handleOpenPortalResponseMessage(actorCtx, msg)
+	}
+}
+
+func (p *PortalRequestActor) handleOpenPortalMessage(actorCtx actor.Context, msg *jasonsgame.OpenPortalMessage) error {
+	err := verifyPortalMessage(p.network, msg, msg.From)
+	if err != nil {
+		return err
+	}
+
+	location, err := fetchLocation(p.network, msg.To)
+	if err != nil {
+		return err
+	}
+	isOwner, err := location.IsOwnedBy([]string{p.keyAddr()})
+	if err != nil || !isOwner {
+		return fmt.Errorf("location isn't owned by this player")
+	}
+
+	// portals can only lead to the requester's own land
+	err = verifyLandOwner(p.network, msg.ToLandId, msg.From)
+	if err != nil {
+		return err
+	}
+
+	bits, err := proto.Marshal(msg)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling portal request")
+	}
+	err = p.ds.Put(portalRequestKey(msg), bits)
+	if err != nil {
+		return errors.Wrap(err, "error saving portal request")
+	}
+
+	description, _ := location.GetDescription()
+	p.notify(actorCtx, fmt.Sprintf("%s would like to build a portal at %s, type `portal requests` to answer",
+		playerNameFor(p.network, msg.From), describeForPlayer(description, msg.To)))
+	return nil
+}
+
+func (p *PortalRequestActor) handleOpenPortalResponseMessage(actorCtx actor.Context, msg *jasonsgame.OpenPortalResponseMessage) {
+	if msg.To != p.playerDid {
+		return
+	}
+	err := verifyPortalMessage(p.network, msg, msg.From)
+	if err != nil {
+		p.Log.Warnw("ignoring portal response", "from", msg.From, "err", err)
+		return
+	}
+
+	var description string
+	if location, err := fetchLocation(p.network, msg.LandId); err == nil {
+		description, _ = location.GetDescription()
+	}
+
+	if msg.Accepted {
+		p.notify(actorCtx, fmt.Sprintf("your portal request at %s was accepted, the portal is open", describeForPlayer(description, msg.LandId)))
+		return
+	}
+	p.notify(actorCtx, fmt.Sprintf("your portal request at %s was declined", describeForPlayer(description, msg.LandId)))
+}
+
+// notifyPending reminds the player of requests that came in before they
+// last left
+func (p *PortalRequestActor) notifyPending(actorCtx actor.Context) {
+	requests, err := pendingPortalRequests(p.ds)
+	if err != nil {
+		p.Log.Warnw("error fetching portal requests", "err", err)
+		return
+	}
+
+	switch len(requests) {
+	case 0:
+		return
+	case 1:
+		p.notify(actorCtx, "someone would like to build a portal on your land, type `portal requests` to answer")
+	default:
+		p.notify(actorCtx, fmt.Sprintf("%d portal requests are waiting for you, type `portal requests` to answer", len(requests)))
+	}
+}
+
+func (p *PortalRequestActor) notify(actorCtx actor.Context, message string) {
+	if parent := actorCtx.Parent(); parent != nil {
+		actorCtx.Send(parent, &userEvent{message: message})
+	}
+}
+
+func fetchLocation(net network.Network, did string) (*LocationTree, error) {
+	tree, err := net.GetTree(did)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("error fetching location %s", did))
+	}
+	if tree == nil {
+		return nil, fmt.Errorf("could not find location %s", did)
+	}
+	return NewLocationTree(net, tree), nil
+}
+
+// verifyLandOwner checks the location with did is owned by the player with playerDid
+func verifyLandOwner(net network.Network, did string, playerDid string) error {
+	playerTree, err := net.GetTree(playerDid)
+	if err != nil {
+		return errors.Wrap(err, "error fetching player")
+	}
+	if playerTree == nil {
+		return fmt.Errorf("could not find player %s", playerDid)
+	}
+	auths, err := playerTree.Authentications()
+	if err != nil {
+		return errors.Wrap(err, "error fetching player auths")
+	}
+
+	land, err := fetchLocation(net, did)
+	if err != nil {
+		return err
+	}
+	isOwner, err := land.IsOwnedBy(auths)
+	if err != nil || !isOwner {
+		return fmt.Errorf("%s isn't owned by %s", did, playerDid)
+	}
+	return nil
+}
+
+// portalSigningHash is the hash of a portal request or response without its
+// signature
+func portalSigningHash(msg proto.Message) ([]byte, error) {
+	unsigned := proto.Clone(msg)
+	switch m := unsigned.(type) {
+	case *jasonsgame.OpenPortalMessage:
+		m.Signature = nil
+	case *jasonsgame.OpenPortalResponseMessage:
+		m.Signature = nil
+	default:
+		return nil, fmt.Errorf("can't sign messages of type %T", msg)
+	}
+
+	bits, err := proto.Marshal(unsigned)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling portal message")
+	}
+	return crypto.Keccak256(bits), nil
+}
+
+func signPortalMessage(msg proto.Message, key *ecdsa.PrivateKey) error {
+	hash, err := portalSigningHash(msg)
+	if err != nil {
+		return err
+	}
+
+	signature, err := crypto.Sign(hash, key)
+	if err != nil {
+		return errors.Wrap(err, "error signing portal message")
+	}
+
+	switch m := msg.(type) {
+	case *jasonsgame.OpenPortalMessage:
+		m.Signature = signature
+	case *jasonsgame.OpenPortalResponseMessage:
+		m.Signature = signature
+	}
+	return nil
+}
+
+// verifyPortalMessage checks the message was signed by one of the owners of
+// the player with did
+func verifyPortalMessage(net network.Network, msg proto.Message, did string) error {
+	var signature []byte
+	switch m := msg.(type) {
+	case *jasonsgame.OpenPortalMessage:
+		signature = m.Signature
+	case *jasonsgame.OpenPortalResponseMessage:
+		signature = m.Signature
+	}
+
+	hash, err := portalSigningHash(msg)
+	if err != nil {
+		return err
+	}
+	pubKey, err := crypto.SigToPub(hash, signature)
+	if err != nil {
+		return errors.Wrap(err, "error recovering signer")
+	}
+
+	tree, err := net.GetTree(did)
+	if err != nil {
+		return errors.Wrap(err, "error fetching player")
+	}
+	if tree == nil {
+		return fmt.Errorf("could not find player %s", did)
+	}
+	auths, err := tree.Authentications()
+	if err != nil {
+		return errors.Wrap(err, "error fetching player auths")
+	}
+	if !stringslice.Include(auths, crypto.PubkeyToAddress(*pubKey).String()) {
+		return fmt.Errorf("portal message isn't signed by %s", did)
+	}
+	return nil
+}
+
+func playerNameFor(net network.Network, did string) string {
+	tree, err := net.GetTree(did)
+	if err != nil || tree == nil {
+		return did
+	}
+	return playerNameOn(net, tree)
+}
+
+// playerNameOn is the name on a player tree, or its did if it has none
+func playerNameOn(net network.Network, tree *consensus.SignedChainTree) string {
+	player, err := NewPlayerTree(net, tree).Player()
+	if err != nil || player.Name == "" {
+		return tree.MustId()
+	}
+	return player.Name
+}
+
+func describeForPlayer(description string, did string) string {
+	if description == "" {
+		return did
+	}
+	return fmt.Sprintf("%s (%s)", description, did)
+}
+
+// handleRequestPortal asks the owner of the current location for a portal
+// to land the player owns, their home if they don't say where
+func (g *Game) handleRequestPortal(actorCtx actor.Context, args string) error {
+	toDid := strings.TrimSpace(args)
+	if toDid == "" {
+		toDid = g.playerTree.HomeLocation.MustId()
+	}
+
+	location, err := g.currentLocationTree()
+	if err != nil {
+		return err
+	}
+
+	auths, err := g.playerTree.Authentications()
+	if err != nil {
+		return errors.Wrap(err, "error fetching player authentications")
+	}
+	if isOwner, _ := location.IsOwnedBy(auths); isOwner {
+		g.sendUserMessage(actorCtx, "this is your land, type `build portal to <did>` instead")
+		return nil
+	}
+
+	portal, err := location.GetPortal()
+	if err != nil {
+		return err
+	}
+	if portal != nil {
+		g.sendUserMessage(actorCtx, "there is already a portal here")
+		return nil
+	}
+
+	err = verifyLandOwner(g.network, toDid, g.playerTree.Did())
+	if err != nil {
+		log.Debugf("portal request to land not owned by player: %v", err)
+		g.sendUserMessage(actorCtx, "portals can only lead to land you own")
+		return nil
+	}
+
+	ownerAddrs, err := location.Tree().Authentications()
+	if err != nil {
+		return errors.Wrap(err, "error fetching location owners")
+	}
+
+	request := &jasonsgame.OpenPortalMessage{
+		From:     g.playerTree.Did(),
+		To:       g.locationDid,
+		ToLandId: toDid,
+	}
+	err = signPortalMessage(request, g.network.PrivateKey())
+	if err != nil {
+		return err
+	}
+	for _, addr := range ownerAddrs {
+		err = g.network.Community().Send(portalRequestTopic(g.network, addr), request)
+		if err != nil {
+			return errors.Wrap(err, "error sending portal request")
+		}
+	}
+
+	g.sendUserMessage(actorCtx, "your request for a portal has been sent to the owner of this land")
+	return nil
+}
+
+func (g *Game) handlePortalRequests(actorCtx actor.Context) error {
+	requests, err := pendingPortalRequests(g.ds)
+	if err != nil {
+		return err
+	}
+
+	if len(requests) == 0 {
+		g.sendUserMessage(actorCtx, "nobody has asked to build a portal on your land")
+		return nil
+	}
+
+	toSend := indentedList{"portal requests, type `accept portal request <number>` or `decline portal request <number>`:"}
+	for i, request := range requests {
+		var description string
+		if location, err := fetchLocation(g.network, request.To); err == nil {
+			description, _ = location.GetDescription()
+		}
+		toSend = append(toSend, fmt.Sprintf("%d. %s would like a portal at %s to %s",
+			i+1, playerNameFor(g.network, request.From), describeForPlayer(description, request.To), request.ToLandId))
+	}
+	g.sendUserMessage(actorCtx, toSend)
+	return nil
+}
+
+func (g *Game) portalRequestNumbered(actorCtx actor.Context, numberArg string) (*jasonsgame.OpenPortalMessage, error) {
+	requests, err := pendingPortalRequests(g.ds)
+	if err != nil {
+		return nil, err
+	}
+
+	numberArg = strings.TrimSpace(numberArg)
+	number, err := strconv.Atoi(numberArg)
+	if err != nil || number < 1 || number > len(requests) {
+		g.sendUserMessage(actorCtx, fmt.Sprintf("there's no portal request numbered %s, type `portal requests` to see them", numberArg))
+		return nil, nil
+	}
+	return requests[number-1], nil
+}
+
+func (g *Game) handleAnswerPortalRequest(actorCtx actor.Context, args string, accept bool) error {
+	request, err := g.portalRequestNumbered(actorCtx, args)
+	if err != nil || request == nil {
+		return err
+	}
+
+	if accept {
+		location, err := fetchLocation(g.network, request.To)
+		if err != nil {
+			return err
+		}
+
+		// things may have changed since the request was made
+		auths, err := g.playerTree.Authentications()
+		if err != nil {
+			return errors.Wrap(err, "error fetching player authentications")
+		}
+		if isOwner, _ := location.IsOwnedBy(auths); !isOwner {
+			err = g.ds.Delete(portalRequestKey(request))
+			if err != nil {
+				return errors.Wrap(err, "error removing portal request")
+			}
+			g.sendUserMessage(actorCtx, fmt.Sprintf("you no longer own %s, so the request was removed", request.To))
+			return nil
+		}
+
+		portal, err := location.GetPortal()
+		if err != nil {
+			return err
+		}
+		if portal != nil {
+			g.sendUserMessage(actorCtx, "there is already a portal there, remove it or decline the request")
+			return nil
+		}
+
+		err = location.BuildPortal(request.ToLandId)
+		if err != nil {
+			return errors.Wrap(err, "error building portal")
+		}
+	}
+
+	err = g.ds.Delete(portalRequestKey(request))
+	if err != nil {
+		return errors.Wrap(err, "error removing portal request")
+	}
+
+	response := &jasonsgame.OpenPortalResponseMessage{
+		From:     g.playerTree.Did(),
+		To:       request.From,
+		Accepted: accept,
+		Opener:   request.From,
+		LandId:   request.To,
+	}
+	err = signPortalMessage(response, g.network.PrivateKey())
+	if err != nil {
+		return err
+	}
+	err = g.network.Community().Send(portalResponseTopic(g.network, request.From), response)
+	if err != nil {
+		return errors.Wrap(err, "error answering portal request")
+	}
+
+	if accept {
+		g.sendUserMessage(actorCtx, fmt.Sprintf("built a portal to %s", request.ToLandId))
+	} else {
+		g.sendUserMessage(actorCtx, "declined the portal request")
+	}
+	return nil
+}
@@ -0,0 +1,323 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/AsynkronIT/protoactor-go/actor"
+	"github.com/AsynkronIT/protoactor-go/plugin"
+	"github.com/pkg/errors"
+	"github.com/quorumcontrol/tupelo-go-sdk/gossip3/middleware"
+
+	"github.com/quorumcontrol/jasons-game/handlers/chatlog"
+	"github.com/quorumcontrol/jasons-game/network"
+	"github.com/quorumcontrol/jasons-game/pb/jasonsgame"
+)
+
+const (
+	presenceHeartbeatInterval = 10 * time.Second
+	// players who miss this many heartbeats are assumed to have left
+	presenceExpiry = 3 * presenceHeartbeatInterval
+)
+
+// GetOccupants is answered by the PresenceActor with *Occupants
+type GetOccupants struct{}
+
+// Occupants are the other players in the location
+type Occupants struct {
+	// Names are the names on the other players' trees, by did
+	Names map[string]string
+}
+
+// List is the names of the other players, sorted
+func (o *Occupants) List() []string {
+	names := make([]string, 0, len(o.Names))
+	for _, name := range o.Names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// userEvent is sent to the game by its children, to show to the player
+type userEvent struct {
+	message string
+}
+
+type presenceTick struct{}
+
+type occupant struct {
+	name     string
+	lastSeen time.Time
+}
+
+// occupantList tracks the other players in a location by did
+type occupantList map[string]*occupant
+
+// seen returns true if the player wasn't already here
+func (o occupantList) seen(did string, name string, now time.Time) bool {
+	existing, ok := o[did]
+	if ok {
+		existing.name = name
+		existing.lastSeen = now
+		return false
+	}
+	o[did] = &occupant{name: name, lastSeen: now}
+	return true
+}
+
+func (o occupantList) remove(did string) (string, bool) {
+	existing, ok := o[did]
+	if !ok {
+		return "", false
+	}
+	delete(o, did)
+	return existing.name, true
+}
+
+// expire removes players not seen since before the expiry, returning their names
+func (o occupantList) expire(now time.Time, expiry time.Duration) []string {
+	var expired []string
+	for did, existing := range o {
+		if now.Sub(existing.lastSeen) > expiry {
+			expired = append(expired, existing.name)
+			delete(o, did)
+		}
+	}
+	sort.Strings(expired)
+	return expired
+}
+
+func (o occupantList) names() map[string]string {
+	names := make(map[string]string, len(o))
+	for did, existing := range o {
+		names[did] = existing.name
+	}
+	return names
+}
+
+// PresenceActor announces the player on a location's topic while they are
+// in it, and keeps track of who else is there. Arrivals and departures are
+// sent to its parent as userEvents. Like chat, presence is signed by an owner
+// of the player's tree, and shown by the name on it.
+type PresenceActor struct {
+	middleware.LogAwareHolder
+	network     network.Network
+	locationDid string
+	playerDid   string
+	name        string
+	occupants   occupantList
+	senders     map[string]*chatSender
+	// sentAt is when the latest presence from each player was sent, so
+	// replaying an older arrival can't undo their departure
+	sentAt map[string]int64
+	done   chan struct{}
+}
+
+type PresenceActorConfig struct {
+	Network     network.Network
+	LocationDid string
+	PlayerDid   string
+	Name        string
+}
+
+func NewPresenceActorProps(cfg *PresenceActorConfig) *actor.Props {
+	return actor.PropsFromProducer(func() actor.Actor {
+		return &PresenceActor{
+			network:     cfg.Network,
+			locationDid: cfg.LocationDid,
+			playerDid:   cfg.PlayerDid,
+			name:        cfg.Name,
+			occupants:   make(occupantList),
+			senders:     make(map[string]*chatSender),
+			sentAt:      make(map[string]int64),
+		}
+	}).WithReceiverMiddleware(
+		middleware.LoggingMiddleware,
+		plugin.Use(&middleware.LogPlugin{}),
+	)
+}
+
+func (p *PresenceActor) topic() []byte {
+	return p.network.Community().TopicFor(p.locationDid)
+}
+
+func (p *PresenceActor) Receive(actorCtx actor.Context) {
+	switch msg := actorCtx.Message().(type) {
+	case *actor.Started:
+		actorCtx.Spawn(p.network.Community().NewSubscriberProps(p.topic()))
+		p.announce(jasonsgame.PresenceMessage_ARRIVE)
+		p.startHeartbeat(actorCtx.Self())
+	case *actor.Stopping:
+		close(p.done)
+		p.announce(jasonsgame.PresenceMessage_DEPART)
+	case *presenceTick:
+		p.announce(jasonsgame.PresenceMessage_HEARTBEAT)
+		for _, name := range p.occupants.expire(time.Now(), presenceExpiry) {
+			p.notify(actorCtx, fmt.Sprintf("%s has wandered off.", name))
+		}
+		p.forgetSentAt(time.Now())
+	case *jasonsgame.PresenceMessage:
+		p.handlePresenceMessage(actorCtx, msg)
+	case *GetOccupants:
+		actorCtx.Respond(&Occupants{Names: p.occupants.names()})
+	}
+}
+
+func (p *PresenceActor) handlePresenceMessage(actorCtx actor.Context, msg *jasonsgame.PresenceMessage) {
+	if msg.Location != p.locationDid || msg.From == p.playerDid {
+		return
+	}
+
+	now := time.Now()
+	if !chatlog.Recent(msg, now) || msg.SentAt < p.sentAt[msg.From] {
+		p.Log.Warnw("ignoring old presence message", "from", msg.From)
+		return
+	}
+
+	name, verified, err := p.verify(msg)
+	if err != nil {
+		p.Log.Warnw("error verifying presence message", "from", msg.From, "err", err)
+		return
+	}
+	if !verified {
+		p.Log.Warnw("ignoring presence message with a bad signature", "from", msg.From)
+		return
+	}
+	p.sentAt[msg.From] = msg.SentAt
+
+	switch msg.Kind {
+	case jasonsgame.PresenceMessage_DEPART:
+		if _, ok := p.occupants.remove(msg.From); ok {
+			p.notify(actorCtx, fmt.Sprintf("%s leaves.", name))
+		}
+	case jasonsgame.PresenceMessage_ARRIVE:
+		if p.occupants.seen(msg.From, name, now) {
+			p.notify(actorCtx, fmt.Sprintf("%s arrives.", name))
+		}
+		// let them know who is already here without waiting for a heartbeat
+		p.announce(jasonsgame.PresenceMessage_HEARTBEAT)
+	default:
+		// anyone new on a heartbeat was already here when we arrived
+		p.occupants.seen(msg.From, name, now)
+	}
+}
+
+// forgetSentAt drops sent ats too old to matter, anything sent before them
+// isn't recent anyway
+func (p *PresenceActor) forgetSentAt(now time.Time) {
+	for did, sentAt := range p.sentAt {
+		if now.Sub(time.Unix(sentAt, 0)) > chatlog.MaxAge {
+			delete(p.sentAt, did)
+		}
+	}
+}
+
+// verify checks the message was signed by an owner of the sender's tree,
+// returning the name on that tree. Senders are cached, and only looked up
+// again when a signature doesn't match.
+func (p *PresenceActor) verify(msg *jasonsgame.PresenceMessage) (string, bool, error) {
+	sender, ok := p.senders[msg.From]
+	if ok && chatlog.SignedBy(msg, sender.auths) {
+		return sender.name, true, nil
+	}
+
+	tree, err := p.network.GetTree(msg.From)
+	if err != nil {
+		return "", false, errors.Wrap(err, "error fetching sender")
+	}
+	if tree == nil {
+		return "", false, nil
+	}
+
+	auths, err := tree.Authentications()
+	if err != nil {
+		return "", false, errors.Wrap(err, "error fetching sender auths")
+	}
+
+	sender = &chatSender{auths: auths, name: playerNameOn(p.network, tree)}
+	p.senders[msg.From] = sender
+
+	return sender.name, chatlog.SignedBy(msg, auths), nil
+}
+
+func (p *PresenceActor) announce(kind jasonsgame.PresenceMessage_Kind) {
+	msg := &jasonsgame.PresenceMessage{
+		Kind:     kind,
+		From:     p.playerDid,
+		Name:     p.name,
+		Location: p.locationDid,
+		SentAt:   time.Now().Unix(),
+	}
+	err := chatlog.Sign(msg, p.network.PrivateKey())
+	if err != nil {
+		p.Log.Errorw("error signing presence", "err", err)
+		return
+	}
+
+	err = p.network.Community().Send(p.topic(), msg)
+	if err != nil {
+		p.Log.Errorw("error sending presence", "err", err)
+	}
+}
+
+func (p *PresenceActor) notify(actorCtx actor.Context, message string) {
+	if parent := actorCtx.Parent(); parent != nil {
+		actorCtx.Send(parent, &userEvent{message: message})
+	}
+}
+
+func (p *PresenceActor) startHeartbeat(self *actor.PID) {
+	p.done = make(chan struct{})
+	done := p.done
+
+	go func() {
+		ticker := time.NewTicker(presenceHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				actor.EmptyRootContext.Send(self, &presenceTick{})
+			}
+		}
+	}()
+}
+
+func (g *Game) playerName() string {
+	player, err := g.playerTree.Player()
+	if err != nil || player.Name == "" {
+		return g.playerTree.Did()
+	}
+	return player.Name
+}
+
+func (g *Game) getOccupants(actorCtx actor.Context) (*Occupants, error) {
+	response, err := actorCtx.RequestFuture(g.presenceActor, &GetOccupants{}, 5*time.Second).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	occupants, ok := response.(*Occupants)
+	if !ok {
+		return nil, fmt.Errorf("error casting Occupants")
+	}
+	return occupants, nil
+}
+
+func (g *Game) handleWho(actorCtx actor.Context) error {
+	occupants, err := g.getOccupants(actorCtx)
+	if err != nil {
+		return err
+	}
+
+	if len(occupants.Names) == 0 {
+		g.sendUserMessage(actorCtx, "there's nobody else here")
+		return nil
+	}
+
+	g.sendUserMessage(actorCtx, append(indentedList{"also here:"}, occupants.List()...))
+	return nil
+}
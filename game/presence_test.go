@@ -0,0 +1,38 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOccupantList(t *testing.T) {
+	now := time.Now()
+	occupants := make(occupantList)
+
+	require.True(t, occupants.seen("did:jason", "jason", now))
+	require.True(t, occupants.seen("did:ruth", "ruth", now.Add(-20*time.Second)))
+	require.False(t, occupants.seen("did:jason", "jason", now))
+	require.Equal(t, map[string]string{"did:jason": "jason", "did:ruth": "ruth"}, occupants.names())
+
+	require.Empty(t, occupants.expire(now, 30*time.Second))
+	require.Equal(t, []string{"ruth"}, occupants.expire(now.Add(15*time.Second), 30*time.Second))
+	require.Equal(t, map[string]string{"did:jason": "jason"}, occupants.names())
+
+	name, ok := occupants.remove("did:jason")
+	require.True(t, ok)
+	require.Equal(t, "jason", name)
+	_, ok = occupants.remove("did:jason")
+	require.False(t, ok)
+	require.Empty(t, occupants.names())
+}
+
+func TestOccupantsList(t *testing.T) {
+	occupants := &Occupants{Names: map[string]string{
+		"did:ruth":       "ruth",
+		"did:jason":      "jason",
+		"did:otherjason": "jason",
+	}}
+	require.Equal(t, []string{"jason", "jason", "ruth"}, occupants.List())
+}
@@ -0,0 +1,165 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/pkg/errors"
+	"github.com/quorumcontrol/chaintree/typecaster"
+	"github.com/quorumcontrol/tupelo-go-sdk/consensus"
+
+	"github.com/quorumcontrol/jasons-game/network"
+)
+
+func init() {
+	cbor.RegisterCborType(Quest{})
+	typecaster.AddType(Quest{})
+	cbor.RegisterCborType(QuestObjective{})
+	typecaster.AddType(QuestObjective{})
+	cbor.RegisterCborType(QuestProgress{})
+	typecaster.AddType(QuestProgress{})
+}
+
+var questPath = []string{"quest"}
+
+// QuestTree is a chaintree holding a quest definition, which players
+// can start through a StartQuestInteraction
+type QuestTree struct {
+	tree    *consensus.SignedChainTree
+	network network.Network
+}
+
+func NewQuestTree(net network.Network, tree *consensus.SignedChainTree) *QuestTree {
+	return &QuestTree{
+		tree:    tree,
+		network: net,
+	}
+}
+
+func FindQuestTree(net network.Network, did string) (*QuestTree, error) {
+	tree, err := net.GetTree(did)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("error fetching quest %v", did))
+	}
+	if tree == nil {
+		return nil, fmt.Errorf("could not find quest %v", did)
+	}
+	return NewQuestTree(net, tree), nil
+}
+
+func CreateQuestTree(net network.Network, quest *Quest) (*QuestTree, error) {
+	tree, err := net.CreateChainTree()
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating quest chaintree")
+	}
+
+	questTree := NewQuestTree(net, tree)
+	err = questTree.SetQuest(quest)
+	if err != nil {
+		return nil, err
+	}
+	return questTree, nil
+}
+
+func (q *QuestTree) MustId() string {
+	return q.tree.MustId()
+}
+
+func (q *QuestTree) Tree() *consensus.SignedChainTree {
+	return q.tree
+}
+
+func (q *QuestTree) Quest() (*Quest, error) {
+	uncastQuest, _, err := q.tree.ChainTree.Dag.Resolve(context.TODO(), append([]string{"tree", "data", "jasons-game"}, questPath...))
+	if err != nil {
+		return nil, errors.Wrap(err, "error resolving quest")
+	}
+	if uncastQuest == nil {
+		return nil, fmt.Errorf("tree %v is not a quest", q.MustId())
+	}
+
+	quest := new(Quest)
+	err = typecaster.ToType(uncastQuest, quest)
+	if err != nil {
+		return nil, errors.Wrap(err, "error casting quest")
+	}
+	return quest, nil
+}
+
+func (q *QuestTree) SetQuest(quest *Quest) error {
+	if quest.Name == "" {
+		return fmt.Errorf("quests must have a name")
+	}
+	if len(quest.Objectives) == 0 {
+		return fmt.Errorf("quests must have at least one objective")
+	}
+
+	newTree, err := q.network.UpdateChainTree(q.tree, strings.Join(append([]string{"jasons-game"}, questPath...), "/"), quest)
+	if err != nil {
+		return errors.Wrap(err, "error updating quest")
+	}
+	q.tree = newTree
+	return nil
+}
+
+// Describe is shown to players when an objective has no description
+func (o *QuestObjective) Describe() string {
+	switch {
+	case o.Description != "":
+		return o.Description
+	case o.VisitLocation != "":
+		return "visit " + o.VisitLocation
+	case o.HoldObject != "":
+		return "hold the " + o.HoldObject
+	case o.UseInteraction != "":
+		return o.UseInteraction
+	case o.ReceivePrize != "":
+		return "receive the prize from " + o.ReceivePrize
+	default:
+		return "unknown objective"
+	}
+}
+
+func (p *QuestProgress) IsObjectiveComplete(idx int) bool {
+	for _, completed := range p.CompletedObjectives {
+		if int(completed) == idx {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *QuestProgress) CompleteObjective(idx int) {
+	if !p.IsObjectiveComplete(idx) {
+		p.CompletedObjectives = append(p.CompletedObjectives, uint32(idx))
+	}
+}
+
+// questEvent is something the player did that may meet quest objectives
+type questEvent struct {
+	// did of the location the player moved to
+	locationDid string
+	// command of the interaction the player used
+	interaction string
+	// the player picked something up, so could be holding a new object
+	pickedUp bool
+	// did of the handler of the location the player picked something up from
+	prizeFrom string
+}
+
+// matches is true for objectives met by the event itself. Holding an
+// object depends on the player's inventory, so is checked by the game.
+func (o *QuestObjective) matches(evt *questEvent) bool {
+	switch {
+	case o.VisitLocation != "":
+		return evt.locationDid == o.VisitLocation
+	case o.UseInteraction != "":
+		return evt.interaction != "" && strings.EqualFold(evt.interaction, o.UseInteraction)
+	case o.ReceivePrize != "":
+		return evt.prizeFrom == o.ReceivePrize
+	default:
+		return false
+	}
+}
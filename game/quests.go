@@ -0,0 +1,295 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/AsynkronIT/protoactor-go/actor"
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+)
+
+// cachedQuest is a quest as it was at tip, so it isn't decoded again on
+// every action while its tree stays the same
+type cachedQuest struct {
+	tip   cid.Cid
+	quest *Quest
+}
+
+type playerQuest struct {
+	did      string
+	quest    *Quest
+	progress *QuestProgress
+}
+
+func (q *playerQuest) completedCount() int {
+	completed := 0
+	for idx := range q.quest.Objectives {
+		if q.progress.IsObjectiveComplete(idx) {
+			completed++
+		}
+	}
+	return completed
+}
+
+// playerQuests returns the quests the player has started, sorted by name
+func (g *Game) playerQuests() ([]*playerQuest, error) {
+	return g.fetchPlayerQuests(true)
+}
+
+// fetchPlayerQuests leaves out completed quests unless withCompleted
+func (g *Game) fetchPlayerQuests(withCompleted bool) ([]*playerQuest, error) {
+	progresses, err := g.playerTree.QuestProgresses()
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching quest progress")
+	}
+
+	quests := make([]*playerQuest, 0, len(progresses))
+	for did, progress := range progresses {
+		if !withCompleted && progress.CompletedAt > 0 {
+			continue
+		}
+		quest, err := g.questFor(did)
+		if err != nil {
+			return nil, err
+		}
+		quests = append(quests, &playerQuest{did: did, quest: quest, progress: progress})
+	}
+
+	sort.Slice(quests, func(i, j int) bool {
+		return quests[i].quest.Name < quests[j].quest.Name
+	})
+	return quests, nil
+}
+
+func (g *Game) questFor(did string) (*Quest, error) {
+	questTree, err := FindQuestTree(g.network, did)
+	if err != nil {
+		return nil, err
+	}
+
+	cached, ok := g.quests[did]
+	if ok && cached.tip.Equals(questTree.Tree().Tip()) {
+		return cached.quest, nil
+	}
+
+	quest, err := questTree.Quest()
+	if err != nil {
+		return nil, err
+	}
+	g.quests[did] = &cachedQuest{tip: questTree.Tree().Tip(), quest: quest}
+	return quest, nil
+}
+
+func (g *Game) findPlayerQuest(name string) (*playerQuest, error) {
+	quests, err := g.playerQuests()
+	if err != nil {
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		// with only one quest going there's no need to name it
+		var active []*playerQuest
+		for _, quest := range quests {
+			if quest.progress.CompletedAt == 0 {
+				active = append(active, quest)
+			}
+		}
+		if len(active) == 1 {
+			return active[0], nil
+		}
+		return nil, fmt.Errorf("which quest? type `quests` to see your quests")
+	}
+
+	for _, quest := range quests {
+		if strings.EqualFold(quest.quest.Name, name) {
+			return quest, nil
+		}
+	}
+	return nil, fmt.Errorf("you aren't on a quest named %s", name)
+}
+
+func (g *Game) handleQuestList(actorCtx actor.Context) error {
+	quests, err := g.playerQuests()
+	if err != nil {
+		return err
+	}
+
+	if len(quests) == 0 {
+		g.sendUserMessage(actorCtx, "you aren't on any quests")
+		return nil
+	}
+
+	toSend := indentedList{"your quests:"}
+	for _, quest := range quests {
+		if quest.progress.CompletedAt > 0 {
+			toSend = append(toSend, fmt.Sprintf("%s (complete)", quest.quest.Name))
+		} else {
+			toSend = append(toSend, fmt.Sprintf("%s (%d/%d)", quest.quest.Name, quest.completedCount(), len(quest.quest.Objectives)))
+		}
+	}
+	g.sendUserMessage(actorCtx, toSend)
+	return nil
+}
+
+func (g *Game) handleQuestDetails(actorCtx actor.Context, name string) error {
+	quest, err := g.findPlayerQuest(name)
+	if err != nil {
+		return err
+	}
+	g.sendUserMessage(actorCtx, describeQuest(quest.quest, quest.progress))
+	return nil
+}
+
+func (g *Game) handleAbandonQuest(actorCtx actor.Context, name string) error {
+	quest, err := g.findPlayerQuest(name)
+	if err != nil {
+		return err
+	}
+
+	err = g.playerTree.SetQuestProgress(quest.did, nil)
+	if err != nil {
+		return errors.Wrap(err, "error abandoning quest")
+	}
+
+	g.sendUserMessage(actorCtx, fmt.Sprintf("you have abandoned the quest %s", quest.quest.Name))
+	return nil
+}
+
+func (g *Game) handleStartQuest(actorCtx actor.Context, questDid string) error {
+	questTree, err := FindQuestTree(g.network, questDid)
+	if err != nil {
+		return err
+	}
+	quest, err := questTree.Quest()
+	if err != nil {
+		return err
+	}
+
+	progress, err := g.playerTree.QuestProgress(questDid)
+	if err != nil {
+		return errors.Wrap(err, "error fetching quest progress")
+	}
+	if progress != nil {
+		if progress.CompletedAt > 0 {
+			g.sendUserMessage(actorCtx, fmt.Sprintf("you have already completed the quest %s", quest.Name))
+		} else {
+			g.sendUserMessage(actorCtx, fmt.Sprintf("you are already on the quest %s", quest.Name))
+		}
+		return nil
+	}
+
+	progress = &QuestProgress{StartedAt: time.Now().Unix()}
+	err = g.playerTree.SetQuestProgress(questDid, progress)
+	if err != nil {
+		return errors.Wrap(err, "error starting quest")
+	}
+
+	g.sendUserMessage(actorCtx, append(indentedList{fmt.Sprintf("you have started the quest %s", quest.Name)}, describeQuest(quest, progress)[1:]...))
+
+	// objectives may already be met, e.g. holding the right object
+	g.updateQuests(actorCtx, &questEvent{locationDid: g.locationDid, pickedUp: true})
+	return nil
+}
+
+func describeQuest(quest *Quest, progress *QuestProgress) indentedList {
+	toSend := indentedList{quest.Name}
+	if quest.Description != "" {
+		toSend = append(toSend, quest.Description)
+	}
+	for idx, objective := range quest.Objectives {
+		check := " "
+		if progress.IsObjectiveComplete(idx) {
+			check = "x"
+		}
+		toSend = append(toSend, fmt.Sprintf("[%s] %s", check, objective.Describe()))
+	}
+	return toSend
+}
+
+// updateQuests checks the objectives of every quest the player is on against
+// something they just did. Quest tracking should never get in the way of
+// playing, so failures are only logged.
+func (g *Game) updateQuests(actorCtx actor.Context, evt *questEvent) {
+	quests, err := g.fetchPlayerQuests(false)
+	if err != nil {
+		log.Warningf("error fetching quests: %v", err)
+		return
+	}
+
+	for _, quest := range quests {
+		if quest.progress.CompletedAt > 0 {
+			continue
+		}
+
+		changed := false
+		for idx, objective := range quest.quest.Objectives {
+			if quest.progress.IsObjectiveComplete(idx) {
+				continue
+			}
+
+			met, err := g.objectiveMet(actorCtx, objective, evt)
+			if err != nil {
+				log.Warningf("error checking quest objective: %v", err)
+				continue
+			}
+			if met {
+				quest.progress.CompleteObjective(idx)
+				changed = true
+				g.sendUserMessage(actorCtx, fmt.Sprintf("%s: %s - done", quest.quest.Name, objective.Describe()))
+			}
+		}
+
+		if !changed {
+			continue
+		}
+
+		if quest.completedCount() == len(quest.quest.Objectives) {
+			quest.progress.CompletedAt = time.Now().Unix()
+			if quest.quest.CompletionMessage != "" {
+				g.sendUserMessage(actorCtx, quest.quest.CompletionMessage)
+			} else {
+				g.sendUserMessage(actorCtx, fmt.Sprintf("you have completed the quest %s", quest.quest.Name))
+			}
+		}
+
+		err = g.playerTree.SetQuestProgress(quest.did, quest.progress)
+		if err != nil {
+			log.Warningf("error saving quest progress: %v", err)
+		}
+	}
+}
+
+func (g *Game) objectiveMet(actorCtx actor.Context, objective *QuestObjective, evt *questEvent) (bool, error) {
+	if objective.HoldObject != "" {
+		if !evt.pickedUp {
+			return false, nil
+		}
+		return g.checkCondition(actorCtx, &InteractionCondition{InventoryContains: objective.HoldObject})
+	}
+	return objective.matches(evt), nil
+}
+
+// currentLocationHandlerDid is the did of the handler of the current
+// location, empty if it doesn't have one
+func (g *Game) currentLocationHandlerDid() (string, error) {
+	tree, err := g.network.GetTree(g.locationDid)
+	if err != nil || tree == nil {
+		return "", err
+	}
+
+	uncastDid, _, err := tree.ChainTree.Dag.Resolve(context.TODO(), []string{"tree", "data", "jasons-game-handler"})
+	if err != nil || uncastDid == nil {
+		return "", err
+	}
+
+	did, ok := uncastDid.(string)
+	if !ok {
+		return "", fmt.Errorf("error casting handler did; type is %T", uncastDid)
+	}
+	return did, nil
+}
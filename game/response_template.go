@@ -0,0 +1,212 @@
+package game
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/AsynkronIT/protoactor-go/actor"
+	"github.com/pkg/errors"
+	"github.com/quorumcontrol/tupelo-go-sdk/consensus"
+
+	"github.com/quorumcontrol/jasons-game/network"
+	"github.com/quorumcontrol/jasons-game/pb/jasonsgame"
+)
+
+// Response templates use [[ ]] rather than {{ }} so they pass through the
+// importer, which runs its own templating over the yaml with {{ }}
+const (
+	responseTemplateLeftDelim  = "[["
+	responseTemplateRightDelim = "]]"
+)
+
+const (
+	responseTemplateMaxSteps     = 200
+	responseTemplateMaxOutput    = 4096
+	responseTemplateMaxTreeReads = 10
+)
+
+type responseTemplateData struct {
+	Player    *jasonsgame.Player
+	Location  *jasonsgame.Location
+	Now       time.Time
+	TimeOfDay string
+}
+
+// renderResponse renders response as a template if it contains any template
+// actions. Rendering failures are logged and the raw response is returned, so
+// a bad template can't take down the game.
+func (g *Game) renderResponse(actorCtx actor.Context, response string) string {
+	if !strings.Contains(response, responseTemplateLeftDelim) {
+		return response
+	}
+
+	data := &responseTemplateData{
+		Now: time.Now(),
+	}
+	data.TimeOfDay = timeOfDay(data.Now)
+
+	player, err := g.playerTree.Player()
+	if err != nil {
+		log.Warningf("error fetching player for response template: %v", err)
+	}
+	data.Player = player
+
+	location, err := g.getCurrentLocation(actorCtx)
+	if err != nil {
+		log.Warningf("error fetching location for response template: %v", err)
+	}
+	data.Location = location
+
+	rendered, err := renderResponseTemplate(g.network, response, data)
+	if err != nil {
+		log.Warningf("error rendering response template: %v", err)
+		return response
+	}
+	return rendered
+}
+
+// renderResponseTemplate executes the template and recovers from any panic
+// inside of it. Templates can't loop or call other templates, so the time
+// they take is bounded by their size, see checkResponseTemplate.
+func renderResponseTemplate(net network.Network, text string, data *responseTemplateData) (rendered string, err error) {
+	tmpl, err := template.New("response").
+		Delims(responseTemplateLeftDelim, responseTemplateRightDelim).
+		Funcs(responseTemplateFuncs(net)).
+		Parse(text)
+	if err != nil {
+		return "", errors.Wrap(err, "error parsing template")
+	}
+
+	if len(tmpl.Templates()) > 1 {
+		return "", fmt.Errorf("templates may not define other templates")
+	}
+	steps := 0
+	err = checkResponseTemplate(tmpl.Tree.Root, &steps)
+	if err != nil {
+		return "", err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			rendered, err = "", fmt.Errorf("template panicked: %v", r)
+		}
+	}()
+
+	out := &limitedBuffer{limit: responseTemplateMaxOutput}
+	err = tmpl.Execute(out, data)
+	if err != nil {
+		return "", errors.Wrap(err, "error executing template")
+	}
+	return out.String(), nil
+}
+
+// checkResponseTemplate rejects range and template actions, and counts each
+// node into steps, so that executing a template runs each node at most once
+// and no more than responseTemplateMaxSteps nodes are run
+func checkResponseTemplate(node parse.Node, steps *int) error {
+	if node == nil {
+		return nil
+	}
+
+	*steps++
+	if *steps > responseTemplateMaxSteps {
+		return fmt.Errorf("templates are limited to %d steps", responseTemplateMaxSteps)
+	}
+
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			err := checkResponseTemplate(child, steps)
+			if err != nil {
+				return err
+			}
+		}
+	case *parse.IfNode:
+		return checkResponseBranch(&n.BranchNode, steps)
+	case *parse.WithNode:
+		return checkResponseBranch(&n.BranchNode, steps)
+	case *parse.RangeNode:
+		return fmt.Errorf("templates may not use range")
+	case *parse.TemplateNode:
+		return fmt.Errorf("templates may not call other templates")
+	}
+	return nil
+}
+
+func checkResponseBranch(branch *parse.BranchNode, steps *int) error {
+	err := checkResponseTemplate(branch.List, steps)
+	if err != nil {
+		return err
+	}
+	if branch.ElseList == nil {
+		return nil
+	}
+	return checkResponseTemplate(branch.ElseList, steps)
+}
+
+func responseTemplateFuncs(net network.Network) template.FuncMap {
+	treeReads := 0
+
+	return template.FuncMap{
+		// tree resolves a value from the jasons-game data of any tree, e.g.
+		// [[ tree .Location.Did "somevalue" ]]
+		"tree": func(did string, path string) (interface{}, error) {
+			treeReads++
+			if treeReads > responseTemplateMaxTreeReads {
+				return nil, fmt.Errorf("templates may only read %d tree values", responseTemplateMaxTreeReads)
+			}
+
+			tree, err := net.GetTree(did)
+			if err != nil {
+				return nil, errors.Wrap(err, "error fetching tree")
+			}
+			if tree == nil {
+				return nil, fmt.Errorf("could not find tree with did %v", did)
+			}
+
+			pathSlice, err := consensus.DecodePath(path)
+			if err != nil {
+				return nil, errors.Wrap(err, "error casting path")
+			}
+
+			value, _, err := tree.ChainTree.Dag.Resolve(context.Background(), append([]string{"tree", "data", "jasons-game"}, pathSlice...))
+			if err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("error fetching value for %v", path))
+			}
+			return value, nil
+		},
+	}
+}
+
+func timeOfDay(t time.Time) string {
+	switch hour := t.Hour(); {
+	case hour >= 5 && hour < 12:
+		return "morning"
+	case hour >= 12 && hour < 17:
+		return "afternoon"
+	case hour >= 17 && hour < 21:
+		return "evening"
+	default:
+		return "night"
+	}
+}
+
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		return 0, fmt.Errorf("template output is limited to %d bytes", b.limit)
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,77 @@
+package game
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/quorumcontrol/jasons-game/network"
+	"github.com/quorumcontrol/jasons-game/pb/jasonsgame"
+)
+
+func TestRenderResponseTemplate(t *testing.T) {
+	net := network.NewLocalNetwork()
+
+	tree, err := net.CreateChainTree()
+	require.Nil(t, err)
+	tree, err = net.UpdateChainTree(tree, "jasons-game/weather", "raining")
+	require.Nil(t, err)
+
+	data := &responseTemplateData{
+		Player:    &jasonsgame.Player{Name: "jason"},
+		Location:  &jasonsgame.Location{Did: tree.MustId(), Description: "a damp cave"},
+		Now:       time.Date(2019, 10, 1, 9, 0, 0, 0, time.UTC),
+		TimeOfDay: "morning",
+	}
+
+	t.Run("renders variables and tree values", func(t *testing.T) {
+		rendered, err := renderResponseTemplate(net, `good [[ .TimeOfDay ]] [[ .Player.Name ]], it is [[ tree .Location.Did "weather" ]] in [[ .Location.Description ]]`, data)
+		require.Nil(t, err)
+		require.Equal(t, "good morning jason, it is raining in a damp cave", rendered)
+	})
+
+	t.Run("leaves importer delimiters alone", func(t *testing.T) {
+		rendered, err := renderResponseTemplate(net, "{{ .Player.Name }}", data)
+		require.Nil(t, err)
+		require.Equal(t, "{{ .Player.Name }}", rendered)
+	})
+
+	t.Run("errors on bad templates", func(t *testing.T) {
+		_, err := renderResponseTemplate(net, "[[ .Nope ]]", data)
+		require.NotNil(t, err)
+
+		_, err = renderResponseTemplate(net, "[[ if ]]", data)
+		require.NotNil(t, err)
+
+		_, err = renderResponseTemplate(net, `[[ tree "did:tupelo:nope" "weather" ]]`, data)
+		require.NotNil(t, err)
+	})
+
+	t.Run("limits output and tree reads", func(t *testing.T) {
+		_, err := renderResponseTemplate(net, "[[ .Player.Name ]]"+strings.Repeat("x", responseTemplateMaxOutput), data)
+		require.NotNil(t, err)
+
+		_, err = renderResponseTemplate(net, strings.Repeat(`[[ tree .Location.Did "weather" ]]`, responseTemplateMaxTreeReads+1), data)
+		require.NotNil(t, err)
+	})
+
+	t.Run("rejects loops and nested templates", func(t *testing.T) {
+		_, err := renderResponseTemplate(net, `[[ range .Player.Name ]]x[[ end ]]`, data)
+		require.NotNil(t, err)
+
+		_, err = renderResponseTemplate(net, `[[ define "again" ]][[ template "again" ]][[ end ]][[ template "again" ]]`, data)
+		require.NotNil(t, err)
+
+		_, err = renderResponseTemplate(net, strings.Repeat(`[[ if true ]]x[[ end ]]`, responseTemplateMaxSteps), data)
+		require.NotNil(t, err)
+	})
+}
+
+func TestTimeOfDay(t *testing.T) {
+	require.Equal(t, "morning", timeOfDay(time.Date(2019, 10, 1, 5, 0, 0, 0, time.UTC)))
+	require.Equal(t, "afternoon", timeOfDay(time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)))
+	require.Equal(t, "evening", timeOfDay(time.Date(2019, 10, 1, 20, 59, 0, 0, time.UTC)))
+	require.Equal(t, "night", timeOfDay(time.Date(2019, 10, 1, 2, 0, 0, 0, time.UTC)))
+}
@@ -79,3 +79,51 @@ func AtHeight(ctx context.Context, tree *chaintree.ChainTree, height uint64) (*c
 
 	return nil, fmt.Errorf("height of %d not found", height)
 }
+
+type Version struct {
+	Tip    cid.Cid
+	Height uint64
+}
+
+// History returns every version of a chaintree from newest to oldest
+func History(ctx context.Context, tree *chaintree.ChainTree) ([]*Version, error) {
+	versions := []*Version{}
+	tip := tree.Dag.Tip
+
+	for !tip.Equals(cid.Undef) {
+		treeAt, err := tree.At(ctx, &tip)
+		if err != nil {
+			return versions, err
+		}
+
+		treeHeight, err := Height(ctx, treeAt)
+		if err != nil {
+			return versions, err
+		}
+		versions = append(versions, &Version{Tip: tip, Height: treeHeight})
+
+		previousBlockUncast, _, err := treeAt.Dag.Resolve(ctx, []string{"chain", "end"})
+		if err != nil {
+			return versions, err
+		}
+		if previousBlockUncast == nil {
+			break
+		}
+		previousBlock, ok := previousBlockUncast.(map[string]interface{})
+		if !ok {
+			return versions, fmt.Errorf("chain previous block could not be cast")
+		}
+
+		previousTip, ok := previousBlock["previousTip"]
+		if !ok || previousTip == nil {
+			break
+		}
+
+		tip, ok = previousTip.(cid.Cid)
+		if !ok {
+			return versions, fmt.Errorf("chain.end could not be cast")
+		}
+	}
+
+	return versions, nil
+}
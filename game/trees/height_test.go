@@ -70,3 +70,30 @@ func TestAtHeight(t *testing.T) {
 	_, err = AtHeight(ctx, tree.ChainTree, 4)
 	require.NotNil(t, err)
 }
+
+func TestHistory(t *testing.T) {
+	ctx := context.Background()
+	net := network.NewLocalNetwork()
+
+	tree, err := net.CreateChainTree()
+	require.Nil(t, err)
+
+	for i := 1; i <= 3; i++ {
+		tree, err = net.UpdateChainTree(tree, "test", i)
+		require.Nil(t, err)
+	}
+
+	versions, err := History(ctx, tree.ChainTree)
+	require.Nil(t, err)
+	require.True(t, len(versions) >= 3)
+	require.Equal(t, uint64(3), versions[0].Height)
+	require.Equal(t, tree.Tip(), versions[0].Tip)
+
+	for i, version := range versions[1:] {
+		require.True(t, version.Height < versions[i].Height)
+
+		treeAt, err := AtHeight(ctx, tree.ChainTree, version.Height)
+		require.Nil(t, err)
+		require.Equal(t, version.Tip, treeAt.Dag.Tip)
+	}
+}
@@ -0,0 +1,56 @@
+package trees
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/quorumcontrol/chaintree/chaintree"
+
+	"github.com/quorumcontrol/jasons-game/network"
+)
+
+// Signers are the addresses of the keys that signed the tree's latest block
+func Signers(ctx context.Context, tree *chaintree.ChainTree) ([]string, error) {
+	uncast, _, err := tree.Dag.Resolve(ctx, []string{"chain", "end", "headers", "signatures"})
+	if err != nil {
+		return nil, errors.Wrap(err, "error resolving block signatures")
+	}
+	if uncast == nil {
+		return nil, nil
+	}
+
+	signatures, ok := uncast.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("error casting block signatures; type is %T", uncast)
+	}
+
+	signers := make([]string, 0, len(signatures))
+	for addr := range signatures {
+		signers = append(signers, addr)
+	}
+	sort.Strings(signers)
+	return signers, nil
+}
+
+// BlockTime is when the tree's latest block was made, from its
+// network.BlockTimeHeader, or zero if the block wasn't stamped
+func BlockTime(ctx context.Context, tree *chaintree.ChainTree) (time.Time, error) {
+	uncast, _, err := tree.Dag.Resolve(ctx, []string{"chain", "end", "headers", network.BlockTimeHeader})
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "error resolving block time")
+	}
+
+	switch blockTime := uncast.(type) {
+	case nil:
+		return time.Time{}, nil
+	case int64:
+		return time.Unix(blockTime, 0), nil
+	case uint64:
+		return time.Unix(int64(blockTime), 0), nil
+	default:
+		return time.Time{}, fmt.Errorf("error casting block time; type is %T", uncast)
+	}
+}
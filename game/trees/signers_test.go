@@ -0,0 +1,43 @@
+package trees
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quorumcontrol/jasons-game/network"
+)
+
+func TestSigners(t *testing.T) {
+	ctx := context.Background()
+	net := network.NewLocalNetwork()
+
+	tree, err := net.CreateChainTree()
+	require.Nil(t, err)
+
+	tree, err = net.UpdateChainTree(tree, "test", "1")
+	require.Nil(t, err)
+
+	signers, err := Signers(ctx, tree.ChainTree)
+	require.Nil(t, err)
+	require.Equal(t, []string{crypto.PubkeyToAddress(*net.PublicKey()).String()}, signers)
+}
+
+func TestBlockTime(t *testing.T) {
+	ctx := context.Background()
+	net := network.NewLocalNetwork()
+
+	tree, err := net.CreateChainTree()
+	require.Nil(t, err)
+
+	before := time.Now().Add(-time.Second)
+	tree, err = net.UpdateChainTree(tree, "test", "1")
+	require.Nil(t, err)
+
+	blockTime, err := BlockTime(ctx, tree.ChainTree)
+	require.Nil(t, err)
+	require.True(t, blockTime.After(before))
+}
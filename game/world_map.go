@@ -0,0 +1,531 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/AsynkronIT/protoactor-go/actor"
+	"github.com/pkg/errors"
+
+	"github.com/quorumcontrol/jasons-game/game/static"
+	"github.com/quorumcontrol/jasons-game/network"
+	"github.com/quorumcontrol/jasons-game/pb/jasonsgame"
+)
+
+// how many cells around the current location the ascii map shows
+const worldMapRadius = 3
+
+// portalCommand is the exit every portal adds to its location
+const portalCommand = "go through portal"
+
+type gridOffset struct {
+	x int64
+	y int64
+}
+
+var directionOffsets = map[string]gridOffset{
+	"north":     {0, 1},
+	"south":     {0, -1},
+	"east":      {1, 0},
+	"west":      {-1, 0},
+	"northeast": {1, 1},
+	"northwest": {-1, 1},
+	"southeast": {1, -1},
+	"southwest": {-1, -1},
+}
+
+// neighbourOffsets is the order exits without a direction are placed in
+var neighbourOffsets = []gridOffset{
+	{1, 0}, {0, -1}, {-1, 0}, {0, 1}, {1, -1}, {-1, -1}, {-1, 1}, {1, 1},
+}
+
+// exitDirection returns the grid offset for commands like "go north" or "n"
+func exitDirection(command string) (gridOffset, bool) {
+	for _, word := range strings.Fields(strings.ToLower(command)) {
+		if offset, ok := directionOffsets[word]; ok {
+			return offset, true
+		}
+		for direction, aliases := range interactionAliases {
+			offset, ok := directionOffsets[direction]
+			if !ok {
+				continue
+			}
+			for _, alias := range aliases {
+				if word == alias {
+					return offset, true
+				}
+			}
+		}
+	}
+	return gridOffset{}, false
+}
+
+type worldMapExit struct {
+	from    string
+	to      string
+	command string
+}
+
+type worldMapLocation struct {
+	did         string
+	description string
+	visited     bool
+	x           int64
+	y           int64
+	placed      bool
+}
+
+// worldMap is the graph of locations the player has visited, and the
+// locations their exits lead to
+type worldMap struct {
+	locations map[string]*worldMapLocation
+	exits     []*worldMapExit
+}
+
+func newWorldMap() *worldMap {
+	return &worldMap{
+		locations: make(map[string]*worldMapLocation),
+	}
+}
+
+func (m *worldMap) location(did string) *worldMapLocation {
+	loc, ok := m.locations[did]
+	if !ok {
+		loc = &worldMapLocation{did: did}
+		m.locations[did] = loc
+	}
+	return loc
+}
+
+func (m *worldMap) addVisited(did string, description string) {
+	loc := m.location(did)
+	loc.visited = true
+	loc.description = description
+}
+
+func (m *worldMap) addExit(exit *worldMapExit) {
+	m.location(exit.from)
+	m.location(exit.to)
+	m.exits = append(m.exits, exit)
+}
+
+// replaceLocation records a visit to did, with exits replacing any exits
+// the map had for it before. Unvisited locations no exit leads to any more
+// are dropped.
+func (m *worldMap) replaceLocation(did string, description string, exits []*worldMapExit) {
+	m.addVisited(did, description)
+
+	kept := m.exits[:0]
+	for _, exit := range m.exits {
+		if exit.from != did {
+			kept = append(kept, exit)
+		}
+	}
+	m.exits = kept
+	for _, exit := range exits {
+		m.addExit(exit)
+	}
+
+	leadTo := make(map[string]bool)
+	for _, exit := range m.exits {
+		leadTo[exit.to] = true
+	}
+	for locDid, loc := range m.locations {
+		if !loc.visited && !leadTo[locDid] {
+			delete(m.locations, locDid)
+		}
+	}
+}
+
+// exitsFrom returns the exits from did sorted by command
+func (m *worldMap) exitsFrom(did string) []*worldMapExit {
+	var exits []*worldMapExit
+	for _, exit := range m.exits {
+		if exit.from == did {
+			exits = append(exits, exit)
+		}
+	}
+	sort.Slice(exits, func(i, j int) bool {
+		return exits[i].command < exits[j].command
+	})
+	return exits
+}
+
+func (m *worldMap) connected(a string, b string) bool {
+	for _, exit := range m.exits {
+		if (exit.from == a && exit.to == b) || (exit.from == b && exit.to == a) {
+			return true
+		}
+	}
+	return false
+}
+
+// layout assigns grid coordinates by walking out from each root in order,
+// following compass directions where exits have them. Locations that can't
+// be reached from an earlier root are laid out to the east of the rest.
+func (m *worldMap) layout(roots ...string) {
+	occupied := make(map[gridOffset]string)
+	for _, loc := range m.locations {
+		loc.placed = false
+	}
+
+	place := func(loc *worldMapLocation, want gridOffset) {
+		cell := nearestFreeCell(occupied, want)
+		loc.x, loc.y, loc.placed = cell.x, cell.y, true
+		occupied[cell] = loc.did
+	}
+
+	dids := make([]string, 0, len(m.locations))
+	for did := range m.locations {
+		dids = append(dids, did)
+	}
+	sort.Strings(dids)
+
+	for _, root := range append(roots, dids...) {
+		rootLoc, ok := m.locations[root]
+		if !ok || rootLoc.placed {
+			continue
+		}
+
+		var maxX int64 = -2
+		for cell := range occupied {
+			if cell.x > maxX {
+				maxX = cell.x
+			}
+		}
+		place(rootLoc, gridOffset{maxX + 2, 0})
+
+		queue := []*worldMapLocation{rootLoc}
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+
+			neighbour := 0
+			for _, exit := range m.exitsFrom(current.did) {
+				next := m.locations[exit.to]
+				if next.placed {
+					continue
+				}
+
+				offset, ok := exitDirection(exit.command)
+				if !ok {
+					offset = neighbourOffsets[neighbour%len(neighbourOffsets)]
+					neighbour++
+				}
+				place(next, gridOffset{current.x + offset.x, current.y + offset.y})
+				queue = append(queue, next)
+			}
+		}
+	}
+}
+
+// nearestFreeCell searches rings of growing size around want
+func nearestFreeCell(occupied map[gridOffset]string, want gridOffset) gridOffset {
+	if _, taken := occupied[want]; !taken {
+		return want
+	}
+	for ring := int64(1); ; ring++ {
+		for dy := ring; dy >= -ring; dy-- {
+			for dx := -ring; dx <= ring; dx++ {
+				if dx != -ring && dx != ring && dy != -ring && dy != ring {
+					continue
+				}
+				cell := gridOffset{want.x + dx, want.y + dy}
+				if _, taken := occupied[cell]; !taken {
+					return cell
+				}
+			}
+		}
+	}
+}
+
+// render draws the map within radius cells of current, north is up
+func (m *worldMap) render(current string, radius int64) []string {
+	center, ok := m.locations[current]
+	if !ok || !center.placed {
+		return []string{"you haven't explored anywhere yet"}
+	}
+
+	grid := make(map[gridOffset]*worldMapLocation)
+	for _, loc := range m.locations {
+		if loc.placed {
+			grid[gridOffset{loc.x, loc.y}] = loc
+		}
+	}
+
+	linked := func(a gridOffset, b gridOffset) bool {
+		locA, okA := grid[a]
+		locB, okB := grid[b]
+		return okA && okB && m.connected(locA.did, locB.did)
+	}
+
+	width := int(radius*2+1)*4 - 1
+	var lines []string
+	for y := center.y + radius; y >= center.y-radius; y-- {
+		cells := []byte(strings.Repeat(" ", width))
+		below := []byte(strings.Repeat(" ", width))
+
+		for x := center.x - radius; x <= center.x+radius; x++ {
+			col := int(x-(center.x-radius)) * 4
+			cell := gridOffset{x, y}
+
+			if loc, ok := grid[cell]; ok {
+				mark := byte(' ')
+				switch {
+				case loc.did == current:
+					mark = '@'
+				case !loc.visited:
+					mark = '?'
+				}
+				copy(cells[col:], []byte{'[', mark, ']'})
+			}
+
+			if x < center.x+radius && linked(cell, gridOffset{x + 1, y}) {
+				cells[col+3] = '-'
+			}
+			if linked(cell, gridOffset{x, y - 1}) {
+				below[col+1] = '|'
+			}
+			if x < center.x+radius {
+				down := linked(cell, gridOffset{x + 1, y - 1})
+				up := linked(gridOffset{x, y - 1}, gridOffset{x + 1, y})
+				switch {
+				case down && up:
+					below[col+3] = 'X'
+				case down:
+					below[col+3] = '\\'
+				case up:
+					below[col+3] = '/'
+				}
+			}
+		}
+
+		lines = append(lines, strings.TrimRight(string(cells), " "))
+		if y > center.y-radius {
+			lines = append(lines, strings.TrimRight(string(below), " "))
+		}
+	}
+
+	// drop empty rows at the top and bottom
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return append(lines, "", "@ is you, ? is somewhere you haven't been yet")
+}
+
+func (m *worldMap) toUpdate(current string) *jasonsgame.MapUpdate {
+	update := &jasonsgame.MapUpdate{Current: current}
+
+	dids := make([]string, 0, len(m.locations))
+	for did := range m.locations {
+		dids = append(dids, did)
+	}
+	sort.Strings(dids)
+
+	for _, did := range dids {
+		loc := m.locations[did]
+		update.Locations = append(update.Locations, &jasonsgame.MapLocation{
+			Did:         loc.did,
+			X:           loc.x,
+			Y:           loc.y,
+			Description: loc.description,
+			Visited:     loc.visited,
+		})
+	}
+
+	for _, exit := range m.exits {
+		update.Exits = append(update.Exits, &jasonsgame.MapExit{
+			From:    exit.from,
+			To:      exit.to,
+			Command: exit.command,
+		})
+	}
+	return update
+}
+
+// locationExits finds where the interactions of a location lead. Only exits
+// that always lead to the same place count, so ciphers, conditions and
+// random outcomes are left off of the map.
+func locationExits(net network.Network, homeDid string, location *LocationTree) ([]*worldMapExit, error) {
+	interactions, err := location.InteractionsList()
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching interactions")
+	}
+
+	var exits []*worldMapExit
+	for _, interaction := range interactions {
+		to, err := exitDestination(net, homeDid, interaction)
+		if err != nil {
+			return nil, err
+		}
+		if to != "" {
+			exits = append(exits, &worldMapExit{
+				from:    location.MustId(),
+				to:      to,
+				command: interaction.GetCommand(),
+			})
+		}
+	}
+
+	portal, err := location.GetPortal()
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching portal")
+	}
+	if portal != nil && portal.To != "" {
+		exits = append(exits, &worldMapExit{
+			from:    location.MustId(),
+			to:      portal.To,
+			command: portalCommand,
+		})
+	}
+
+	return exits, nil
+}
+
+func exitDestination(net network.Network, homeDid string, interaction Interaction) (string, error) {
+	switch interaction := interaction.(type) {
+	case *ChangeLocationInteraction:
+		return interaction.Did, nil
+	case *ChangeNamedLocationInteraction:
+		switch interaction.Name {
+		case "home":
+			return homeDid, nil
+		case "last-location":
+			return "", nil
+		default:
+			return static.Get(net, interaction.Name)
+		}
+	case *ChainedInteraction:
+		chained, err := interaction.Interactions()
+		if err != nil {
+			return "", err
+		}
+		for _, next := range chained {
+			to, err := exitDestination(net, homeDid, next)
+			if err != nil || to != "" {
+				return to, err
+			}
+		}
+	}
+	return "", nil
+}
+
+// visitedLocations is every location in the player's journal, sorted by did
+func (g *Game) visitedLocations() ([]string, error) {
+	journal, err := g.playerTree.Journal()
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching journal")
+	}
+
+	dids := make([]string, 0, len(journal))
+	for did := range journal {
+		dids = append(dids, did)
+	}
+	sort.Strings(dids)
+	return dids, nil
+}
+
+// buildWorldMap fetches every visited location, which gets slow as the
+// journal grows, so moving around updates the map with updateWorldMap instead
+func (g *Game) buildWorldMap() (*worldMap, error) {
+	visited, err := g.visitedLocations()
+	if err != nil {
+		return nil, err
+	}
+
+	homeDid := g.playerTree.HomeLocation.MustId()
+	m := newWorldMap()
+
+	for _, did := range visited {
+		tree, err := g.network.GetTree(did)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("error fetching location %s", did))
+		}
+		if tree == nil {
+			// the location has been deleted since it was visited
+			continue
+		}
+		location := NewLocationTree(g.network, tree)
+
+		description, err := location.GetDescription()
+		if err != nil {
+			return nil, errors.Wrap(err, "error fetching description")
+		}
+		m.addVisited(did, description)
+
+		exits, err := locationExits(g.network, homeDid, location)
+		if err != nil {
+			return nil, err
+		}
+		for _, exit := range exits {
+			m.addExit(exit)
+		}
+	}
+
+	m.layout(homeDid, g.locationDid)
+	g.worldMap = m
+	return m, nil
+}
+
+// updateWorldMap refreshes only the current location on the map, building
+// the whole map the first time it is needed
+func (g *Game) updateWorldMap() (*worldMap, error) {
+	if g.worldMap == nil {
+		return g.buildWorldMap()
+	}
+
+	location, err := g.currentLocationTree()
+	if err != nil {
+		return nil, err
+	}
+	description, err := location.GetDescription()
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching description")
+	}
+	homeDid := g.playerTree.HomeLocation.MustId()
+	exits, err := locationExits(g.network, homeDid, location)
+	if err != nil {
+		return nil, err
+	}
+
+	g.worldMap.replaceLocation(g.locationDid, description, exits)
+	g.worldMap.layout(homeDid, g.locationDid)
+	return g.worldMap, nil
+}
+
+func (g *Game) handleMap(actorCtx actor.Context) error {
+	m, err := g.buildWorldMap()
+	if err != nil {
+		return err
+	}
+
+	g.sendUserMessage(actorCtx, m.render(g.locationDid, worldMapRadius))
+	actorCtx.Send(g.ui, m.toUpdate(g.locationDid))
+	return nil
+}
+
+// sendMapUpdate streams the map to the UI, which can draw it alongside the game
+func (g *Game) sendMapUpdate(actorCtx actor.Context) {
+	m, err := g.updateWorldMap()
+	if err != nil {
+		log.Warningf("error updating map: %v", err)
+		return
+	}
+	actorCtx.Send(g.ui, m.toUpdate(g.locationDid))
+}
+
+// fillMapCoordinates sets x and y on the location from the last map built
+func (g *Game) fillMapCoordinates(location *jasonsgame.Location) {
+	if g.worldMap == nil || location == nil {
+		return
+	}
+	if loc, ok := g.worldMap.locations[location.Did]; ok && loc.placed {
+		location.X = loc.x
+		location.Y = loc.y
+	}
+}
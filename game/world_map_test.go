@@ -0,0 +1,116 @@
+package game
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExitDirection(t *testing.T) {
+	offset, ok := exitDirection("go north")
+	require.True(t, ok)
+	require.Equal(t, gridOffset{0, 1}, offset)
+
+	offset, ok = exitDirection("sw")
+	require.True(t, ok)
+	require.Equal(t, gridOffset{-1, -1}, offset)
+
+	_, ok = exitDirection("enter the forest")
+	require.False(t, ok)
+}
+
+func TestWorldMapLayout(t *testing.T) {
+	m := newWorldMap()
+	m.addVisited("home", "home sweet home")
+	m.addVisited("field", "a field")
+	m.addExit(&worldMapExit{from: "home", to: "field", command: "go north"})
+	m.addExit(&worldMapExit{from: "field", to: "home", command: "go south"})
+	m.addExit(&worldMapExit{from: "field", to: "forest", command: "enter the forest"})
+	m.addExit(&worldMapExit{from: "field", to: "cave", command: "east"})
+
+	m.layout("home")
+
+	require.Equal(t, int64(0), m.locations["home"].x)
+	require.Equal(t, int64(0), m.locations["home"].y)
+	require.Equal(t, int64(0), m.locations["field"].x)
+	require.Equal(t, int64(1), m.locations["field"].y)
+	require.Equal(t, int64(1), m.locations["cave"].x)
+	require.Equal(t, int64(1), m.locations["cave"].y)
+
+	// the forest has no direction, so takes the first free neighbour
+	forest := m.locations["forest"]
+	require.True(t, forest.placed)
+	require.NotEqual(t, gridOffset{1, 1}, gridOffset{forest.x, forest.y})
+	require.False(t, forest.visited)
+
+	rendered := strings.Join(m.render("field", 1), "\n")
+	require.Contains(t, rendered, "[@]-[?]")
+	require.Contains(t, rendered, " |")
+	require.Contains(t, rendered, "[ ]")
+
+	update := m.toUpdate("field")
+	require.Equal(t, "field", update.Current)
+	require.Len(t, update.Locations, 4)
+	require.Len(t, update.Exits, 4)
+}
+
+func TestWorldMapReplaceLocation(t *testing.T) {
+	m := newWorldMap()
+	m.addVisited("home", "home sweet home")
+	m.addExit(&worldMapExit{from: "home", to: "field", command: "go north"})
+	m.addExit(&worldMapExit{from: "home", to: "cave", command: "climb down"})
+	m.layout("home")
+
+	m.replaceLocation("field", "a field", []*worldMapExit{
+		{from: "field", to: "home", command: "go south"},
+	})
+	m.replaceLocation("home", "home sweet home", []*worldMapExit{
+		{from: "home", to: "field", command: "go north"},
+	})
+	m.layout("home")
+
+	require.True(t, m.locations["field"].visited)
+	require.Equal(t, int64(1), m.locations["field"].y)
+	require.NotContains(t, m.locations, "cave")
+	require.Len(t, m.exits, 2)
+}
+
+func TestWorldMapLayoutDisconnected(t *testing.T) {
+	m := newWorldMap()
+	m.addVisited("home", "")
+	m.addVisited("island", "")
+
+	m.layout("home")
+
+	require.Equal(t, int64(0), m.locations["home"].x)
+	require.Equal(t, int64(2), m.locations["island"].x)
+	require.Equal(t, []string{"you haven't explored anywhere yet"}, m.render("nowhere", 1))
+}
+
+func TestWorldMapShortestRoute(t *testing.T) {
+	m := newWorldMap()
+	m.addVisited("home", "home sweet home")
+	m.addVisited("field", "a windy field")
+	m.addVisited("forest", "a dark forest")
+	m.addExit(&worldMapExit{from: "home", to: "field", command: "go north"})
+	m.addExit(&worldMapExit{from: "field", to: "forest", command: "enter the forest"})
+	m.addExit(&worldMapExit{from: "forest", to: "home", command: "take the shortcut"})
+	m.addExit(&worldMapExit{from: "field", to: "cave", command: "climb down"})
+
+	route := m.shortestRoute("home", "forest")
+	require.Len(t, route, 2)
+	require.Equal(t, "go north", route[0].command)
+	require.Equal(t, "enter the forest", route[1].command)
+
+	route = m.shortestRoute("forest", "home")
+	require.Len(t, route, 1)
+
+	// exits from the cave haven't been seen
+	require.Nil(t, m.shortestRoute("cave", "home"))
+
+	require.Equal(t, []string{"forest"}, m.matchPlaces("the Forest", "home"))
+	require.Equal(t, []string{"cave"}, m.matchPlaces("down", "home"))
+	require.Equal(t, []string{"home"}, m.matchPlaces("home", "home"))
+	require.Empty(t, m.matchPlaces("castle", "home"))
+}
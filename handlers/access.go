@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/quorumcontrol/jasons-game/pb/jasonsgame"
+)
+
+// AccessResponseSigningHash is what a location's handler signs to answer
+// an AccessRequest
+func AccessResponseSigningHash(msg *jasonsgame.AccessResponse) []byte {
+	return crypto.Keccak256([]byte(fmt.Sprintf("%s/%s/%s/%t/%s", msg.Location, msg.Player, msg.Nonce, msg.Allowed, msg.Reason)))
+}
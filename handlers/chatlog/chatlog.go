@@ -0,0 +1,165 @@
+package chatlog
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pkg/errors"
+	"github.com/quorumcontrol/jasons-game/handlers"
+	"github.com/quorumcontrol/jasons-game/network"
+	"github.com/quorumcontrol/jasons-game/pb/jasonsgame"
+)
+
+// EnabledPath is where, in a location's game data, it says whether its chat
+// is logged
+const EnabledPath = "chat-log"
+
+var enabledTreePath = []string{"tree", "data", "jasons-game", EnabledPath}
+
+// MaxLogs is how many locations the handler keeps logs for, the logs of
+// those least recently chatted in are dropped first
+const MaxLogs = 1000
+
+// MaxMessageLength is the longest message the handler will log
+const MaxMessageLength = 1000
+
+// ChatLogHandler keeps the chat of the locations it handles, so players can
+// catch up on it even when nobody else is around. Only recent messages
+// signed by their sender, in locations that have logging enabled, are kept.
+// Messages are stored as they were signed, and verified again by the
+// players reading them.
+type ChatLogHandler struct {
+	network network.Network
+	size    int
+	maxLogs int
+	lock    sync.Mutex
+	logs    map[string]*Log
+	// recent are the locations with logs, least recently chatted in first
+	recent []string
+}
+
+var ChatLogHandlerMessages = handlers.HandlerMessageList{
+	proto.MessageName((*jasonsgame.ChatMessage)(nil)),
+	proto.MessageName((*jasonsgame.ChatLogRequest)(nil)),
+}
+
+func NewChatLogHandler(network network.Network) *ChatLogHandler {
+	return &ChatLogHandler{
+		network: network,
+		size:    DefaultSize,
+		maxLogs: MaxLogs,
+		logs:    make(map[string]*Log),
+	}
+}
+
+func (h *ChatLogHandler) Handle(msg proto.Message) error {
+	switch msg := msg.(type) {
+	case *jasonsgame.ChatMessage:
+		err := h.verify(msg)
+		if err != nil {
+			return err
+		}
+
+		h.lock.Lock()
+		defer h.lock.Unlock()
+		h.logFor(msg.Location).Add(msg)
+		return nil
+	case *jasonsgame.ChatLogRequest:
+		h.lock.Lock()
+		log, ok := h.logs[msg.Location]
+		var messages []*jasonsgame.ChatMessage
+		if ok {
+			messages = log.Last(0)
+		}
+		h.lock.Unlock()
+
+		if len(messages) == 0 {
+			return nil
+		}
+
+		return h.network.Community().Send(h.network.Community().TopicFor(msg.ReplyTo), &jasonsgame.ChatLogResponse{
+			Location: msg.Location,
+			To:       msg.From,
+			Messages: messages,
+		})
+	default:
+		return handlers.ErrUnsupportedMessageType
+	}
+}
+
+func (h *ChatLogHandler) Supports(msg proto.Message) bool {
+	return ChatLogHandlerMessages.Contains(msg)
+}
+
+func (h *ChatLogHandler) SupportedMessages() []string {
+	return ChatLogHandlerMessages
+}
+
+// logFor returns the location's log, creating it and dropping the least
+// recently used log if there are too many. Callers must hold the lock.
+func (h *ChatLogHandler) logFor(location string) *Log {
+	for idx, recent := range h.recent {
+		if recent == location {
+			h.recent = append(h.recent[:idx], h.recent[idx+1:]...)
+			break
+		}
+	}
+	h.recent = append(h.recent, location)
+
+	log, ok := h.logs[location]
+	if !ok {
+		log = NewLog(h.size)
+		h.logs[location] = log
+	}
+
+	for len(h.recent) > h.maxLogs {
+		delete(h.logs, h.recent[0])
+		h.recent = h.recent[1:]
+	}
+	return log
+}
+
+// verify only lets through messages sent recently, signed by an owner of
+// the tree they're from, to a location that logs its chat
+func (h *ChatLogHandler) verify(msg *jasonsgame.ChatMessage) error {
+	if len(msg.Message) > MaxMessageLength {
+		return fmt.Errorf("chat message is longer than %d", MaxMessageLength)
+	}
+	if !Recent(msg, time.Now()) {
+		return fmt.Errorf("chat message wasn't sent recently")
+	}
+
+	sender, err := h.network.GetTree(msg.From)
+	if err != nil {
+		return errors.Wrap(err, "error fetching sender")
+	}
+	if sender == nil {
+		return fmt.Errorf("sender %s not found", msg.From)
+	}
+	auths, err := sender.Authentications()
+	if err != nil {
+		return errors.Wrap(err, "error fetching sender auths")
+	}
+	if !SignedBy(msg, auths) {
+		return fmt.Errorf("chat message isn't signed by %s", msg.From)
+	}
+
+	location, err := h.network.GetTree(msg.Location)
+	if err != nil {
+		return errors.Wrap(err, "error fetching location")
+	}
+	if location == nil {
+		return fmt.Errorf("location %s not found", msg.Location)
+	}
+	val, _, err := location.ChainTree.Dag.Resolve(context.Background(), enabledTreePath)
+	if err != nil {
+		return errors.Wrap(err, "error fetching chat log setting")
+	}
+	if enabled, ok := val.(bool); !ok || !enabled {
+		return fmt.Errorf("chat isn't logged at %s", msg.Location)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package chatlog
+
+import (
+	"testing"
+
+	"github.com/quorumcontrol/jasons-game/network"
+	"github.com/quorumcontrol/jasons-game/pb/jasonsgame"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLog(t *testing.T) {
+	log := NewLog(2)
+
+	first := &jasonsgame.ChatMessage{Message: "first", SentAt: 1, Signature: []byte("first")}
+	second := &jasonsgame.ChatMessage{Message: "second", SentAt: 2, Signature: []byte("second")}
+	third := &jasonsgame.ChatMessage{Message: "third", SentAt: 3, Signature: []byte("third")}
+
+	// kept in the order they arrive, whatever they say they were sent at
+	require.True(t, log.Add(third))
+	require.True(t, log.Add(first))
+	require.False(t, log.Add(first))
+	require.Equal(t, []*jasonsgame.ChatMessage{third, first}, log.Last(0))
+
+	require.True(t, log.Add(second))
+	require.Equal(t, 2, log.Len())
+	require.Equal(t, []*jasonsgame.ChatMessage{first, second}, log.Last(0))
+	require.Equal(t, []*jasonsgame.ChatMessage{second}, log.Last(1))
+
+	// dropped messages can be logged again
+	require.True(t, log.Add(third))
+}
+
+func TestChatLogHandlerMaxLogs(t *testing.T) {
+	h := NewChatLogHandler(network.NewLocalNetwork())
+	h.maxLogs = 2
+
+	h.logFor("first")
+	h.logFor("second")
+	h.logFor("first")
+	h.logFor("third")
+
+	require.Len(t, h.logs, 2)
+	require.Contains(t, h.logs, "first")
+	require.Contains(t, h.logs, "third")
+}
@@ -0,0 +1,75 @@
+package chatlog_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/quorumcontrol/jasons-game/game"
+	"github.com/quorumcontrol/jasons-game/handlers/chatlog"
+	"github.com/quorumcontrol/jasons-game/network"
+	"github.com/quorumcontrol/jasons-game/pb/jasonsgame"
+	messages "github.com/quorumcontrol/messages/build/go/community"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatLogHandler(t *testing.T) {
+	net := network.NewLocalNetwork()
+	h := chatlog.NewChatLogHandler(net)
+
+	sender, err := net.CreateChainTree()
+	require.Nil(t, err)
+	location, err := net.CreateChainTree()
+	require.Nil(t, err)
+
+	chatMessage := &jasonsgame.ChatMessage{
+		From:     sender.MustId(),
+		Message:  "it works",
+		Location: location.MustId(),
+		SentAt:   time.Now().Unix(),
+	}
+	require.Nil(t, chatlog.Sign(chatMessage, net.PrivateKey()))
+	require.True(t, h.Supports(chatMessage))
+
+	// only locations that have turned logging on are logged
+	require.NotNil(t, h.Handle(chatMessage))
+	require.Nil(t, game.NewLocationTree(net, location).SetChatLogEnabled(true))
+	require.Nil(t, h.Handle(chatMessage))
+
+	forged := proto.Clone(chatMessage).(*jasonsgame.ChatMessage)
+	forged.Message = "it's forged"
+	require.NotNil(t, h.Handle(forged))
+
+	stale := &jasonsgame.ChatMessage{
+		From:     sender.MustId(),
+		Message:  "from long ago",
+		Location: location.MustId(),
+		SentAt:   time.Now().Add(-2 * chatlog.MaxAge).Unix(),
+	}
+	require.Nil(t, chatlog.Sign(stale, net.PrivateKey()))
+	require.NotNil(t, h.Handle(stale))
+
+	received := make(chan *jasonsgame.ChatLogResponse, 1)
+	_, err = net.Community().Subscribe(net.Community().TopicFor("reply-here"), func(ctx context.Context, _ *messages.Envelope, msg proto.Message) {
+		received <- msg.(*jasonsgame.ChatLogResponse)
+	})
+	require.Nil(t, err)
+
+	request := &jasonsgame.ChatLogRequest{
+		Location: location.MustId(),
+		From:     "did:tupelo:player",
+		ReplyTo:  "reply-here",
+	}
+	require.True(t, h.Supports(request))
+	require.Nil(t, h.Handle(request))
+
+	select {
+	case msg := <-received:
+		require.Equal(t, "did:tupelo:player", msg.To)
+		require.Len(t, msg.Messages, 1)
+		require.Equal(t, chatMessage.Message, msg.Messages[0].Message)
+	case <-time.After(1 * time.Second):
+		require.Fail(t, "timeout waiting for chat log")
+	}
+}
@@ -0,0 +1,55 @@
+package chatlog
+
+import (
+	"github.com/quorumcontrol/jasons-game/pb/jasonsgame"
+)
+
+// DefaultSize is how many messages a location's log keeps
+const DefaultSize = 50
+
+// Log keeps the most recent chat messages in a location, oldest first
+type Log struct {
+	size     int
+	messages []*jasonsgame.ChatMessage
+	// logged are the signing hashes of the messages, so each is only kept once
+	logged map[string]bool
+}
+
+func NewLog(size int) *Log {
+	return &Log{size: size, logged: make(map[string]bool)}
+}
+
+// Add logs the message, returning false if it was already logged. Messages
+// are kept in the order they arrived rather than by when they say they were
+// sent, since senders pick that themselves, and the oldest are dropped once
+// the log is full.
+func (l *Log) Add(msg *jasonsgame.ChatMessage) bool {
+	hash, err := SigningHash(msg)
+	if err != nil || l.logged[string(hash)] {
+		return false
+	}
+	l.logged[string(hash)] = true
+	l.messages = append(l.messages, msg)
+
+	for len(l.messages) > l.size {
+		if dropped, err := SigningHash(l.messages[0]); err == nil {
+			delete(l.logged, string(dropped))
+		}
+		l.messages = l.messages[1:]
+	}
+	return true
+}
+
+// Last returns up to the last n messages, oldest first
+func (l *Log) Last(n int) []*jasonsgame.ChatMessage {
+	if n <= 0 || n > len(l.messages) {
+		n = len(l.messages)
+	}
+	last := make([]*jasonsgame.ChatMessage, n)
+	copy(last, l.messages[len(l.messages)-n:])
+	return last
+}
+
+func (l *Log) Len() int {
+	return len(l.messages)
+}
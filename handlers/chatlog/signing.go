@@ -0,0 +1,102 @@
+package chatlog
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gogo/protobuf/proto"
+	"github.com/pkg/errors"
+	"github.com/quorumcontrol/jasons-game/pb/jasonsgame"
+	"github.com/quorumcontrol/jasons-game/utils/stringslice"
+)
+
+// MaxAge is how far a message's sent at can be from now before it's
+// ignored, so old signed messages can't be replayed
+const MaxAge = 2 * time.Minute
+
+// Signed is any chat or presence message, signed by an owner of the tree
+// it's from
+type Signed interface {
+	proto.Message
+	GetFrom() string
+	GetSignature() []byte
+	GetSentAt() int64
+}
+
+// SigningHash is the hash of the message without its signature
+func SigningHash(msg proto.Message) ([]byte, error) {
+	unsigned := proto.Clone(msg)
+	switch m := unsigned.(type) {
+	case *jasonsgame.ChatMessage:
+		m.Signature = nil
+	case *jasonsgame.ShoutMessage:
+		m.Signature = nil
+	case *jasonsgame.WhisperMessage:
+		m.Signature = nil
+	case *jasonsgame.PresenceMessage:
+		m.Signature = nil
+	default:
+		return nil, fmt.Errorf("can't sign messages of type %T", msg)
+	}
+
+	bits, err := proto.Marshal(unsigned)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling chat message")
+	}
+	return crypto.Keccak256(bits), nil
+}
+
+func Sign(msg proto.Message, key *ecdsa.PrivateKey) error {
+	hash, err := SigningHash(msg)
+	if err != nil {
+		return err
+	}
+
+	signature, err := crypto.Sign(hash, key)
+	if err != nil {
+		return errors.Wrap(err, "error signing chat message")
+	}
+
+	switch m := msg.(type) {
+	case *jasonsgame.ChatMessage:
+		m.Signature = signature
+	case *jasonsgame.ShoutMessage:
+		m.Signature = signature
+	case *jasonsgame.WhisperMessage:
+		m.Signature = signature
+	case *jasonsgame.PresenceMessage:
+		m.Signature = signature
+	}
+	return nil
+}
+
+// Signer returns the address of the key that signed the message
+func Signer(msg Signed) (string, error) {
+	hash, err := SigningHash(msg)
+	if err != nil {
+		return "", err
+	}
+
+	pubKey, err := crypto.SigToPub(hash, msg.GetSignature())
+	if err != nil {
+		return "", errors.Wrap(err, "error recovering signer")
+	}
+	return crypto.PubkeyToAddress(*pubKey).String(), nil
+}
+
+// SignedBy is true when the message was signed by one of auths
+func SignedBy(msg Signed, auths []string) bool {
+	signer, err := Signer(msg)
+	if err != nil {
+		return false
+	}
+	return stringslice.Include(auths, signer)
+}
+
+// Recent is true when the message was sent within MaxAge of now
+func Recent(msg Signed, now time.Time) bool {
+	sentAt := time.Unix(msg.GetSentAt(), 0)
+	return now.Sub(sentAt) <= MaxAge && sentAt.Sub(now) <= MaxAge
+}
@@ -0,0 +1,30 @@
+package chatlog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quorumcontrol/jasons-game/pb/jasonsgame"
+)
+
+func TestSign(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.Nil(t, err)
+
+	msg := &jasonsgame.ChatMessage{From: "did:tupelo:jason", Name: "jason", Message: "hi", SentAt: time.Now().Unix()}
+	require.Nil(t, Sign(msg, key))
+	require.NotEmpty(t, msg.Signature)
+
+	signer, err := Signer(msg)
+	require.Nil(t, err)
+	require.Equal(t, crypto.PubkeyToAddress(key.PublicKey).String(), signer)
+	require.True(t, SignedBy(msg, []string{signer}))
+	require.True(t, Recent(msg, time.Now()))
+	require.False(t, Recent(msg, time.Now().Add(2*MaxAge)))
+
+	msg.Message = "bye"
+	require.False(t, SignedBy(msg, []string{signer}))
+}
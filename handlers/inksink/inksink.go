@@ -0,0 +1,291 @@
+package inksink
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gogo/protobuf/proto"
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+	"github.com/quorumcontrol/messages/build/go/transactions"
+	"github.com/quorumcontrol/tupelo-go-sdk/consensus"
+
+	"github.com/quorumcontrol/jasons-game/handlers"
+	"github.com/quorumcontrol/jasons-game/network"
+	"github.com/quorumcontrol/jasons-game/pb/jasonsgame"
+)
+
+// RefundWindow is how long after a payment it can be given back
+const RefundWindow = 5 * time.Minute
+
+// payment is ink the sink has received, and what it was for
+type payment struct {
+	from       string
+	amount     uint64
+	location   string
+	tip        cid.Cid
+	receivedAt time.Time
+	refunded   bool
+}
+
+// InkSinkHandler receives the ink players pay for building into the sink,
+// which its network's key owns, and gives it back for builds that didn't
+// happen. A payment is only given back once, to whoever paid it, within the
+// RefundWindow and while the location it paid for hasn't changed.
+type InkSinkHandler struct {
+	network   network.Network
+	did       string
+	tokenName *consensus.TokenName
+	lock      sync.Mutex
+	payments  map[string]*payment
+}
+
+var InkSinkHandlerMessages = handlers.HandlerMessageList{
+	proto.MessageName((*jasonsgame.InkPayment)(nil)),
+	proto.MessageName((*jasonsgame.InkRefundRequest)(nil)),
+}
+
+func NewInkSinkHandler(network network.Network, tokenName *consensus.TokenName) (*InkSinkHandler, error) {
+	tree, err := network.FindOrCreatePassphraseTree("ink-sink")
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching ink sink tree")
+	}
+	return NewInkSinkHandlerFor(network, tree.MustId(), tokenName), nil
+}
+
+// NewInkSinkHandlerFor handles the sink with did, which must be owned by the
+// network's key
+func NewInkSinkHandlerFor(network network.Network, did string, tokenName *consensus.TokenName) *InkSinkHandler {
+	return &InkSinkHandler{
+		network:   network,
+		did:       did,
+		tokenName: tokenName,
+		payments:  make(map[string]*payment),
+	}
+}
+
+// ReplyTopicFor is where the sink answers the player with did
+func ReplyTopicFor(did string) string {
+	return did + "/ink-sink"
+}
+
+// PaymentSigningHash is what's signed to hand over a payment
+func PaymentSigningHash(from string, id string, location string) []byte {
+	return crypto.Keccak256([]byte("payment/" + from + "/" + id + "/" + location))
+}
+
+// RefundSigningHash is what's signed to ask for a payment back
+func RefundSigningHash(from string, id string) []byte {
+	return crypto.Keccak256([]byte("refund/" + from + "/" + id))
+}
+
+// Did is the sink's tree, where players send ink
+func (h *InkSinkHandler) Did() string {
+	return h.did
+}
+
+// SetHandler points the sink at the service with did, so players can find
+// this handler from the sink
+func (h *InkSinkHandler) SetHandler(did string) error {
+	sink, err := h.sinkTree()
+	if err != nil {
+		return err
+	}
+	_, err = h.network.UpdateChainTree(sink, handlers.HandlerPath, did)
+	if err != nil {
+		return errors.Wrap(err, "error setting ink sink handler")
+	}
+	return nil
+}
+
+func (h *InkSinkHandler) Handle(msg proto.Message) error {
+	switch msg := msg.(type) {
+	case *jasonsgame.InkPayment:
+		err := h.receive(msg)
+		sendErr := h.respond(msg.From, &jasonsgame.InkPaymentResponse{
+			From:  msg.From,
+			Id:    msg.Id,
+			Error: errorString(err),
+		})
+		if err != nil {
+			return err
+		}
+		return sendErr
+	case *jasonsgame.InkRefundRequest:
+		token, err := h.refund(msg)
+		sendErr := h.respond(msg.From, &jasonsgame.InkRefundResponse{
+			From:  msg.From,
+			Id:    msg.Id,
+			Token: token,
+			Error: errorString(err),
+		})
+		if err != nil {
+			return err
+		}
+		return sendErr
+	default:
+		return handlers.ErrUnsupportedMessageType
+	}
+}
+
+func (h *InkSinkHandler) Supports(msg proto.Message) bool {
+	return InkSinkHandlerMessages.Contains(msg)
+}
+
+func (h *InkSinkHandler) SupportedMessages() []string {
+	return InkSinkHandlerMessages
+}
+
+func (h *InkSinkHandler) respond(did string, msg proto.Message) error {
+	if did == "" {
+		return nil
+	}
+	return h.network.Community().Send(h.network.Community().TopicFor(ReplyTopicFor(did)), msg)
+}
+
+// receive takes the payment into the sink, remembering how much it was by
+// how much the sink's balance went up
+func (h *InkSinkHandler) receive(msg *jasonsgame.InkPayment) error {
+	if msg.From == "" || msg.Id == "" || msg.Location == "" || len(msg.Token) == 0 {
+		return fmt.Errorf("payments must have a payer, id, location and token")
+	}
+	err := handlers.VerifySigner(h.network, msg.From, PaymentSigningHash(msg.From, msg.Id, msg.Location), msg.Signature)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("payment isn't signed by %s", msg.From))
+	}
+
+	tokenPayload := &transactions.TokenPayload{}
+	err = proto.Unmarshal(msg.Token, tokenPayload)
+	if err != nil {
+		return errors.Wrap(err, "error unmarshaling token payload")
+	}
+	if tokenPayload.TransactionId != msg.Id {
+		return fmt.Errorf("payment id %s doesn't match its token", msg.Id)
+	}
+
+	location, err := h.network.GetTree(msg.Location)
+	if err != nil {
+		return errors.Wrap(err, "error fetching location")
+	}
+	if location == nil {
+		return fmt.Errorf("location %s not found", msg.Location)
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.forgetExpired(time.Now())
+
+	if _, ok := h.payments[msg.Id]; ok {
+		return fmt.Errorf("payment %s has already been received", msg.Id)
+	}
+
+	sink, err := h.sinkTree()
+	if err != nil {
+		return err
+	}
+	before, err := h.network.InkBalance(sink, h.tokenName)
+	if err != nil {
+		return errors.Wrap(err, "error fetching sink balance")
+	}
+
+	err = h.network.ReceiveInk(sink, tokenPayload)
+	if err != nil {
+		return errors.Wrap(err, "error receiving ink")
+	}
+
+	sink, err = h.sinkTree()
+	if err != nil {
+		return err
+	}
+	after, err := h.network.InkBalance(sink, h.tokenName)
+	if err != nil {
+		return errors.Wrap(err, "error fetching sink balance")
+	}
+	if after <= before {
+		return fmt.Errorf("payment %s wasn't ink", msg.Id)
+	}
+
+	h.payments[msg.Id] = &payment{
+		from:       msg.From,
+		amount:     after - before,
+		location:   msg.Location,
+		tip:        location.Tip(),
+		receivedAt: time.Now(),
+	}
+	return nil
+}
+
+// refund sends a payment back to whoever paid it, returning the marshaled
+// TokenPayload for them to receive
+func (h *InkSinkHandler) refund(msg *jasonsgame.InkRefundRequest) ([]byte, error) {
+	err := handlers.VerifySigner(h.network, msg.From, RefundSigningHash(msg.From, msg.Id), msg.Signature)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("refund request isn't signed by %s", msg.From))
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.forgetExpired(time.Now())
+
+	paid, ok := h.payments[msg.Id]
+	if !ok || paid.from != msg.From {
+		return nil, fmt.Errorf("there's no payment %s from %s to give back", msg.Id, msg.From)
+	}
+	if paid.refunded {
+		return nil, fmt.Errorf("payment %s has already been given back", msg.Id)
+	}
+
+	location, err := h.network.GetTree(paid.location)
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching location")
+	}
+	if location == nil || !location.Tip().Equals(paid.tip) {
+		return nil, fmt.Errorf("%s has changed since payment %s", paid.location, msg.Id)
+	}
+
+	sink, err := h.sinkTree()
+	if err != nil {
+		return nil, err
+	}
+	tokenPayload, err := h.network.SendInk(sink, h.tokenName, paid.amount, paid.from)
+	if err != nil {
+		return nil, errors.Wrap(err, "error sending ink back")
+	}
+	paid.refunded = true
+
+	token, err := proto.Marshal(tokenPayload)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling token payload")
+	}
+	return token, nil
+}
+
+// forgetExpired drops payments that can't be refunded anymore. It must be
+// called with the lock held.
+func (h *InkSinkHandler) forgetExpired(now time.Time) {
+	for id, paid := range h.payments {
+		if now.Sub(paid.receivedAt) > RefundWindow {
+			delete(h.payments, id)
+		}
+	}
+}
+
+func (h *InkSinkHandler) sinkTree() (*consensus.SignedChainTree, error) {
+	sink, err := h.network.GetTree(h.did)
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching ink sink")
+	}
+	if sink == nil {
+		return nil, fmt.Errorf("ink sink %s not found", h.did)
+	}
+	return sink, nil
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
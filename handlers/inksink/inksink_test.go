@@ -0,0 +1,59 @@
+package inksink
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gogo/protobuf/proto"
+	"github.com/quorumcontrol/messages/build/go/transactions"
+	"github.com/quorumcontrol/tupelo-go-sdk/consensus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quorumcontrol/jasons-game/network"
+	"github.com/quorumcontrol/jasons-game/pb/jasonsgame"
+)
+
+func TestInkSinkHandler(t *testing.T) {
+	net := network.NewLocalNetwork()
+
+	h, err := NewInkSinkHandler(net, &consensus.TokenName{ChainTreeDID: "did:tupelo:ink", LocalName: "ink"})
+	require.Nil(t, err)
+
+	player, err := net.CreateChainTree()
+	require.Nil(t, err)
+	from := player.MustId()
+	location, err := net.CreateChainTree()
+	require.Nil(t, err)
+
+	token, err := proto.Marshal(&transactions.TokenPayload{TransactionId: "send-1"})
+	require.Nil(t, err)
+	msg := &jasonsgame.InkPayment{From: from, Id: "send-1", Token: token, Location: location.MustId()}
+	require.True(t, h.Supports(msg))
+
+	// payments have to be signed by the payer
+	require.NotNil(t, h.receive(msg))
+	otherKey, err := crypto.GenerateKey()
+	require.Nil(t, err)
+	msg.Signature, err = crypto.Sign(PaymentSigningHash(from, msg.Id, msg.Location), otherKey)
+	require.Nil(t, err)
+	require.NotNil(t, h.receive(msg))
+
+	// and their id has to be the token's
+	mismatched := &jasonsgame.InkPayment{From: from, Id: "send-2", Token: token, Location: location.MustId()}
+	mismatched.Signature, err = crypto.Sign(PaymentSigningHash(from, mismatched.Id, mismatched.Location), net.PrivateKey())
+	require.Nil(t, err)
+	require.NotNil(t, h.receive(mismatched))
+
+	// the local network doesn't move ink, so the sink's balance doesn't go up
+	msg.Signature, err = crypto.Sign(PaymentSigningHash(from, msg.Id, msg.Location), net.PrivateKey())
+	require.Nil(t, err)
+	err = h.receive(msg)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "wasn't ink")
+
+	// so there's nothing to give back
+	signature, err := crypto.Sign(RefundSigningHash(from, msg.Id), net.PrivateKey())
+	require.Nil(t, err)
+	_, err = h.refund(&jasonsgame.InkRefundRequest{From: from, Id: msg.Id, Signature: signature})
+	require.NotNil(t, err)
+}
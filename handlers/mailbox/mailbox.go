@@ -0,0 +1,277 @@
+package mailbox
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gogo/protobuf/proto"
+	"github.com/pkg/errors"
+	"github.com/quorumcontrol/jasons-game/handlers"
+	"github.com/quorumcontrol/jasons-game/network"
+	"github.com/quorumcontrol/jasons-game/pb/jasonsgame"
+	"github.com/quorumcontrol/tupelo-go-sdk/consensus"
+)
+
+const mailPath = "mail"
+
+// MaxMailPerSender is how many letters from one player can be waiting for
+// another in the mailbox
+const MaxMailPerSender = 20
+
+// MaxSendAge is how far mail's sent at can be from when it reaches the
+// mailbox. Deleted mail is remembered this long so it can't be sent again,
+// after that it would be refused as too old anyway.
+const MaxSendAge = 10 * time.Minute
+
+// MaxRequestAge is how far a mailbox request's sent at can be from when it's
+// handled, so old requests can't be replayed
+const MaxRequestAge = 2 * time.Minute
+
+// MailboxHandler holds mail for players until they delete it. Mail is
+// encrypted to its recipient, so the handler only knows who it's for, and
+// only sends it to them on their ReplyTopic when they ask.
+type MailboxHandler struct {
+	network network.Network
+	lock    sync.Mutex
+	tree    *consensus.SignedChainTree
+}
+
+var MailboxHandlerMessages = handlers.HandlerMessageList{
+	proto.MessageName((*jasonsgame.MailEncrypted)(nil)),
+	proto.MessageName((*jasonsgame.MailboxRequest)(nil)),
+	proto.MessageName((*jasonsgame.MailDeleteRequest)(nil)),
+}
+
+func NewMailboxHandler(network network.Network) (*MailboxHandler, error) {
+	tree, err := network.FindOrCreatePassphraseTree("mailbox")
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching mailbox tree")
+	}
+
+	return &MailboxHandler{
+		network: network,
+		tree:    tree,
+	}, nil
+}
+
+// MailId is how mail is stored and deleted
+func MailId(encrypted []byte) string {
+	idSha := sha256.Sum256(encrypted)
+	return hex.EncodeToString(idSha[:])
+}
+
+// ReplyTopic is where the mail to did is sent when they ask for it
+func ReplyTopic(did string) string {
+	return did + "/mail"
+}
+
+// SendSigningHash is what's signed to send mail
+func SendSigningHash(to string, id string, from string, sentAt int64) []byte {
+	return crypto.Keccak256([]byte(fmt.Sprintf("%s/%s/%s/%d", to, id, from, sentAt)))
+}
+
+// RequestSigningHash is what's signed to ask for mail
+func RequestSigningHash(to string, sentAt int64) []byte {
+	return crypto.Keccak256([]byte(fmt.Sprintf("%s/%d", to, sentAt)))
+}
+
+// DeleteSigningHash is what's signed to delete mail
+func DeleteSigningHash(to string, id string) []byte {
+	return crypto.Keccak256([]byte(to + "/" + id))
+}
+
+func (h *MailboxHandler) Handle(msg proto.Message) error {
+	switch msg := msg.(type) {
+	case *jasonsgame.MailEncrypted:
+		if msg.To == "" || msg.From == "" || len(msg.Encrypted) == 0 {
+			return fmt.Errorf("mail must have a sender, recipient and contents")
+		}
+		if msg.Id != MailId(msg.Encrypted) {
+			return fmt.Errorf("mail id %s doesn't match its contents", msg.Id)
+		}
+		if !within(msg.SentAt, time.Now(), MaxSendAge) {
+			return fmt.Errorf("mail %s was sent too long ago", msg.Id)
+		}
+		err := handlers.VerifySigner(h.network, msg.From, SendSigningHash(msg.To, msg.Id, msg.From, msg.SentAt), msg.Signature)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("mail isn't signed by %s", msg.From))
+		}
+		return h.store(msg)
+	case *jasonsgame.MailboxRequest:
+		if !within(msg.SentAt, time.Now(), MaxRequestAge) {
+			return fmt.Errorf("mail request for %s was sent too long ago", msg.To)
+		}
+		// only the recipient can ask for their mail
+		err := handlers.VerifySigner(h.network, msg.To, RequestSigningHash(msg.To, msg.SentAt), msg.Signature)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("only %s can ask for their mail", msg.To))
+		}
+		mail, err := h.mailFor(msg.To)
+		if err != nil {
+			return err
+		}
+		return h.network.Community().Send(h.network.Community().TopicFor(ReplyTopic(msg.To)), &jasonsgame.MailboxResponse{
+			To:   msg.To,
+			Mail: mail,
+		})
+	case *jasonsgame.MailDeleteRequest:
+		// only the recipient can delete their mail
+		err := handlers.VerifySigner(h.network, msg.To, DeleteSigningHash(msg.To, msg.Id), msg.Signature)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("only %s can delete their mail", msg.To))
+		}
+		return h.delete(msg.To, msg.Id)
+	default:
+		return handlers.ErrUnsupportedMessageType
+	}
+}
+
+func (h *MailboxHandler) Supports(msg proto.Message) bool {
+	return MailboxHandlerMessages.Contains(msg)
+}
+
+func (h *MailboxHandler) SupportedMessages() []string {
+	return MailboxHandlerMessages
+}
+
+// within is true when sentAt is no further than maxAge from now
+func within(sentAt int64, now time.Time, maxAge time.Duration) bool {
+	sent := time.Unix(sentAt, 0)
+	return now.Sub(sent) <= maxAge && sent.Sub(now) <= maxAge
+}
+
+// deleted is true for mail that has been deleted, which is kept without
+// its contents until it's older than MaxSendAge
+func deleted(mail *jasonsgame.MailEncrypted) bool {
+	return len(mail.Encrypted) == 0
+}
+
+// store keeps the mail unless its sender already has too much waiting for
+// the recipient. Mail that has been sent before is refused, even once it's
+// deleted, so it can't be sent again by whoever saw it.
+func (h *MailboxHandler) store(msg *jasonsgame.MailEncrypted) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	stored, err := h.storedMail(msg.To)
+	if err != nil {
+		return err
+	}
+	if _, ok := stored[msg.Id]; ok {
+		return fmt.Errorf("mail %s has already been sent", msg.Id)
+	}
+
+	fromSender := 0
+	for _, mail := range stored {
+		if !deleted(mail) && mail.From == msg.From {
+			fromSender++
+		}
+	}
+	if fromSender >= MaxMailPerSender {
+		return fmt.Errorf("%s already has %d letters waiting for %s", msg.From, fromSender, msg.To)
+	}
+
+	stored[msg.Id] = msg
+	return h.saveLocked(msg.To, stored)
+}
+
+// delete keeps just enough of the mail to refuse it if it's sent again
+func (h *MailboxHandler) delete(to string, id string) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	stored, err := h.storedMail(to)
+	if err != nil {
+		return err
+	}
+	mail, ok := stored[id]
+	if !ok || deleted(mail) {
+		return fmt.Errorf("%s has no mail %s", to, id)
+	}
+
+	stored[id] = &jasonsgame.MailEncrypted{Id: mail.Id, To: mail.To, From: mail.From, SentAt: mail.SentAt}
+	return h.saveLocked(to, stored)
+}
+
+// saveLocked replaces the recipient's mail with stored, leaving out deleted
+// mail that's too old to be sent again. It must be called with the lock held.
+func (h *MailboxHandler) saveLocked(to string, stored map[string]*jasonsgame.MailEncrypted) error {
+	now := time.Now()
+	val := make(map[string]interface{}, len(stored))
+	for id, mail := range stored {
+		if deleted(mail) && !within(mail.SentAt, now, MaxSendAge) {
+			continue
+		}
+		bits, err := proto.Marshal(mail)
+		if err != nil {
+			return errors.Wrap(err, "error marshaling mail")
+		}
+		val[id] = bits
+	}
+
+	newTree, err := h.network.UpdateChainTree(h.tree, strings.Join([]string{mailPath, to}, "/"), val)
+	if err != nil {
+		return errors.Wrap(err, "error updating mailbox")
+	}
+	h.tree = newTree
+	return nil
+}
+
+func (h *MailboxHandler) mailFor(to string) ([]*jasonsgame.MailEncrypted, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	stored, err := h.storedMail(to)
+	if err != nil {
+		return nil, err
+	}
+
+	mail := make([]*jasonsgame.MailEncrypted, 0, len(stored))
+	for _, encrypted := range stored {
+		if !deleted(encrypted) {
+			mail = append(mail, encrypted)
+		}
+	}
+	return mail, nil
+}
+
+// storedMail is the mail to the recipient by id, including what they've
+// deleted recently. It must be called with the lock held.
+func (h *MailboxHandler) storedMail(to string) (map[string]*jasonsgame.MailEncrypted, error) {
+	uncast, _, err := h.tree.ChainTree.Dag.Resolve(context.Background(), []string{"tree", "data", mailPath, to})
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching mail")
+	}
+	if uncast == nil {
+		return make(map[string]*jasonsgame.MailEncrypted), nil
+	}
+
+	stored, ok := uncast.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("error casting mail; type is %T", uncast)
+	}
+
+	mail := make(map[string]*jasonsgame.MailEncrypted, len(stored))
+	for id, uncastBits := range stored {
+		encrypted := &jasonsgame.MailEncrypted{Id: id}
+		// mail deleted before it was kept with its sent at is left as nil,
+		// and dropped the next time the mail is saved
+		bits, ok := uncastBits.([]byte)
+		if !ok || len(bits) == 0 {
+			mail[id] = encrypted
+			continue
+		}
+		err = proto.Unmarshal(bits, encrypted)
+		if err != nil {
+			return nil, errors.Wrap(err, "error unmarshaling mail")
+		}
+		mail[id] = encrypted
+	}
+	return mail, nil
+}
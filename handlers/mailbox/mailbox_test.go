@@ -0,0 +1,169 @@
+package mailbox
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gogo/protobuf/proto"
+	messages "github.com/quorumcontrol/messages/build/go/community"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quorumcontrol/jasons-game/network"
+	"github.com/quorumcontrol/jasons-game/pb/jasonsgame"
+)
+
+func TestMailboxHandler(t *testing.T) {
+	net := network.NewLocalNetwork()
+
+	h, err := NewMailboxHandler(net)
+	require.Nil(t, err)
+
+	recipient, err := net.CreateChainTree()
+	require.Nil(t, err)
+	to := recipient.MustId()
+
+	sender, err := net.CreateChainTree()
+	require.Nil(t, err)
+	from := sender.MustId()
+
+	encrypted := []byte("not really encrypted")
+	msg := &jasonsgame.MailEncrypted{Id: MailId(encrypted), To: to, Encrypted: encrypted, From: from, SentAt: time.Now().Unix()}
+	require.True(t, h.Supports(msg))
+
+	// mail has to be signed by its sender
+	require.NotNil(t, h.Handle(msg))
+	msg.Signature, err = crypto.Sign(SendSigningHash(to, msg.Id, from, msg.SentAt), net.PrivateKey())
+	require.Nil(t, err)
+	require.Nil(t, h.Handle(msg))
+
+	// and can only be sent once
+	require.NotNil(t, h.Handle(msg))
+
+	err = h.Handle(&jasonsgame.MailEncrypted{Id: "made-up", To: to, Encrypted: encrypted, From: from, Signature: msg.Signature, SentAt: msg.SentAt})
+	require.NotNil(t, err)
+
+	// or sent long ago
+	oldEncrypted := []byte("from long ago")
+	old := &jasonsgame.MailEncrypted{Id: MailId(oldEncrypted), To: to, Encrypted: oldEncrypted, From: from, SentAt: time.Now().Add(-2 * MaxSendAge).Unix()}
+	old.Signature, err = crypto.Sign(SendSigningHash(to, old.Id, from, old.SentAt), net.PrivateKey())
+	require.Nil(t, err)
+	require.NotNil(t, h.Handle(old))
+
+	mail, err := h.mailFor(to)
+	require.Nil(t, err)
+	require.Len(t, mail, 1)
+	require.Equal(t, encrypted, mail[0].Encrypted)
+
+	// only the recipient can delete their mail
+	otherKey, err := crypto.GenerateKey()
+	require.Nil(t, err)
+	signature, err := crypto.Sign(DeleteSigningHash(to, msg.Id), otherKey)
+	require.Nil(t, err)
+	err = h.Handle(&jasonsgame.MailDeleteRequest{To: to, Id: msg.Id, Signature: signature})
+	require.NotNil(t, err)
+
+	signature, err = crypto.Sign(DeleteSigningHash(to, msg.Id), net.PrivateKey())
+	require.Nil(t, err)
+	require.Nil(t, h.Handle(&jasonsgame.MailDeleteRequest{To: to, Id: msg.Id, Signature: signature}))
+
+	mail, err = h.mailFor(to)
+	require.Nil(t, err)
+	require.Len(t, mail, 0)
+
+	// deleted mail can't be sent again
+	require.NotNil(t, h.Handle(msg))
+	require.NotNil(t, h.Handle(&jasonsgame.MailDeleteRequest{To: to, Id: msg.Id, Signature: signature}))
+}
+
+func TestMailboxHandlerForgetsOldDeletedMail(t *testing.T) {
+	net := network.NewLocalNetwork()
+
+	h, err := NewMailboxHandler(net)
+	require.Nil(t, err)
+
+	to := "did:tupelo:recipient"
+	h.lock.Lock()
+	err = h.saveLocked(to, map[string]*jasonsgame.MailEncrypted{
+		"recent": {Id: "recent", To: to, SentAt: time.Now().Unix()},
+		"old":    {Id: "old", To: to, SentAt: time.Now().Add(-2 * MaxSendAge).Unix()},
+	})
+	require.Nil(t, err)
+	stored, err := h.storedMail(to)
+	h.lock.Unlock()
+	require.Nil(t, err)
+
+	require.Len(t, stored, 1)
+	require.Contains(t, stored, "recent")
+}
+
+func TestMailboxHandlerRequest(t *testing.T) {
+	net := network.NewLocalNetwork()
+
+	h, err := NewMailboxHandler(net)
+	require.Nil(t, err)
+
+	recipient, err := net.CreateChainTree()
+	require.Nil(t, err)
+	to := recipient.MustId()
+
+	received := make(chan *jasonsgame.MailboxResponse, 2)
+	_, err = net.Community().Subscribe(net.Community().TopicFor(ReplyTopic(to)), func(ctx context.Context, _ *messages.Envelope, msg proto.Message) {
+		received <- msg.(*jasonsgame.MailboxResponse)
+	})
+	require.Nil(t, err)
+
+	// only the recipient can ask for their mail
+	request := &jasonsgame.MailboxRequest{To: to, SentAt: time.Now().Unix()}
+	require.NotNil(t, h.Handle(request))
+	otherKey, err := crypto.GenerateKey()
+	require.Nil(t, err)
+	request.Signature, err = crypto.Sign(RequestSigningHash(to, request.SentAt), otherKey)
+	require.Nil(t, err)
+	require.NotNil(t, h.Handle(request))
+
+	// and not with an old request
+	old := &jasonsgame.MailboxRequest{To: to, SentAt: time.Now().Add(-2 * MaxRequestAge).Unix()}
+	old.Signature, err = crypto.Sign(RequestSigningHash(to, old.SentAt), net.PrivateKey())
+	require.Nil(t, err)
+	require.NotNil(t, h.Handle(old))
+
+	request.Signature, err = crypto.Sign(RequestSigningHash(to, request.SentAt), net.PrivateKey())
+	require.Nil(t, err)
+	require.Nil(t, h.Handle(request))
+
+	select {
+	case response := <-received:
+		require.Equal(t, to, response.To)
+		require.Len(t, response.Mail, 0)
+	case <-time.After(1 * time.Second):
+		require.Fail(t, "timeout waiting for mail")
+	}
+}
+
+func TestMailboxHandlerMaxMailPerSender(t *testing.T) {
+	net := network.NewLocalNetwork()
+
+	h, err := NewMailboxHandler(net)
+	require.Nil(t, err)
+
+	recipient, err := net.CreateChainTree()
+	require.Nil(t, err)
+	sender, err := net.CreateChainTree()
+	require.Nil(t, err)
+
+	send := func(idx int) error {
+		encrypted := []byte(fmt.Sprintf("letter %d", idx))
+		msg := &jasonsgame.MailEncrypted{Id: MailId(encrypted), To: recipient.MustId(), Encrypted: encrypted, From: sender.MustId(), SentAt: time.Now().Unix()}
+		msg.Signature, err = crypto.Sign(SendSigningHash(msg.To, msg.Id, msg.From, msg.SentAt), net.PrivateKey())
+		require.Nil(t, err)
+		return h.Handle(msg)
+	}
+
+	for i := 0; i < MaxMailPerSender; i++ {
+		require.Nil(t, send(i))
+	}
+	require.NotNil(t, send(MaxMailPerSender))
+}
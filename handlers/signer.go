@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+	"github.com/quorumcontrol/jasons-game/network"
+	"github.com/quorumcontrol/jasons-game/utils/stringslice"
+)
+
+// VerifySigner checks the hash was signed by one of the owners of the tree
+// with did
+func VerifySigner(net network.Network, did string, hash []byte, signature []byte) error {
+	pubKey, err := crypto.SigToPub(hash, signature)
+	if err != nil {
+		return errors.Wrap(err, "error recovering signer")
+	}
+
+	tree, err := net.GetTree(did)
+	if err != nil {
+		return errors.Wrap(err, "error fetching signer")
+	}
+	if tree == nil {
+		return fmt.Errorf("%s not found", did)
+	}
+
+	auths, err := tree.Authentications()
+	if err != nil {
+		return errors.Wrap(err, "error fetching signer auths")
+	}
+
+	if !stringslice.Include(auths, crypto.PubkeyToAddress(*pubKey).String()) {
+		return fmt.Errorf("signer isn't an owner of %s", did)
+	}
+	return nil
+}
@@ -11,6 +11,7 @@ import (
 	"sort"
 	"strings"
 	"text/template"
+	"time"
 	"unicode"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -35,6 +36,7 @@ type nameToDidMap map[string]string
 type NameToDids struct {
 	Locations nameToDidMap
 	Objects   nameToDidMap
+	Quests    nameToDidMap
 	Static    nameToDidMap
 }
 
@@ -54,9 +56,25 @@ type ImportObject struct {
 	Interactions []*ImportInteraction   `yaml:"interactions"`
 }
 
+type ImportObjective struct {
+	Description    string `yaml:"description"`
+	VisitLocation  string `yaml:"visit_location"`
+	HoldObject     string `yaml:"hold_object"`
+	UseInteraction string `yaml:"use_interaction"`
+	ReceivePrize   string `yaml:"receive_prize"`
+}
+
+type ImportQuest struct {
+	Name              string             `yaml:"name"`
+	Description       string             `yaml:"description"`
+	CompletionMessage string             `yaml:"completion_message"`
+	Objectives        []*ImportObjective `yaml:"objectives"`
+}
+
 type ImportPayload struct {
 	Locations map[string]*ImportLocation `yaml:"locations"`
 	Objects   map[string]*ImportObject   `yaml:"objects"`
+	Quests    map[string]*ImportQuest    `yaml:"quests"`
 }
 
 type Importer struct {
@@ -78,6 +96,7 @@ func (i *Importer) createTrees(data *ImportPayload) (*NameToDids, error) {
 	ids := &NameToDids{
 		Locations: make(nameToDidMap),
 		Objects:   make(nameToDidMap),
+		Quests:    make(nameToDidMap),
 		Static:    staticVals,
 	}
 
@@ -99,6 +118,15 @@ func (i *Importer) createTrees(data *ImportPayload) (*NameToDids, error) {
 		log.Infof("%s: Created chaintree for objects.%s", tree.MustId(), key)
 	}
 
+	for key := range data.Quests {
+		tree, err := i.network.FindOrCreatePassphraseTree("quests/" + key)
+		if err != nil {
+			return nil, err
+		}
+		ids.Quests[key] = tree.MustId()
+		log.Infof("%s: Created chaintree for quests.%s", tree.MustId(), key)
+	}
+
 	return ids, nil
 }
 
@@ -231,10 +259,56 @@ func (i *Importer) convertImportInteraction(attrs *ImportInteraction) (game.Inte
 			return interaction, err
 		}
 
-		interaction, err = game.NewCipherInteraction(command, secret, successInteraction, failureInteraction)
+		cipherInteraction, err := game.NewCipherInteraction(command, secret, successInteraction, failureInteraction)
 		if err != nil {
 			return interaction, errors.Wrap(err, "error creating CipherInteraction")
 		}
+
+		cipherInteraction.Limit, err = convertImportCipherLimit(attrs.Value)
+		if err != nil {
+			return interaction, errors.Wrap(err, "CipherInteraction")
+		}
+		interaction = cipherInteraction
+	case "MultiPartCipherInteraction":
+		command, ok := attrs.Value["command"].(string)
+		if !ok {
+			return interaction, fmt.Errorf("MultiPartCipherInteraction must have command")
+		}
+
+		var fragments []string
+		err := i.yamlTypecast(attrs.Value["fragments"], &fragments)
+		if err != nil || len(fragments) == 0 {
+			return interaction, fmt.Errorf("MultiPartCipherInteraction must have a list of fragments")
+		}
+
+		successInteraction, err := i.convertNestedImportInteraction(attrs.Value, "success_interaction", command)
+		if err != nil {
+			return interaction, errors.Wrap(err, "MultiPartCipherInteraction")
+		}
+		if successInteraction == nil {
+			return interaction, fmt.Errorf("MultiPartCipherInteraction must have success_interaction")
+		}
+
+		failureInteraction, err := i.convertNestedImportInteraction(attrs.Value, "failure_interaction", command)
+		if err != nil {
+			return interaction, errors.Wrap(err, "MultiPartCipherInteraction")
+		}
+
+		progressInteraction, err := i.convertNestedImportInteraction(attrs.Value, "progress_interaction", command)
+		if err != nil {
+			return interaction, errors.Wrap(err, "MultiPartCipherInteraction")
+		}
+
+		cipherInteraction, err := game.NewMultiPartCipherInteraction(command, fragments, successInteraction, failureInteraction, progressInteraction)
+		if err != nil {
+			return interaction, errors.Wrap(err, "error creating MultiPartCipherInteraction")
+		}
+
+		cipherInteraction.Limit, err = convertImportCipherLimit(attrs.Value)
+		if err != nil {
+			return interaction, errors.Wrap(err, "MultiPartCipherInteraction")
+		}
+		interaction = cipherInteraction
 	case "ChainedInteraction":
 		command, ok := attrs.Value["command"].(string)
 		if !ok {
@@ -272,6 +346,146 @@ func (i *Importer) convertImportInteraction(attrs *ImportInteraction) (game.Inte
 		if err != nil {
 			return interaction, errors.Wrap(err, "error creating ChainedInteraction")
 		}
+	case "ConditionalInteraction":
+		command, ok := attrs.Value["command"].(string)
+		if !ok {
+			return interaction, fmt.Errorf("ConditionalInteraction must have command")
+		}
+
+		condition, err := i.convertImportCondition(attrs.Value["condition"])
+		if err != nil {
+			return interaction, err
+		}
+
+		successInteraction, err := i.convertNestedImportInteraction(attrs.Value, "success_interaction", command)
+		if err != nil {
+			return interaction, errors.Wrap(err, "ConditionalInteraction")
+		}
+		if successInteraction == nil {
+			return interaction, fmt.Errorf("ConditionalInteraction must have success_interaction")
+		}
+
+		failureInteraction, err := i.convertNestedImportInteraction(attrs.Value, "failure_interaction", command)
+		if err != nil {
+			return interaction, errors.Wrap(err, "ConditionalInteraction")
+		}
+
+		interaction, err = game.NewConditionalInteraction(command, condition, successInteraction, failureInteraction)
+		if err != nil {
+			return interaction, errors.Wrap(err, "error creating ConditionalInteraction")
+		}
+	case "RandomInteraction":
+		command, ok := attrs.Value["command"].(string)
+		if !ok {
+			return interaction, fmt.Errorf("RandomInteraction must have command")
+		}
+
+		outcomesUncast, ok := attrs.Value["outcomes"].([]interface{})
+		if !ok || len(outcomesUncast) == 0 {
+			return interaction, fmt.Errorf("RandomInteraction must have one or more outcomes")
+		}
+
+		outcomes := make([]*game.WeightedInteraction, len(outcomesUncast))
+		for idx, outcomeUncast := range outcomesUncast {
+			var outcomeAttrs map[string]interface{}
+			err := i.yamlTypecast(outcomeUncast, &outcomeAttrs)
+			if err != nil {
+				return interaction, fmt.Errorf("RandomInteraction outcome %d must have weight and interaction", idx)
+			}
+
+			weight, ok := outcomeAttrs["weight"].(int)
+			if !ok || weight <= 0 {
+				return interaction, fmt.Errorf("RandomInteraction outcome %d must have a positive weight", idx)
+			}
+
+			outcomeInteraction, err := i.convertNestedImportInteraction(outcomeAttrs, "interaction", command)
+			if err != nil {
+				return interaction, errors.Wrap(err, fmt.Sprintf("RandomInteraction outcome %d", idx))
+			}
+			if outcomeInteraction == nil {
+				return interaction, fmt.Errorf("RandomInteraction outcome %d must have interaction", idx)
+			}
+
+			outcomes[idx] = &game.WeightedInteraction{
+				Weight:      uint32(weight),
+				Interaction: outcomeInteraction,
+			}
+		}
+
+		randomInteraction, err := game.NewRandomInteraction(command, outcomes...)
+		if err != nil {
+			return interaction, errors.Wrap(err, "error creating RandomInteraction")
+		}
+		randomInteraction.ShowRoll, _ = attrs.Value["show_roll"].(bool)
+		interaction = randomInteraction
+	case "LimitedInteraction":
+		command, ok := attrs.Value["command"].(string)
+		if !ok {
+			return interaction, fmt.Errorf("LimitedInteraction must have command")
+		}
+
+		limitedInteraction, err := i.convertNestedImportInteraction(attrs.Value, "interaction", command)
+		if err != nil {
+			return interaction, errors.Wrap(err, "LimitedInteraction")
+		}
+		if limitedInteraction == nil {
+			return interaction, fmt.Errorf("LimitedInteraction must have interaction")
+		}
+
+		maxUses, _ := attrs.Value["max_uses"].(int)
+		if maxUses < 0 {
+			return interaction, fmt.Errorf("LimitedInteraction max_uses must be positive")
+		}
+
+		var window time.Duration
+		if windowStr, ok := attrs.Value["window"].(string); ok {
+			window, err = time.ParseDuration(windowStr)
+			if err != nil {
+				return interaction, errors.Wrap(err, "LimitedInteraction window must be a duration like 24h")
+			}
+		}
+
+		if maxUses == 0 && window > 0 {
+			// "once per window" is the common case
+			maxUses = 1
+		}
+
+		newInteraction, err := game.NewLimitedInteraction(command, limitedInteraction, uint32(maxUses), window)
+		if err != nil {
+			return interaction, errors.Wrap(err, "error creating LimitedInteraction")
+		}
+		newInteraction.LimitMessage, _ = attrs.Value["limit_message"].(string)
+		interaction = newInteraction
+	case "DialogueInteraction":
+		command, ok := attrs.Value["command"].(string)
+		if !ok {
+			return interaction, fmt.Errorf("DialogueInteraction must have command")
+		}
+
+		start, ok := attrs.Value["start"].(string)
+		if !ok {
+			return interaction, fmt.Errorf("DialogueInteraction must have start")
+		}
+
+		nodesUncast, ok := attrs.Value["nodes"].([]interface{})
+		if !ok || len(nodesUncast) == 0 {
+			return interaction, fmt.Errorf("DialogueInteraction must have one or more nodes")
+		}
+
+		nodes := make([]*game.DialogueNode, len(nodesUncast))
+		for idx, nodeUncast := range nodesUncast {
+			node, err := i.convertImportDialogueNode(nodeUncast, command)
+			if err != nil {
+				return interaction, errors.Wrap(err, fmt.Sprintf("DialogueInteraction node %d", idx))
+			}
+			nodes[idx] = node
+		}
+
+		newInteraction, err := game.NewDialogueInteraction(command, start, nodes...)
+		if err != nil {
+			return interaction, errors.Wrap(err, "error creating DialogueInteraction")
+		}
+		interaction = newInteraction
 	default:
 		typeURL := fmt.Sprintf("type.googleapis.com/jasonsgame.%s", attrs.Type)
 
@@ -295,6 +509,124 @@ func (i *Importer) convertImportInteraction(attrs *ImportInteraction) (game.Inte
 	return interaction, nil
 }
 
+// convertNestedImportInteraction converts the interaction stored under key,
+// defaulting its command to the parent's. Returns nil if key isn't set.
+func (i *Importer) convertNestedImportInteraction(value map[string]interface{}, key string, command string) (game.Interaction, error) {
+	importInteractionUncast, ok := value[key]
+	if !ok || importInteractionUncast == nil {
+		return nil, nil
+	}
+
+	var importInteraction *ImportInteraction
+	err := i.yamlTypecast(importInteractionUncast, &importInteraction)
+	if err != nil || importInteraction == nil {
+		return nil, fmt.Errorf("%s must be ImportInteraction", key)
+	}
+	if importInteraction.Value == nil {
+		importInteraction.Value = make(map[string]interface{})
+	}
+	if _, ok := importInteraction.Value["command"]; !ok {
+		importInteraction.Value["command"] = command
+	}
+
+	return i.convertImportInteraction(importInteraction)
+}
+
+func (i *Importer) convertImportDialogueNode(nodeUncast interface{}, command string) (*game.DialogueNode, error) {
+	var nodeAttrs map[string]interface{}
+	err := i.yamlTypecast(nodeUncast, &nodeAttrs)
+	if err != nil {
+		return nil, fmt.Errorf("must have id and text")
+	}
+
+	id, ok := nodeAttrs["id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("must have id")
+	}
+	text, ok := nodeAttrs["text"].(string)
+	if !ok {
+		return nil, fmt.Errorf("must have text")
+	}
+
+	var choices []*game.DialogueChoice
+	if choicesUncast, ok := nodeAttrs["choices"]; ok {
+		err = i.yamlTypecast(choicesUncast, &choices)
+		if err != nil {
+			return nil, fmt.Errorf("choices must be a list of text and next")
+		}
+	}
+
+	nodeInteraction, err := i.convertNestedImportInteraction(nodeAttrs, "interaction", command)
+	if err != nil {
+		return nil, err
+	}
+
+	return game.NewDialogueNode(id, text, nodeInteraction, choices...)
+}
+
+// convertImportCipherLimit returns nil, meaning the default limits, unless
+// max_attempts, lockout or lockout_message are set
+func convertImportCipherLimit(value map[string]interface{}) (*game.CipherLimit, error) {
+	maxAttempts, hasMaxAttempts := value["max_attempts"].(int)
+	lockoutStr, hasLockout := value["lockout"].(string)
+	lockoutMessage, hasLockoutMessage := value["lockout_message"].(string)
+
+	if !hasMaxAttempts && !hasLockout && !hasLockoutMessage {
+		return nil, nil
+	}
+
+	if maxAttempts < 0 {
+		return nil, fmt.Errorf("max_attempts must be positive")
+	}
+
+	var lockout time.Duration
+	if hasLockout {
+		var err error
+		lockout, err = time.ParseDuration(lockoutStr)
+		if err != nil {
+			return nil, errors.Wrap(err, "lockout must be a duration like 10m")
+		}
+	}
+
+	return &game.CipherLimit{
+		MaxAttempts:    uint32(maxAttempts),
+		LockoutSeconds: int64(lockout / time.Second),
+		LockoutMessage: lockoutMessage,
+	}, nil
+}
+
+func (i *Importer) convertImportCondition(conditionUncast interface{}) (*game.InteractionCondition, error) {
+	var conditionAttrs map[string]string
+	err := i.yamlTypecast(conditionUncast, &conditionAttrs)
+	if err != nil || len(conditionAttrs) == 0 {
+		return nil, fmt.Errorf("ConditionalInteraction must have a condition")
+	}
+
+	condition := &game.InteractionCondition{}
+	for key, val := range conditionAttrs {
+		switch key {
+		case "inventory_contains":
+			condition.InventoryContains = val
+		case "tree_did":
+			condition.TreeDid = val
+		case "tree_path":
+			condition.TreePath = val
+		case "tree_value":
+			condition.TreeValue = val
+		case "owns_did":
+			condition.OwnsDid = val
+		default:
+			return nil, fmt.Errorf("unknown condition %s", key)
+		}
+	}
+
+	if condition.TreeDid != "" && condition.TreePath == "" {
+		return nil, fmt.Errorf("condition with tree_did must have tree_path")
+	}
+
+	return condition, nil
+}
+
 func (i *Importer) loadInteractions(tree *consensus.SignedChainTree, data []*ImportInteraction) (*consensus.SignedChainTree, error) {
 	var err error
 	if len(data) == 0 {
@@ -389,6 +721,44 @@ func (i *Importer) updateObject(did string, objData *ImportObject) error {
 	})
 }
 
+func (i *Importer) loadQuests(data map[string]*ImportQuest, ids *NameToDids) error {
+	for name, questData := range data {
+		did := ids.Quests[name]
+
+		if questData.Name == "" {
+			questData.Name = strings.ReplaceAll(name, "_", " ")
+		}
+
+		err := i.updateQuest(did, questData)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("error importing quest %s", name))
+		}
+	}
+	return nil
+}
+
+func (i *Importer) updateQuest(did string, questData *ImportQuest) error {
+	quest := &game.Quest{
+		Name:              questData.Name,
+		Description:       questData.Description,
+		CompletionMessage: questData.CompletionMessage,
+		Objectives:        make([]*game.QuestObjective, len(questData.Objectives)),
+	}
+	for idx, objective := range questData.Objectives {
+		quest.Objectives[idx] = &game.QuestObjective{
+			Description:    objective.Description,
+			VisitLocation:  objective.VisitLocation,
+			HoldObject:     objective.HoldObject,
+			UseInteraction: objective.UseInteraction,
+			ReceivePrize:   objective.ReceivePrize,
+		}
+	}
+
+	return i.updateTreeIfChanged(did, questData, func(tree *consensus.SignedChainTree) error {
+		return game.NewQuestTree(i.network, tree).SetQuest(quest)
+	})
+}
+
 func (i *Importer) updateTreeIfChanged(did string, treeData interface{}, updateFunction func(tree *consensus.SignedChainTree) error) error {
 	tree, err := i.network.GetTree(did)
 	if err != nil {
@@ -523,7 +893,12 @@ func (i *Importer) Import(importPath string) (*NameToDids, error) {
 		return ids, err
 	}
 
-	log.Infof("import complete - %d locations created - %d objects created", len(ids.Locations), len(ids.Objects))
+	err = i.loadQuests(data.Quests, ids)
+	if err != nil {
+		return ids, err
+	}
+
+	log.Infof("import complete - %d locations created - %d objects created - %d quests created", len(ids.Locations), len(ids.Objects), len(ids.Quests))
 
 	return ids, nil
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/quorumcontrol/jasons-game/game"
 	"github.com/quorumcontrol/jasons-game/network"
 	"github.com/stretchr/testify/require"
 )
@@ -36,7 +37,7 @@ func TestImport(t *testing.T) {
 	require.Equal(t, val, "you are now in the forest, what now?")
 	val, _, err = tree.ChainTree.Dag.Resolve(ctx, []string{"tree", "data", "jasons-game", "interactions"})
 	require.Nil(t, err)
-	require.Equal(t, len(val.(map[string]interface{})), 3)
+	require.Equal(t, len(val.(map[string]interface{})), 4)
 	val, _, err = tree.ChainTree.Dag.Resolve(ctx, []string{"tree", "data", "jasons-game", "inventory"})
 	require.Nil(t, err)
 	require.Nil(t, val)
@@ -50,4 +51,225 @@ func TestImport(t *testing.T) {
 	val, _, err = tree.ChainTree.Dag.Resolve(ctx, []string{"tree", "data", "jasons-game", "interactions"})
 	require.Nil(t, err)
 	require.Equal(t, len(val.(map[string]interface{})), 4)
+
+	tree, err = net.GetTree(ids.Quests["idol_hunt"])
+	require.Nil(t, err)
+	require.NotNil(t, tree)
+	quest, err := game.NewQuestTree(net, tree).Quest()
+	require.Nil(t, err)
+	require.Equal(t, "idol hunt", quest.Name)
+	require.Len(t, quest.Objectives, 2)
+	require.Equal(t, ids.Locations["forest"], quest.Objectives[0].VisitLocation)
+	require.Equal(t, "idol", quest.Objectives[1].HoldObject)
+}
+
+func TestImportConditionalInteraction(t *testing.T) {
+	net := network.NewLocalNetwork()
+	imp := New(net)
+
+	interaction, err := imp.convertImportInteraction(&ImportInteraction{
+		Type: "ConditionalInteraction",
+		Value: map[string]interface{}{
+			"command": "open door",
+			"condition": map[string]interface{}{
+				"inventory_contains": "key",
+			},
+			"success_interaction": map[string]interface{}{
+				"type":  "RespondInteraction",
+				"value": map[string]interface{}{"response": "the door swings open"},
+			},
+			"failure_interaction": map[string]interface{}{
+				"type":  "RespondInteraction",
+				"value": map[string]interface{}{"response": "the door is locked"},
+			},
+		},
+	})
+	require.Nil(t, err)
+
+	conditional, ok := interaction.(*game.ConditionalInteraction)
+	require.True(t, ok)
+	require.Equal(t, "open door", conditional.Command)
+	require.Equal(t, "key", conditional.Condition.InventoryContains)
+
+	success, err := conditional.SuccessInteraction()
+	require.Nil(t, err)
+	require.Equal(t, "open door", success.GetCommand())
+
+	_, err = imp.convertImportInteraction(&ImportInteraction{
+		Type: "ConditionalInteraction",
+		Value: map[string]interface{}{
+			"command":   "open door",
+			"condition": map[string]interface{}{"unknown": "value"},
+		},
+	})
+	require.NotNil(t, err)
+}
+
+func TestImportRandomInteraction(t *testing.T) {
+	net := network.NewLocalNetwork()
+	imp := New(net)
+
+	interaction, err := imp.convertImportInteraction(&ImportInteraction{
+		Type: "RandomInteraction",
+		Value: map[string]interface{}{
+			"command":   "open chest",
+			"show_roll": true,
+			"outcomes": []interface{}{
+				map[string]interface{}{
+					"weight": 1,
+					"interaction": map[string]interface{}{
+						"type":  "RespondInteraction",
+						"value": map[string]interface{}{"response": "gold"},
+					},
+				},
+				map[string]interface{}{
+					"weight": 9,
+					"interaction": map[string]interface{}{
+						"type":  "RespondInteraction",
+						"value": map[string]interface{}{"response": "dust"},
+					},
+				},
+			},
+		},
+	})
+	require.Nil(t, err)
+
+	random, ok := interaction.(*game.RandomInteraction)
+	require.True(t, ok)
+	require.True(t, random.ShowRoll)
+	require.Equal(t, []uint32{1, 9}, random.Weights)
+
+	_, err = imp.convertImportInteraction(&ImportInteraction{
+		Type: "RandomInteraction",
+		Value: map[string]interface{}{
+			"command": "open chest",
+			"outcomes": []interface{}{
+				map[string]interface{}{
+					"weight": 0,
+					"interaction": map[string]interface{}{
+						"type":  "RespondInteraction",
+						"value": map[string]interface{}{"response": "nothing"},
+					},
+				},
+			},
+		},
+	})
+	require.NotNil(t, err)
+}
+
+func TestImportLimitedInteraction(t *testing.T) {
+	net := network.NewLocalNetwork()
+	imp := New(net)
+
+	interaction, err := imp.convertImportInteraction(&ImportInteraction{
+		Type: "LimitedInteraction",
+		Value: map[string]interface{}{
+			"command":       "pray at the altar",
+			"window":        "24h",
+			"limit_message": "the gods are resting",
+			"interaction": map[string]interface{}{
+				"type":  "RespondInteraction",
+				"value": map[string]interface{}{"response": "you feel blessed"},
+			},
+		},
+	})
+	require.Nil(t, err)
+
+	limited, ok := interaction.(*game.LimitedInteraction)
+	require.True(t, ok)
+	require.Equal(t, uint32(1), limited.MaxUses)
+	require.Equal(t, int64(24*60*60), limited.WindowSeconds)
+	require.Equal(t, "the gods are resting", limited.LimitMessage)
+}
+
+func TestImportDialogueInteraction(t *testing.T) {
+	net := network.NewLocalNetwork()
+	imp := New(net)
+
+	interaction, err := imp.convertImportInteraction(&ImportInteraction{
+		Type: "DialogueInteraction",
+		Value: map[string]interface{}{
+			"command": "talk to the hermit",
+			"start":   "greeting",
+			"nodes": []interface{}{
+				map[string]interface{}{
+					"id":   "greeting",
+					"text": "what do you want?",
+					"choices": []interface{}{
+						map[string]interface{}{"text": "a gift", "next": "gift"},
+						map[string]interface{}{"text": "nothing"},
+					},
+				},
+				map[string]interface{}{
+					"id":   "gift",
+					"text": "take this and go",
+					"interaction": map[string]interface{}{
+						"type":  "RespondInteraction",
+						"value": map[string]interface{}{"response": "you receive a pebble"},
+					},
+				},
+			},
+		},
+	})
+	require.Nil(t, err)
+
+	dialogue, ok := interaction.(*game.DialogueInteraction)
+	require.True(t, ok)
+	require.Equal(t, "greeting", dialogue.Start)
+	require.Len(t, dialogue.Node("greeting").Choices, 2)
+	require.Equal(t, "gift", dialogue.Node("greeting").Choices[0].Next)
+
+	giftInteraction, err := dialogue.Node("gift").Interaction()
+	require.Nil(t, err)
+	require.Equal(t, "you receive a pebble", giftInteraction.(*game.RespondInteraction).Response)
+
+	_, err = imp.convertImportInteraction(&ImportInteraction{
+		Type: "DialogueInteraction",
+		Value: map[string]interface{}{
+			"command": "talk to the hermit",
+			"start":   "missing",
+			"nodes": []interface{}{
+				map[string]interface{}{"id": "greeting", "text": "hello"},
+			},
+		},
+	})
+	require.NotNil(t, err)
+}
+
+func TestImportMultiPartCipherInteraction(t *testing.T) {
+	net := network.NewLocalNetwork()
+	imp := New(net)
+
+	interaction, err := imp.convertImportInteraction(&ImportInteraction{
+		Type: "MultiPartCipherInteraction",
+		Value: map[string]interface{}{
+			"command":      "speak",
+			"fragments":    []interface{}{"open", "sesame"},
+			"max_attempts": 3,
+			"lockout":      "10m",
+			"success_interaction": map[string]interface{}{
+				"type":  "RespondInteraction",
+				"value": map[string]interface{}{"response": "the door swings open"},
+			},
+			"progress_interaction": map[string]interface{}{
+				"type":  "RespondInteraction",
+				"value": map[string]interface{}{"response": "the door rumbles"},
+			},
+		},
+	})
+	require.Nil(t, err)
+
+	cipher, ok := interaction.(*game.MultiPartCipherInteraction)
+	require.True(t, ok)
+	require.Len(t, cipher.SealedShares, 2)
+	require.Equal(t, uint32(3), cipher.Limit.MaxAttempts)
+	require.Equal(t, int64(600), cipher.Limit.LockoutSeconds)
+
+	progressInteraction, err := cipher.ProgressInteraction()
+	require.Nil(t, err)
+	require.Equal(t, "the door rumbles", progressInteraction.(*game.RespondInteraction).Response)
+
+	failureInteraction, err := cipher.FailureInteraction()
+	require.Nil(t, err)
+	require.Nil(t, failureInteraction)
 }
@@ -5,6 +5,7 @@ import (
 	"crypto/ecdsa"
 	"crypto/sha256"
 	"fmt"
+	"time"
 
 	"github.com/AsynkronIT/protoactor-go/actor"
 	"github.com/AsynkronIT/protoactor-go/eventstream"
@@ -271,6 +272,10 @@ func (ln *LocalNetwork) DisallowReceiveInk(chaintreeId string) {
 	// placeholder to fulfill the interface
 }
 
+func (ln *LocalNetwork) InkBalance(tree *consensus.SignedChainTree, tokenName *consensus.TokenName) (uint64, error) {
+	return inkBalance(tree, tokenName)
+}
+
 func (ln *LocalNetwork) PlayTransactions(tree *consensus.SignedChainTree, transactions []*transactions.Transaction) (*consensus.SignedChainTree, error) {
 	ctx := context.TODO()
 	unmarshaledRoot, err := tree.ChainTree.Dag.Get(ctx, tree.Tip())
@@ -306,6 +311,8 @@ func (ln *LocalNetwork) PlayTransactions(tree *consensus.SignedChainTree, transa
 	if err != nil {
 		return nil, fmt.Errorf("error signing root: %v", err)
 	}
+	// headers aren't signed, so the time is added after signing
+	blockWithHeaders.Headers[BlockTimeHeader] = time.Now().Unix()
 
 	isValid, err := tree.ChainTree.ProcessBlock(ctx, blockWithHeaders)
 	if err != nil {
@@ -32,6 +32,10 @@ var log = logging.Logger("gamenetwork")
 const CommunityDiscoveryNamespace = CommunityName + "-hub"
 const TupueloDiscoveryNamespace = "tupelo-transaction-gossipers"
 
+// BlockTimeHeader is the block header holding the unix time a block was made,
+// on networks that stamp their blocks
+const BlockTimeHeader = "timestamp"
+
 var DefaultGameBootstrappers = []string{
 	"/ip4/3.13.69.217/tcp/34011/ipfs/16Uiu2HAmSXDGtQTaNPVzQQkdYuZ221k5668tUYeEEpnzE7UEteFn",
 	"/ip4/34.212.243.16/tcp/34011/ipfs/16Uiu2HAmL3JgeNJGcqZjUgzaq5nhPwDXgGpxah5ssBokaUbKo6ds",
@@ -44,6 +48,7 @@ type InkNetwork interface {
 	ReceiveInk(tree *consensus.SignedChainTree, tokenPayload *transactions.TokenPayload) error
 	ReceiveInkOnEphemeralChainTree(tree *consensus.SignedChainTree, privateKey *ecdsa.PrivateKey, tokenPayload *transactions.TokenPayload) error
 	DisallowReceiveInk(chaintreeId string)
+	InkBalance(tree *consensus.SignedChainTree, tokenName *consensus.TokenName) (uint64, error)
 }
 
 type Network interface {
@@ -44,3 +44,31 @@ func (n *RemoteNetwork) receiveInk(tree *consensus.SignedChainTree, privateKey *
 
 	return nil
 }
+
+func (n *RemoteNetwork) InkBalance(tree *consensus.SignedChainTree, tokenName *consensus.TokenName) (uint64, error) {
+	return inkBalance(tree, tokenName)
+}
+
+// inkBalance is how much of the token the tree holds, zero if it has never
+// held any
+func inkBalance(tree *consensus.SignedChainTree, tokenName *consensus.TokenName) (uint64, error) {
+	dataTree, err := tree.ChainTree.Tree(context.TODO())
+	if err != nil {
+		return 0, errors.Wrap(err, "error fetching tree")
+	}
+
+	ledger := consensus.NewTreeLedger(dataTree, tokenName)
+	exists, err := ledger.TokenExists()
+	if err != nil {
+		return 0, errors.Wrap(err, "error checking for token")
+	}
+	if !exists {
+		return 0, nil
+	}
+
+	balance, err := ledger.Balance()
+	if err != nil {
+		return 0, errors.Wrap(err, "error fetching token balance")
+	}
+	return balance, nil
+}
@@ -12,4 +12,6 @@ func init() {
 	typecaster.AddType(Portal{})
 	cbor.RegisterCborType(Player{})
 	typecaster.AddType(Player{})
+	cbor.RegisterCborType(AccessPolicy{})
+	typecaster.AddType(AccessPolicy{})
 }
@@ -15,11 +15,16 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	badger "github.com/ipfs/go-ds-badger"
 	"github.com/pkg/errors"
+	"github.com/quorumcontrol/tupelo-go-sdk/consensus"
 	"github.com/shibukawa/configdir"
 	"github.com/spf13/cobra"
 
+	"github.com/quorumcontrol/jasons-game/game/static"
 	"github.com/quorumcontrol/jasons-game/handlers"
+	"github.com/quorumcontrol/jasons-game/handlers/chatlog"
+	"github.com/quorumcontrol/jasons-game/handlers/inksink"
 	"github.com/quorumcontrol/jasons-game/handlers/inventory"
+	"github.com/quorumcontrol/jasons-game/handlers/mailbox"
 	"github.com/quorumcontrol/jasons-game/network"
 	"github.com/quorumcontrol/jasons-game/service"
 )
@@ -79,6 +84,7 @@ func main() {
 			}
 
 			serviceHandlers := []handlers.Handler{}
+			var inkSinkHandler *inksink.InkSinkHandler
 
 			for _, h := range handlersFlag {
 				switch h {
@@ -86,6 +92,24 @@ func main() {
 					serviceHandlers = append(serviceHandlers, inventory.NewUnrestrictedAddHandler(net))
 				case "inventory.UnrestrictedRemoveHandler":
 					serviceHandlers = append(serviceHandlers, inventory.NewUnrestrictedRemoveHandler(net))
+				case "chatlog.ChatLogHandler":
+					serviceHandlers = append(serviceHandlers, chatlog.NewChatLogHandler(net))
+				case "mailbox.MailboxHandler":
+					mailboxHandler, err := mailbox.NewMailboxHandler(net)
+					if err != nil {
+						panic(errors.Wrap(err, "setting up mailbox handler"))
+					}
+					serviceHandlers = append(serviceHandlers, mailboxHandler)
+				case "inksink.InkSinkHandler":
+					inkDID, err := static.Get(net, "InkDID")
+					if err != nil || inkDID == "" {
+						panic(fmt.Sprintf("ink sink needs static.InkDID to be set: %v", err))
+					}
+					inkSinkHandler, err = inksink.NewInkSinkHandler(net, &consensus.TokenName{ChainTreeDID: inkDID, LocalName: "ink"})
+					if err != nil {
+						panic(errors.Wrap(err, "setting up ink sink handler"))
+					}
+					serviceHandlers = append(serviceHandlers, inkSinkHandler)
 				default:
 					panic(fmt.Sprintf("handler of type %v is not supported", h))
 				}
@@ -102,6 +126,14 @@ func main() {
 			}
 			fmt.Printf("Starting service with ChainTree id %v\n", serviceDid)
 
+			if inkSinkHandler != nil {
+				err = inkSinkHandler.SetHandler(serviceDid.(string))
+				if err != nil {
+					panic(errors.Wrap(err, "error pointing the ink sink at this service"))
+				}
+				fmt.Printf("Ink sink is ChainTree id %v, set it as static.InkSink\n", inkSinkHandler.Did())
+			}
+
 			stopOnSignal(servicePID)
 		},
 	}
@@ -55,6 +55,11 @@ func buildUIMessage(msg proto.Message) (*jasonsgame.UserInterfaceMessage, error)
 			UiMessage: &jasonsgame.UserInterfaceMessage_CommandUpdate{CommandUpdate: msg},
 		}
 		return uiMsg, nil
+	case *jasonsgame.MapUpdate:
+		uiMsg := &jasonsgame.UserInterfaceMessage{
+			UiMessage: &jasonsgame.UserInterfaceMessage_MapUpdate{MapUpdate: msg},
+		}
+		return uiMsg, nil
 	default:
 		return nil, fmt.Errorf("Unrecognized user interface message: %v", msg)
 	}
@@ -132,6 +137,26 @@ func (us *UIServer) Receive(actorCtx actor.Context) {
 			log.Errorf("error sending message to stream: %v", err)
 		}
 
+	case *jasonsgame.MapUpdate:
+		actorCtx.SetReceiveTimeout(5 * time.Second)
+		log.Debugf("map update: %d locations", len(msg.Locations))
+		if us.stream == nil {
+			log.Errorf("no valid stream for map update")
+			return
+		}
+
+		uiMsg, err := buildUIMessage(msg)
+		if err != nil {
+			panic(err)
+		}
+
+		err = us.stream.Send(uiMsg)
+		if err != nil {
+			us.stream = nil
+			us.sendDone()
+			log.Errorf("error sending message to stream: %v", err)
+		}
+
 	case *jasonsgame.UserInput:
 		actorCtx.SetReceiveTimeout(5 * time.Second)
 		log.Debugf("user input %s", msg.Message)